@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"forum/internal/store"
+)
+
+// cleanupSessions borra las sesiones caducadas al arrancar y luego cada
+// every. Con every <= 0 no hace nada.
+func cleanupSessions(sessions store.SessionStore, every time.Duration) {
+	if every <= 0 {
+		return
+	}
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		n, err := sessions.DeleteExpired(ctx, time.Now())
+		cancel()
+		if err != nil {
+			log.Printf("session cleanup err: %v", err)
+		} else if n > 0 {
+			log.Printf("session cleanup: %d expired sessions deleted", n)
+		}
+		time.Sleep(every)
+	}
+}
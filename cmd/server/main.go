@@ -1,26 +1,47 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
+	"os"
 	"time"
 
 	"forum/internal/app"
 	"forum/internal/db"
 	httpx "forum/internal/http"
+	"forum/internal/store/sqlstore"
 )
 
 func main() {
 
 	cfg := app.LoadConfig()
 
+	// forum migrate up|down [n]|status
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(cfg, os.Args[2:]))
+	}
+	// forum role <username> <role>
+	if len(os.Args) > 1 && os.Args[1] == "role" {
+		os.Exit(runRole(cfg, os.Args[2:]))
+	}
+
 	d, err := db.Open(cfg.DatabaseURL)
 	app.Must(err)
 
-	// Usa el esquema de Postgres en esta rama
-	app.Must(db.Migrate(d, "schema.pg.sql"))
+	// Aplica las migraciones pendientes; en Postgres el advisory lock evita carreras si
+	// arrancan varias instancias a la vez.
+	applied, err := db.MigrateUp(context.Background(), d)
+	app.Must(err)
+	for _, m := range applied {
+		log.Printf("migration applied: %04d_%s", m.Version, m.Name)
+	}
+
+	st := sqlstore.New(d)
+	srv := httpx.NewServer(st, cfg)
 
-	srv := httpx.NewServer(d, cfg)
+	// Las sesiones caducadas no se borran solas
+	go cleanupSessions(st.Sessions, cfg.SessionCleanup)
 
 	// ⚙️ Encadena middlewares a nivel de servidor
 	var handler http.Handler = srv
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+
+	"forum/internal/app"
+	"forum/internal/db"
+)
+
+const migrateUsage = `usage: forum migrate <command>
+
+  up          apply all pending migrations
+  down [n]    roll back the last n migrations (default 1)
+  status      list migrations and whether they are applied`
+
+// runMigrate implementa el subcomando "migrate" y devuelve el código de salida.
+func runMigrate(cfg app.Config, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	d, err := db.Open(cfg.DatabaseURL)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "migrate:", err)
+		return 1
+	}
+	defer d.Close()
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		done, err := db.MigrateUp(ctx, d)
+		for _, m := range done {
+			fmt.Printf("applied   %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "migrate up:", err)
+			return 1
+		}
+		if len(done) == 0 {
+			fmt.Println("nothing to apply")
+		}
+
+	case "down":
+		n := 1
+		if len(args) > 1 {
+			if n, err = strconv.Atoi(args[1]); err != nil || n < 1 {
+				fmt.Fprintln(os.Stderr, "migrate down: n must be a positive number")
+				return 2
+			}
+		}
+		done, err := db.MigrateDown(ctx, d, n)
+		for _, m := range done {
+			fmt.Printf("reverted  %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "migrate down:", err)
+			return 1
+		}
+		if len(done) == 0 {
+			fmt.Println("nothing to revert")
+		}
+
+	case "status":
+		st, err := db.MigrationsStatus(ctx, d)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "migrate status:", err)
+			return 1
+		}
+		for _, s := range st {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%-30s %s\n", s.Version, s.Name, applied)
+		}
+
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+	return 0
+}
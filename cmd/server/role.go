@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"forum/internal/app"
+	"forum/internal/auth"
+	"forum/internal/db"
+	"forum/internal/store"
+	"forum/internal/store/sqlstore"
+)
+
+const roleUsage = `usage: forum role <username> <member|moderator|admin>
+
+  Changes a user's role (recorded in the audit log). Use it to create the
+  first admin.`
+
+// runRole implementa el subcomando "role" y devuelve el código de salida.
+func runRole(cfg app.Config, args []string) int {
+	if len(args) != 2 {
+		fmt.Fprintln(os.Stderr, roleUsage)
+		return 2
+	}
+
+	d, err := db.Open(cfg.DatabaseURL)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "role:", err)
+		return 1
+	}
+	defer d.Close()
+
+	ctx := context.Background()
+	st := sqlstore.New(d)
+	before, _ := st.Users.ByUsername(ctx, args[0])
+	err = auth.SetUserRole(ctx, st.Users, args[0], args[1])
+	switch {
+	case errors.Is(err, auth.ErrInvalidRole):
+		fmt.Fprintln(os.Stderr, "role:", err)
+		return 2
+	case errors.Is(err, store.ErrNotFound):
+		fmt.Fprintf(os.Stderr, "role: no user named %q\n", args[0])
+		return 1
+	case err != nil:
+		fmt.Fprintln(os.Stderr, "role:", err)
+		return 1
+	}
+	// Sin actor ni IP: en el registro queda como hecho desde la línea de comandos
+	if err := auth.Audit(ctx, st.Audit, 0, "", auth.AuditUserRole, "user", before.ID,
+		map[string]string{"role": before.Role}, map[string]string{"role": args[1]}); err != nil {
+		fmt.Fprintln(os.Stderr, "role: audit:", err)
+	}
+	fmt.Printf("%s is now %s\n", args[0], args[1])
+	return 0
+}
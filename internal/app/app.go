@@ -4,13 +4,40 @@ import (
 	"database/sql"
 	"log"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 )
 
 type Config struct {
 	Addr            string
 	DatabaseURL     string
-	SessionLifetime time.Duration
+	SessionLifetime time.Duration // validez de la sesión desde la última renovación
+	SessionRenew    float64       // fracción de SessionLifetime tras la que se renueva (0 = nunca)
+	SessionMaxAge   time.Duration // tope absoluto desde el login (0 = sin tope)
+	BaseURL         string        // URL pública, para enlaces en correos
+	MailDir         string        // si no está vacío, los correos se guardan aquí como .eml
+	ResetTokenTTL   time.Duration // validez de los enlaces de reseteo de contraseña
+	VerifyTokenTTL  time.Duration // validez de los enlaces de verificación de email
+	EmailPolicy     EmailPolicy   // qué se bloquea hasta verificar el email
+	SessionCleanup  time.Duration // cada cuánto se borran las sesiones caducadas (0 = nunca)
+}
+
+// EmailPolicy decide qué puede hacer un usuario con el email sin verificar.
+type EmailPolicy string
+
+const (
+	EmailPolicyOff   EmailPolicy = "off"   // no se exige verificación
+	EmailPolicyPost  EmailPolicy = "post"  // puede entrar, pero no publicar/comentar/reaccionar
+	EmailPolicyLogin EmailPolicy = "login" // ni siquiera puede iniciar sesión
+)
+
+func parseEmailPolicy(v string) EmailPolicy {
+	switch p := EmailPolicy(strings.ToLower(v)); p {
+	case EmailPolicyOff, EmailPolicyPost, EmailPolicyLogin:
+		return p
+	}
+	return EmailPolicyPost
 }
 
 func LoadConfig() Config {
@@ -23,10 +50,42 @@ func LoadConfig() Config {
 	if err != nil {
 		dur = 24 * time.Hour
 	}
+	resetMin := getenv("RESET_TOKEN_TTL_MINUTES", "60")
+	resetTTL, err := time.ParseDuration(resetMin + "m")
+	if err != nil {
+		resetTTL = time.Hour
+	}
+	verifyHours := getenv("VERIFY_TOKEN_TTL_HOURS", "48")
+	verifyTTL, err := time.ParseDuration(verifyHours + "h")
+	if err != nil {
+		verifyTTL = 48 * time.Hour
+	}
+	renew, err := strconv.ParseFloat(getenv("SESSION_RENEW_FRACTION", "0.5"), 64)
+	if err != nil || renew < 0 || renew > 1 {
+		renew = 0.5
+	}
+	maxDays, err := strconv.Atoi(getenv("SESSION_MAX_LIFETIME_DAYS", "30"))
+	if err != nil || maxDays < 0 {
+		maxDays = 30
+	}
+	maxAge := time.Duration(maxDays) * 24 * time.Hour
+	cleanupMin := getenv("SESSION_CLEANUP_MINUTES", "60")
+	cleanup, err := time.ParseDuration(cleanupMin + "m")
+	if err != nil {
+		cleanup = time.Hour
+	}
 	return Config{
 		Addr:            addr,
 		DatabaseURL:     dbURL,
 		SessionLifetime: dur,
+		SessionRenew:    renew,
+		SessionMaxAge:   maxAge,
+		BaseURL:         strings.TrimRight(getenv("BASE_URL", "http://localhost:8080"), "/"),
+		MailDir:         os.Getenv("MAIL_DIR"),
+		ResetTokenTTL:   resetTTL,
+		VerifyTokenTTL:  verifyTTL,
+		EmailPolicy:     parseEmailPolicy(getenv("EMAIL_VERIFICATION", "post")),
+		SessionCleanup:  cleanup,
 	}
 }
 
@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"forum/internal/models"
+	"forum/internal/store"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrInvalidEmail = errors.New("enter a valid email address")
+	ErrSameEmail    = errors.New("that is already your email address")
+	ErrNoUsername   = errors.New("username is required")
+)
+
+/* =========================
+   Contraseña
+   ========================= */
+
+// ChangePassword cambia la contraseña tras comprobar la actual, cierra las
+// demás sesiones del usuario (todas menos keep, la de quien la cambia) y
+// revoca sus tokens personales, que se crearon con la contraseña vieja.
+func ChangePassword(ctx context.Context, users store.UserStore, uid int64, current, newPassword, keep string) error {
+	if err := checkPassword(ctx, users, uid, current); err != nil {
+		return err
+	}
+	if err := validatePassword(newPassword); err != nil {
+		return err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	return users.ChangePassword(ctx, uid, string(hash), keep)
+}
+
+/* =========================
+   Email
+   ========================= */
+
+// RequestEmailChange comprueba la contraseña y la dirección nueva y devuelve
+// el token del enlace de confirmación y la dirección normalizada, a la que hay
+// que mandarlo. El email no cambia hasta ConfirmEmailChange.
+func RequestEmailChange(ctx context.Context, users store.UserStore, changes store.EmailChangeStore, uid int64, password, newEmail string, ttl time.Duration) (string, string, error) {
+	newEmail = strings.TrimSpace(strings.ToLower(newEmail))
+	if err := checkPassword(ctx, users, uid, password); err != nil {
+		return "", "", err
+	}
+	if !strings.Contains(newEmail, "@") || strings.ContainsAny(newEmail, " \t\r\n") {
+		return "", "", ErrInvalidEmail
+	}
+	u, err := users.ByID(ctx, uid)
+	if err != nil {
+		return "", "", err
+	}
+	if u.Email == newEmail {
+		return "", "", ErrSameEmail
+	}
+	// Se vuelve a comprobar al confirmar; aquí solo evita un correo inútil
+	if _, err := users.ByEmail(ctx, newEmail); err == nil {
+		return "", "", ErrEmailTaken
+	} else if !errors.Is(err, store.ErrNotFound) {
+		return "", "", err
+	}
+
+	raw, hash, err := newToken()
+	if err != nil {
+		return "", "", err
+	}
+	now := time.Now()
+	if err := changes.Create(ctx, uid, newEmail, hash, now.Add(ttl), now); err != nil {
+		return "", "", err
+	}
+	return raw, newEmail, nil
+}
+
+// PendingEmail devuelve la dirección a la espera de confirmación ("" si no hay).
+func PendingEmail(ctx context.Context, changes store.EmailChangeStore, uid int64) (string, error) {
+	email, err := changes.Pending(ctx, uid, time.Now())
+	if errors.Is(err, store.ErrNotFound) {
+		return "", nil
+	}
+	return email, err
+}
+
+// ConfirmEmailChange consume el token y pone la dirección nueva, ya
+// verificada (el enlace llegó a ella). Devuelve el usuario como era antes del
+// cambio, para avisar a la dirección anterior, y la nueva.
+func ConfirmEmailChange(ctx context.Context, users store.UserStore, changes store.EmailChangeStore, token string) (models.User, string, error) {
+	if token == "" {
+		return models.User{}, "", ErrInvalidToken
+	}
+	now := time.Now()
+	uid, newEmail, err := changes.Consume(ctx, HashToken(token), now)
+	if errors.Is(err, store.ErrNotFound) {
+		return models.User{}, "", ErrInvalidToken
+	}
+	if err != nil {
+		return models.User{}, "", err
+	}
+	u, err := users.ByID(ctx, uid)
+	if err != nil {
+		return models.User{}, "", err
+	}
+	err = users.SetEmail(ctx, uid, newEmail, now)
+	if errors.Is(err, store.ErrEmailTaken) {
+		return models.User{}, "", ErrEmailTaken
+	}
+	if err != nil {
+		return models.User{}, "", err
+	}
+	return u, newEmail, nil
+}
+
+/* =========================
+   Nombre de usuario
+   ========================= */
+
+// ChangeUsername renombra la cuenta. El nombre anterior queda en el historial
+// y nadie más puede usarlo (ver UserStore.SetUsername).
+func ChangeUsername(ctx context.Context, users store.UserStore, uid int64, username string) error {
+	username = strings.TrimSpace(username)
+	if username == "" {
+		return ErrNoUsername
+	}
+	err := users.SetUsername(ctx, uid, username, time.Now())
+	if errors.Is(err, store.ErrUsernameTaken) {
+		return ErrUsernameTaken
+	}
+	return err
+}
+
+// PastUsernames devuelve los nombres anteriores de uid, el último primero.
+func PastUsernames(ctx context.Context, users store.UserStore, uid int64) ([]models.UsernameChange, error) {
+	return users.PastUsernames(ctx, uid)
+}
@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"forum/internal/models"
+	"forum/internal/store"
+)
+
+/* =========================
+   Personal API tokens
+   ========================= */
+
+// Prefijo de los tokens personales; permite distinguirlos de los de sesión.
+const APITokenPrefix = "fpat_"
+
+const (
+	ScopeRead  = "read"
+	ScopePost  = "post"
+	ScopeReact = "react"
+	ScopeAdmin = "admin"
+)
+
+// AllScopes en el orden en que se muestran en la página de ajustes.
+var AllScopes = []string{ScopeRead, ScopePost, ScopeReact, ScopeAdmin}
+
+var (
+	ErrInvalidScope = errors.New("unknown token scope")
+	ErrNoAPIToken   = errors.New("api token not found")
+)
+
+// CreateAPIToken crea un token para uid y devuelve el valor en claro (solo
+// se muestra una vez; en BD queda el hash). Los scopes restringen lo que el
+// token puede hacer, nunca amplían los permisos del usuario.
+func CreateAPIToken(ctx context.Context, tokens store.APITokenStore, uid int64, name string, scopes []string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", errors.New("token name is required")
+	}
+	scopes, err := normalizeScopes(scopes)
+	if err != nil {
+		return "", err
+	}
+
+	raw, _, err := newToken()
+	if err != nil {
+		return "", err
+	}
+	raw = APITokenPrefix + raw
+
+	_, err = tokens.Create(ctx, models.APIToken{
+		UserID:    uid,
+		Name:      name,
+		Scopes:    scopes,
+		CreatedAt: time.Now(),
+	}, HashToken(raw))
+	if err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+// ListAPITokens devuelve los tokens del usuario (sin el valor, que no se guarda).
+func ListAPITokens(ctx context.Context, tokens store.APITokenStore, uid int64) ([]models.APIToken, error) {
+	return tokens.ListForUser(ctx, uid)
+}
+
+// RevokeAPIToken borra el token si pertenece a uid.
+func RevokeAPIToken(ctx context.Context, tokens store.APITokenStore, uid, id int64) error {
+	err := tokens.Delete(ctx, uid, id)
+	if errors.Is(err, store.ErrNotFound) {
+		return ErrNoAPIToken
+	}
+	return err
+}
+
+// UserFromAPIToken valida el token y actualiza su último uso.
+func UserFromAPIToken(ctx context.Context, tokens store.APITokenStore, raw string) (models.APIToken, error) {
+	t, err := tokens.Use(ctx, HashToken(raw), time.Now())
+	if errors.Is(err, store.ErrNotFound) {
+		return models.APIToken{}, ErrNoAPIToken
+	}
+	return t, err
+}
+
+/* =========================
+   Scopes en el contexto
+   ========================= */
+
+type ctxKeyScopes struct{}
+
+// WithScopes limita la request a esos scopes (autenticación por token personal).
+func WithScopes(ctx context.Context, scopes []string) context.Context {
+	return context.WithValue(ctx, ctxKeyScopes{}, scopes)
+}
+
+// HasScope indica si la request puede usar ese scope. Las requests con
+// sesión (cookie o token de sesión) no llevan scopes y pueden todo.
+func HasScope(ctx context.Context, scope string) bool {
+	scopes, ok := ctx.Value(ctxKeyScopes{}).([]string)
+	if !ok {
+		return true
+	}
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeScopes(in []string) ([]string, error) {
+	seen := map[string]bool{}
+	for _, s := range in {
+		s = strings.TrimSpace(strings.ToLower(s))
+		if s == "" {
+			continue
+		}
+		valid := false
+		for _, a := range AllScopes {
+			valid = valid || a == s
+		}
+		if !valid {
+			return nil, ErrInvalidScope
+		}
+		seen[s] = true
+	}
+	var out []string
+	for _, a := range AllScopes {
+		if seen[a] {
+			out = append(out, a)
+		}
+	}
+	if len(out) == 0 {
+		return nil, errors.New("pick at least one scope")
+	}
+	return out, nil
+}
@@ -0,0 +1,22 @@
+package auth
+
+import (
+	"context"
+	"testing"
+)
+
+// Sin scopes en el contexto (sesión) se puede todo; con scopes, solo esos,
+// y una lista vacía no da ninguno.
+func TestHasScope(t *testing.T) {
+	ctx := context.Background()
+	if !HasScope(ctx, ScopeAdmin) {
+		t.Error("session context lacks admin")
+	}
+	read := WithScopes(ctx, []string{ScopeRead})
+	if !HasScope(read, ScopeRead) || HasScope(read, ScopePost) || HasScope(read, ScopeAdmin) {
+		t.Error("read-only token scopes")
+	}
+	if none := WithScopes(ctx, nil); HasScope(none, ScopeRead) {
+		t.Error("token without scopes can read")
+	}
+}
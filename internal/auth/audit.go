@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"forum/internal/models"
+	"forum/internal/store"
+)
+
+/* =========================
+   Audit log
+   ========================= */
+
+// Acciones que se apuntan en audit_log.
+const (
+	AuditPostDelete    = "post.delete" // siempre, también si borra el autor
+	AuditPostEdit      = "post.edit"   // solo si edita alguien que no es el autor
+	AuditPostLock      = "post.lock"
+	AuditPostPin       = "post.pin"
+	AuditCommentDelete = "comment.delete"
+	AuditCommentEdit   = "comment.edit" // ídem
+	AuditReportClose   = "report.close"
+	AuditUserRole      = "user.role"
+	AuditUserBan       = "user.ban"
+	AuditUserSuspend   = "user.suspend"
+	AuditUserUnban     = "user.unban"
+	AuditUserMute      = "user.mute"
+	AuditUserUnmute    = "user.unmute"
+	AuditUserUnlock    = "user.unlock" // logins fallidos olvidados (ver UnlockAccount)
+)
+
+// AuditActions en el orden en que se ofrecen en el filtro de /admin/audit.
+var AuditActions = []string{
+	AuditPostDelete, AuditPostEdit, AuditPostLock, AuditPostPin,
+	AuditCommentDelete, AuditCommentEdit, AuditReportClose,
+	AuditUserRole, AuditUserBan, AuditUserSuspend, AuditUserUnban, AuditUserMute, AuditUserUnmute, AuditUserUnlock,
+}
+
+// Audit apunta una acción en el registro. actor 0 = línea de comandos;
+// before y after se guardan como JSON (nil = sin estado).
+func Audit(ctx context.Context, audit store.AuditStore, actor int64, ip, action, targetType string, targetID int64, before, after any) error {
+	e := models.AuditEntry{
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		IP:         ip,
+		CreatedAt:  time.Now(),
+	}
+	if actor != 0 {
+		e.ActorID = &actor
+	}
+	var err error
+	if e.Before, err = auditJSON(before); err != nil {
+		return err
+	}
+	if e.After, err = auditJSON(after); err != nil {
+		return err
+	}
+	_, err = audit.Append(ctx, e)
+	return err
+}
+
+func auditJSON(v any) (string, error) {
+	if v == nil {
+		return "", nil
+	}
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+// UserSanctions es el estado de sanciones de u tal como se audita.
+func UserSanctions(u models.User) map[string]any {
+	return map[string]any{
+		"banned_at":       u.BannedAt,
+		"suspended_until": u.SuspendedUntil,
+		"ban_reason":      u.BanReason,
+		"muted_until":     u.MutedUntil,
+		"mute_reason":     u.MuteReason,
+	}
+}
@@ -2,13 +2,14 @@ package auth
 
 import (
 	"context"
-	"database/sql"
 	"errors"
 	"log"
 	"strings"
 	"time"
 
-	"github.com/google/uuid"
+	"forum/internal/models"
+	"forum/internal/store"
+
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -39,157 +40,211 @@ func UserIDFrom(ctx context.Context) (int64, bool) {
 }
 
 /* =========================
-   Register (Postgres)
+   Register
    ========================= */
 
-func Register(db *sql.DB, email, username, password string) error {
+// Register crea el usuario (sin verificar) y devuelve su id.
+func Register(ctx context.Context, users store.UserStore, email, username, password string) (int64, error) {
 	email = strings.TrimSpace(strings.ToLower(email))
 	username = strings.TrimSpace(username)
 
 	if email == "" || username == "" || password == "" {
-		return errors.New("email, username and password are required")
-	}
-	if len(password) < 6 {
-		return errors.New("password must be at least 6 characters")
-	}
-
-	// Comprobación rápida para mensaje amable
-	var exists int
-	if err := db.QueryRow(`SELECT COUNT(1) FROM users WHERE email = $1`, email).Scan(&exists); err != nil {
-		return err
-	}
-	if exists > 0 {
-		return ErrEmailTaken
+		return 0, errors.New("email, username and password are required")
 	}
-	if err := db.QueryRow(`SELECT COUNT(1) FROM users WHERE username = $1`, username).Scan(&exists); err != nil {
-		return err
-	}
-	if exists > 0 {
-		return ErrUsernameTaken
+	if err := validatePassword(password); err != nil {
+		return 0, err
 	}
 
 	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
-		return err
-	}
-
-	_, err = db.Exec(`
-		INSERT INTO users (email, username, password_hash, created_at)
-		VALUES ($1, $2, $3, NOW())
-	`, email, username, string(hash))
-
-	// Por carrera con UNIQUE, mapea al error amigable
-	if isPgUniqueErr(err, "users_email_key") {
-		return ErrEmailTaken
-	}
-	if isPgUniqueErr(err, "users_username_key") {
-		return ErrUsernameTaken
-	}
-	return err
+		return 0, err
+	}
+
+	uid, err := users.Create(ctx, models.User{
+		Email:        email,
+		Username:     username,
+		PasswordHash: string(hash),
+		Role:         RoleMember,
+		CreatedAt:    time.Now(),
+	})
+	// El store mapea los UNIQUE al error amigable
+	switch {
+	case errors.Is(err, store.ErrEmailTaken):
+		return 0, ErrEmailTaken
+	case errors.Is(err, store.ErrUsernameTaken):
+		return 0, ErrUsernameTaken
+	}
+	return uid, err
 }
 
 /* =========================
-   Login (crea sesión UUID)
+   Login (crea la sesión)
    ========================= */
 
-func Login(db *sql.DB, email, password string, lifetime time.Duration) (string, int64, error) {
+// LoginOptions controla cómo se crea la sesión.
+type LoginOptions struct {
+	Lifetime        time.Duration
+	MaxLifetime     time.Duration // tope de la sesión aunque se renueve (0 = sin tope)
+	Remember        bool          // "remember me": cookie persistente en vez de de navegador
+	RequireVerified bool          // rechaza el login si el email no está verificado
+
+	// Rate limit (ver ratelimit.go): con Attempts nil no se limita nada.
+	// IP y UserAgent se guardan además en la sesión (ver sessions.go).
+	Attempts  store.LoginAttemptStore
+	IP        string
+	UserAgent string
+
+	// Segundo factor (ver totp.go): si la cuenta tiene TOTP, Login no crea la
+	// sesión sino un reto en Tokens y devuelve *NeedsSecondFactor.
+	TwoFactor store.TwoFactorStore
+	Tokens    store.TokenStore
+}
+
+// Login comprueba las credenciales y abre la sesión. Devuelve el token (para
+// la cookie o el Bearer) y la sesión guardada.
+func Login(ctx context.Context, users store.UserStore, sessions store.SessionStore, email, password string, opts LoginOptions) (string, models.Session, error) {
 	email = strings.TrimSpace(strings.ToLower(email))
+	now := time.Now()
 
-	var uid int64
-	var passwdHash string
+	// 0) Rate limit por cuenta e IP, antes de gastar un bcrypt. Cuenta también
+	// los emails que no existen, para no delatar cuáles sí.
+	failed := func() error {
+		if opts.Attempts != nil {
+			recordLoginFailure(ctx, opts.Attempts, opts.IP, email, now)
+		}
+		return ErrInvalidLogin
+	}
+	if opts.Attempts != nil {
+		if err := checkLogin(ctx, opts.Attempts, opts.IP, email, now); err != nil {
+			log.Printf("auth.Login: throttled email=%s ip=%s: %v", email, opts.IP, err)
+			return "", models.Session{}, err
+		}
+	}
 
 	// 1) Busca el usuario
-	err := db.QueryRow(`SELECT id, password_hash FROM users WHERE email = $1`, email).Scan(&uid, &passwdHash)
-	if err == sql.ErrNoRows {
+	u, err := users.ByEmail(ctx, email)
+	if errors.Is(err, store.ErrNotFound) {
 		log.Printf("auth.Login: no user for email=%s", email)
-		return "", 0, ErrInvalidLogin
+		return "", models.Session{}, failed()
 	}
 	if err != nil {
 		log.Printf("auth.Login: query user err: %v", err)
-		return "", 0, err
+		return "", models.Session{}, err
 	}
 
 	// 2) Verifica contraseña
-	if err := bcrypt.CompareHashAndPassword([]byte(passwdHash), []byte(password)); err != nil {
+	if err := bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)); err != nil {
 		log.Printf("auth.Login: bad password for email=%s", email)
-		return "", 0, ErrInvalidLogin
-	}
-
-	// 3) Crea sesión
-	tx, err := db.Begin()
+		return "", models.Session{}, failed()
+	}
+	if sn := Barred(u, now); sn != nil {
+		log.Printf("auth.Login: %v email=%s", sn, email)
+		return "", models.Session{}, sn
+	}
+	if opts.RequireVerified && u.EmailVerifiedAt == nil {
+		log.Printf("auth.Login: unverified email=%s", email)
+		return "", models.Session{}, ErrEmailNotVerified
+	}
+
+	// 3) Con TOTP falta el código; los fallos de la cuenta no se olvidan
+	// hasta entonces, o la contraseña sola reiniciaría el límite de códigos
+	if opts.TwoFactor != nil {
+		_, on, err := TwoFactorEnabled(ctx, opts.TwoFactor, u.ID)
+		if err != nil {
+			return "", models.Session{}, err
+		}
+		if on {
+			challenge, err := startChallenge(ctx, opts.Tokens, u.ID, now)
+			if err != nil {
+				return "", models.Session{}, err
+			}
+			log.Printf("auth.Login: second factor required uid=%d", u.ID)
+			return "", models.Session{}, &NeedsSecondFactor{Challenge: challenge}
+		}
+	}
+
+	// 4) Crea sesión
+	token, ses, err := startSession(ctx, sessions, opts, u, now)
 	if err != nil {
-		log.Printf("auth.Login: begin tx err: %v", err)
-		return "", 0, err
+		return "", models.Session{}, err
 	}
-	defer tx.Rollback()
+	log.Printf("auth.Login: OK email=%s uid=%d", email, u.ID)
+	return token, ses, nil
+}
 
-	if _, err := tx.Exec(`DELETE FROM sessions WHERE user_id = $1`, uid); err != nil {
-		log.Printf("auth.Login: delete old sessions err: %v", err)
-		return "", 0, err
+// startSession cierra el login: olvida los fallos de la cuenta y crea la
+// sesión. Las demás sesiones del usuario siguen vivas (ver sessions.go).
+// Devuelve el token y la sesión guardada, cuya ExpiresAt manda en la cookie.
+func startSession(ctx context.Context, sessions store.SessionStore, opts LoginOptions, u models.User, now time.Time) (string, models.Session, error) {
+	if opts.Attempts != nil {
+		if err := opts.Attempts.Clear(ctx, accountKey(u.Email)); err != nil {
+			log.Printf("auth.Login: clear failures err: %v", err)
+		}
 	}
-
-	sid := uuid.New().String()
-	exp := time.Now().Add(lifetime)
-
-	if _, err := tx.Exec(`
-		INSERT INTO sessions (id, user_id, expires_at, created_at)
-		VALUES ($1, $2, $3, NOW())
-	`, sid, uid, exp); err != nil { // ⬅️ pasa time.Time, no string
-		log.Printf("auth.Login: insert session err: %v", err)
-		return "", 0, err
+	csrf, _, err := newToken()
+	if err != nil {
+		return "", models.Session{}, err
 	}
-
-	if err := tx.Commit(); err != nil {
-		log.Printf("auth.Login: commit err: %v", err)
-		return "", 0, err
+	// La cookie lleva token; la BD, solo su SHA-256 (ver HashToken)
+	token, hash, err := newToken()
+	if err != nil {
+		return "", models.Session{}, err
+	}
+	policy := SessionPolicy{Lifetime: opts.Lifetime, MaxLifetime: opts.MaxLifetime}
+	ses := models.Session{
+		ID:         hash,
+		UserID:     u.ID,
+		CSRFToken:  csrf,
+		UserAgent:  truncateUA(opts.UserAgent),
+		IP:         opts.IP,
+		Persistent: opts.Remember,
+		Role:       u.Role,
+		LastSeenAt: now,
+		ExpiresAt:  policy.expiry(now, now),
+		CreatedAt:  now,
+	}
+	if err := sessions.Create(ctx, ses); err != nil {
+		log.Printf("auth.Login: insert session err: %v", err)
+		return "", models.Session{}, err
 	}
-
-	log.Printf("auth.Login: OK email=%s uid=%d sid=%s", email, uid, sid)
-	return sid, uid, nil
+	return token, ses, nil
 }
 
 /* =========================
    Logout
    ========================= */
 
-func Logout(db *sql.DB, sid string) error {
-	_, err := db.Exec(`DELETE FROM sessions WHERE id = $1`, sid)
-	return err
+// Logout cierra la sesión del token (el valor de la cookie o del Bearer).
+func Logout(ctx context.Context, sessions store.SessionStore, token string) error {
+	return sessions.Delete(ctx, HashToken(token))
 }
 
 /* =========================
    UserFromSession
    ========================= */
 
-func UserFromSession(db *sql.DB, sid string) (int64, time.Time, error) {
-	var uid int64
-	var exp time.Time
-
-	err := db.QueryRow(
-		`SELECT user_id, expires_at FROM sessions WHERE id = $1`,
-		sid,
-	).Scan(&uid, &exp)
-
-	if err == sql.ErrNoRows {
-		return 0, time.Time{}, ErrNoSession
+// UserFromSession busca la sesión de un token. Solo se guarda el hash del
+// token, así que ses.ID no sirve como cookie.
+func UserFromSession(ctx context.Context, sessions store.SessionStore, token string) (models.Session, error) {
+	ses, err := sessions.Get(ctx, HashToken(token))
+	if errors.Is(err, store.ErrNotFound) {
+		return models.Session{}, ErrNoSession
 	}
 	if err != nil {
-		return 0, time.Time{}, err
+		return models.Session{}, err
 	}
-	return uid, exp, nil
+	return ses, nil
 }
 
 /* =========================
    Helpers
    ========================= */
 
-// Detecta UNIQUE de Postgres por nombre de constraint en el mensaje.
-// Con UNIQUE implícitos, PG usa nombres tipo: users_email_key, users_username_key.
-func isPgUniqueErr(err error, constraint string) bool {
-	if err == nil {
-		return false
+var ErrWeakPassword = errors.New("password must be at least 6 characters")
+
+func validatePassword(password string) error {
+	if len(password) < 6 {
+		return ErrWeakPassword
 	}
-	msg := strings.ToLower(err.Error())
-	return strings.Contains(msg, "duplicate key value violates unique constraint") &&
-		strings.Contains(msg, strings.ToLower(constraint))
+	return nil
 }
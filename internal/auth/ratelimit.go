@@ -0,0 +1,154 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"forum/internal/store"
+)
+
+/* =========================
+   Rate limit de login
+   ========================= */
+
+// LoginLimit es la política para una clave (una IP o una cuenta). Los fallos
+// se cuentan en una ventana deslizante de Window: a partir de Free, cada
+// intento espera una pausa que se duplica (1s, 2s, 4s… hasta MaxDelay) desde
+// el último fallo; con Lockout fallos dentro de una ventana la clave queda
+// bloqueada LockFor desde el último.
+type LoginLimit struct {
+	Window   time.Duration
+	Free     int
+	MaxDelay time.Duration
+	Lockout  int
+	LockFor  time.Duration
+}
+
+var (
+	// Por cuenta: pocos fallos, porque es adivinar la contraseña de alguien.
+	AccountLimit = LoginLimit{Window: 15 * time.Minute, Free: 3, MaxDelay: time.Minute, Lockout: 10, LockFor: 15 * time.Minute}
+	// Por IP: más margen (NAT, oficinas), pero frena el barrido de cuentas.
+	IPLimit = LoginLimit{Window: 15 * time.Minute, Free: 10, MaxDelay: time.Minute, Lockout: 50, LockFor: 30 * time.Minute}
+)
+
+// Throttled es el error de Login cuando hay que esperar hasta Until.
+type Throttled struct {
+	Until  time.Time
+	Locked bool // bloqueo por demasiados fallos, no solo una pausa
+}
+
+func (t *Throttled) Error() string {
+	if t.Locked {
+		return "too many failed logins, locked until " + t.Until.UTC().Format(time.RFC3339)
+	}
+	return "too many failed logins, retry after " + t.Until.UTC().Format(time.RFC3339)
+}
+
+// RetryAfter son los segundos que faltan (al menos 1), para la cabecera Retry-After.
+func (t *Throttled) RetryAfter(now time.Time) int {
+	return max(1, int(t.Until.Sub(now).Seconds()+0.999))
+}
+
+// Message explica la espera a la persona que intenta entrar.
+func (t *Throttled) Message(now time.Time) string {
+	if t.Locked {
+		return fmt.Sprintf("Too many failed sign-in attempts. Sign-in is locked until %s; an administrator can unlock it sooner.",
+			t.Until.Local().Format("15:04"))
+	}
+	return fmt.Sprintf("Too many failed sign-in attempts. Please wait %d seconds and try again.", t.RetryAfter(now))
+}
+
+func accountKey(email string) string { return "account:" + strings.TrimSpace(strings.ToLower(email)) }
+func ipKey(ip string) string         { return "ip:" + ip }
+
+// throttle aplica lim a key en now; nil si se puede intentar ya.
+func throttle(ctx context.Context, attempts store.LoginAttemptStore, key string, lim LoginLimit, now time.Time) (*Throttled, error) {
+	fails, err := attempts.Recent(ctx, key, now.Add(-lim.Window-lim.LockFor), lim.Lockout)
+	if err != nil || len(fails) == 0 {
+		return nil, err
+	}
+	last := fails[0]
+
+	// Bloqueo: Lockout fallos en la ventana que acaba en el último
+	if len(fails) >= lim.Lockout && !fails[lim.Lockout-1].Before(last.Add(-lim.Window)) {
+		if until := last.Add(lim.LockFor); now.Before(until) {
+			return &Throttled{Until: until, Locked: true}, nil
+		}
+	}
+
+	// Pausa: fallos en la ventana que acaba ahora
+	n := 0
+	for _, f := range fails {
+		if !f.Before(now.Add(-lim.Window)) {
+			n++
+		}
+	}
+	if n < lim.Free {
+		return nil, nil
+	}
+	delay := lim.MaxDelay
+	if shift := n - lim.Free; shift < 30 {
+		delay = min(time.Second<<shift, lim.MaxDelay)
+	}
+	if until := last.Add(delay); now.Before(until) {
+		return &Throttled{Until: until}, nil
+	}
+	return nil, nil
+}
+
+// checkLogin comprueba la IP y la cuenta; si las dos tienen que esperar
+// devuelve la espera más larga.
+func checkLogin(ctx context.Context, attempts store.LoginAttemptStore, ip, email string, now time.Time) error {
+	var worst *Throttled
+	for _, c := range []struct {
+		key string
+		lim LoginLimit
+	}{{accountKey(email), AccountLimit}, {ipKey(ip), IPLimit}} {
+		if c.key == ipKey("") {
+			continue
+		}
+		t, err := throttle(ctx, attempts, c.key, c.lim, now)
+		if err != nil {
+			return err
+		}
+		if t != nil && (worst == nil || t.Until.After(worst.Until)) {
+			worst = t
+		}
+	}
+	if worst != nil {
+		return worst
+	}
+	return nil
+}
+
+// recordLoginFailure apunta el fallo para la cuenta y la IP y, de paso,
+// borra los fallos que ya no cuentan para nadie.
+func recordLoginFailure(ctx context.Context, attempts store.LoginAttemptStore, ip, email string, now time.Time) {
+	keys := []string{accountKey(email)}
+	if ip != "" {
+		keys = append(keys, ipKey(ip))
+	}
+	for _, k := range keys {
+		if err := attempts.Fail(ctx, k, now); err != nil {
+			log.Printf("auth.Login: record failure err: %v", err)
+		}
+	}
+	horizon := max(AccountLimit.Window+AccountLimit.LockFor, IPLimit.Window+IPLimit.LockFor)
+	if err := attempts.Prune(ctx, now.Add(-horizon)); err != nil {
+		log.Printf("auth.Login: prune failures err: %v", err)
+	}
+}
+
+// AccountThrottle devuelve la pausa o el bloqueo vigente de la cuenta, o nil.
+func AccountThrottle(ctx context.Context, attempts store.LoginAttemptStore, email string, now time.Time) (*Throttled, error) {
+	return throttle(ctx, attempts, accountKey(email), AccountLimit, now)
+}
+
+// UnlockAccount olvida los fallos de la cuenta (acción de admin). Los de la
+// IP siguen contando.
+func UnlockAccount(ctx context.Context, attempts store.LoginAttemptStore, email string) error {
+	return attempts.Clear(ctx, accountKey(email))
+}
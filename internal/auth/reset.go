@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"forum/internal/store"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+/* =========================
+   Password reset
+   ========================= */
+
+// CreatePasswordReset genera un token de un solo uso para el usuario con ese email.
+// Solo se guarda el hash SHA-256 del token. Si el email no existe devuelve ("", nil)
+// para que el llamador no pueda distinguir ambos casos.
+func CreatePasswordReset(ctx context.Context, users store.UserStore, tokens store.TokenStore, email string, ttl time.Duration) (string, error) {
+	email = strings.TrimSpace(strings.ToLower(email))
+	if email == "" {
+		return "", nil
+	}
+
+	u, err := users.ByEmail(ctx, email)
+	if errors.Is(err, store.ErrNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	raw, hash, err := newToken()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	if err := tokens.Create(ctx, store.TokenPasswordReset, u.ID, hash, now.Add(ttl), now); err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+// ResetPassword consume el token, cambia la contraseña e invalida todas las
+// sesiones y tokens personales del usuario (y cualquier otro token de reseteo
+// pendiente), en una sola transacción: si falla, el enlace sigue sirviendo
+// para reintentarlo.
+func ResetPassword(ctx context.Context, tokens store.TokenStore, token, newPassword string) error {
+	if token == "" {
+		return ErrInvalidToken
+	}
+	if err := validatePassword(newPassword); err != nil {
+		return err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	// Atómico también frente a dos envíos en paralelo del mismo enlace
+	_, err = tokens.ConsumeAndReset(ctx, HashToken(token), string(hash), time.Now())
+	if errors.Is(err, store.ErrNotFound) {
+		return ErrInvalidToken
+	}
+	return err
+}
+
+/* =========================
+   Tokens
+   ========================= */
+
+// newToken devuelve un token aleatorio (para el usuario) y su hash (para la BD).
+func newToken() (raw, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	raw = base64.RawURLEncoding.EncodeToString(b)
+	return raw, HashToken(raw), nil
+}
+
+// HashToken es el digest que se guarda y se busca en la BD.
+func HashToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"forum/internal/store"
+)
+
+/* =========================
+   Roles
+   ========================= */
+
+const (
+	RoleMember    = "member"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// Roles de menos a más permisos; cada uno puede lo mismo que los anteriores.
+var AllRoles = []string{RoleMember, RoleModerator, RoleAdmin}
+
+var ErrInvalidRole = errors.New("role must be member, moderator or admin")
+
+// UserRole devuelve el rol guardado en users.role.
+func UserRole(ctx context.Context, users store.UserStore, uid int64) (string, error) {
+	u, err := users.ByID(ctx, uid)
+	return u.Role, err
+}
+
+// HasRole indica si role llega al menos a min (admin > moderator > member).
+// Un rol desconocido no llega a nada.
+func HasRole(role, min string) bool {
+	r, m := slices.Index(AllRoles, role), slices.Index(AllRoles, min)
+	return r >= 0 && m >= 0 && r >= m
+}
+
+// CanModerate indica si el rol puede editar/borrar contenido ajeno, cerrar y fijar hilos.
+func CanModerate(role string) bool {
+	return HasRole(role, RoleModerator)
+}
+
+// SetUserRole cambia el rol de un usuario por su nombre.
+func SetUserRole(ctx context.Context, users store.UserStore, username, role string) error {
+	if !slices.Contains(AllRoles, role) {
+		return ErrInvalidRole
+	}
+	u, err := users.ByUsername(ctx, username)
+	if err != nil {
+		return err
+	}
+	return users.SetRole(ctx, u.ID, role)
+}
@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"forum/internal/models"
+	"forum/internal/store"
+)
+
+/* =========================
+   Sanciones
+   ========================= */
+
+// Tipos de sanción, de más a menos grave.
+const (
+	SanctionBan        = "banned"    // permanente, sin acceso
+	SanctionSuspension = "suspended" // sin acceso hasta Until
+	SanctionMute       = "muted"     // puede leer pero no escribir hasta Until
+)
+
+// Sanction es una sanción vigente. Es también un error: Login la devuelve
+// si la cuenta no puede entrar, y CheckWrite si no puede escribir.
+type Sanction struct {
+	Kind   string
+	Reason string
+	Until  *time.Time // nil = permanente
+}
+
+func (s *Sanction) Error() string {
+	if s.Until == nil {
+		return "account " + s.Kind
+	}
+	return "account " + s.Kind + " until " + s.Until.UTC().Format(time.RFC3339)
+}
+
+// Barred devuelve el baneo o la suspensión vigente en now, o nil si la
+// cuenta puede entrar.
+func Barred(u models.User, now time.Time) *Sanction {
+	if u.BannedAt != nil {
+		return &Sanction{Kind: SanctionBan, Reason: u.BanReason}
+	}
+	if u.SuspendedUntil != nil && u.SuspendedUntil.After(now) {
+		until := *u.SuspendedUntil
+		return &Sanction{Kind: SanctionSuspension, Reason: u.BanReason, Until: &until}
+	}
+	return nil
+}
+
+// Silenced devuelve lo que impide escribir en now (un baneo o suspensión, o
+// el silencio), o nil.
+func Silenced(u models.User, now time.Time) *Sanction {
+	if s := Barred(u, now); s != nil {
+		return s
+	}
+	if u.MutedUntil != nil && u.MutedUntil.After(now) {
+		until := *u.MutedUntil
+		return &Sanction{Kind: SanctionMute, Reason: u.MuteReason, Until: &until}
+	}
+	return nil
+}
+
+// CheckWrite devuelve la *Sanction que impide escribir a uid, o nil.
+func CheckWrite(ctx context.Context, users store.UserStore, uid int64) error {
+	u, err := users.ByID(ctx, uid)
+	if err != nil {
+		return err
+	}
+	if s := Silenced(u, time.Now()); s != nil {
+		return s
+	}
+	return nil
+}
+
+// Outranks indica si actor puede sancionar a alguien con el rol target:
+// hace falta un rol estrictamente mayor (un moderador no sanciona a otro).
+func Outranks(actor, target string) bool {
+	return slices.Index(AllRoles, actor) > slices.Index(AllRoles, target)
+}
+
+// Ban cierra la cuenta para siempre. Las sesiones abiertas se matan la
+// próxima vez que se usan (ver Barred), para poder explicar el motivo.
+func Ban(ctx context.Context, users store.UserStore, uid int64, reason string) error {
+	now := time.Now()
+	return users.SetBan(ctx, uid, &now, nil, reason)
+}
+
+// Suspend deja la cuenta sin acceso hasta until (sesiones: como en Ban).
+func Suspend(ctx context.Context, users store.UserStore, uid int64, until time.Time, reason string) error {
+	return users.SetBan(ctx, uid, nil, &until, reason)
+}
+
+// Unban levanta el baneo o la suspensión.
+func Unban(ctx context.Context, users store.UserStore, uid int64) error {
+	return users.SetBan(ctx, uid, nil, nil, "")
+}
+
+// Mute deja la cuenta en solo lectura hasta until; la sesión sigue viva.
+func Mute(ctx context.Context, users store.UserStore, uid int64, until time.Time, reason string) error {
+	return users.SetMute(ctx, uid, &until, reason)
+}
+
+func Unmute(ctx context.Context, users store.UserStore, uid int64) error {
+	return users.SetMute(ctx, uid, nil, "")
+}
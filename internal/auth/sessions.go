@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"forum/internal/models"
+	"forum/internal/store"
+)
+
+/* =========================
+   Sesiones por dispositivo
+   ========================= */
+
+// TouchEvery es cada cuánto se apunta como mucho la actividad de una sesión;
+// escribir en cada request sería una UPDATE por página vista.
+const TouchEvery = time.Minute
+
+// maxUserAgent recorta User-Agent absurdamente largos antes de guardarlos.
+const maxUserAgent = 512
+
+func truncateUA(ua string) string {
+	if len(ua) > maxUserAgent {
+		return ua[:maxUserAgent]
+	}
+	return ua
+}
+
+// SessionHandle identifica una sesión (por su id guardado) en la página de
+// dispositivos y en los logs, sin exponer el id.
+func SessionHandle(id string) string {
+	return HashToken(id)[:16]
+}
+
+// ListSessions devuelve las sesiones vigentes de uid, la más reciente primero.
+func ListSessions(ctx context.Context, sessions store.SessionStore, uid int64) ([]models.Session, error) {
+	return sessions.ListForUser(ctx, uid, time.Now())
+}
+
+// TouchSession apunta la actividad de ses si hace más de TouchEvery de la
+// última o si llega desde otra IP.
+func TouchSession(ctx context.Context, sessions store.SessionStore, ses models.Session, ip string, now time.Time) error {
+	if ip == ses.IP && now.Sub(ses.LastSeenAt) < TouchEvery {
+		return nil
+	}
+	err := sessions.Touch(ctx, ses.ID, ip, now)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil // cerrada mientras tanto
+	}
+	return err
+}
+
+// RevokeSession cierra la sesión de uid con ese handle. ErrNoSession si no
+// hay ninguna (o es de otro usuario).
+func RevokeSession(ctx context.Context, sessions store.SessionStore, uid int64, handle string) error {
+	list, err := ListSessions(ctx, sessions, uid)
+	if err != nil {
+		return err
+	}
+	for _, ses := range list {
+		if SessionHandle(ses.ID) == handle {
+			return sessions.Delete(ctx, ses.ID)
+		}
+	}
+	return ErrNoSession
+}
+
+// SignOutOthers cierra todas las sesiones de uid menos keep.
+func SignOutOthers(ctx context.Context, sessions store.SessionStore, uid int64, keep string) error {
+	return sessions.DeleteOthers(ctx, uid, keep)
+}
+
+/* =========================
+   Renovación y rotación
+   ========================= */
+
+// SessionPolicy decide cuánto vive una sesión.
+type SessionPolicy struct {
+	Lifetime    time.Duration // validez desde la última renovación
+	RenewAfter  float64       // fracción de Lifetime tras la que se renueva (0 = nunca)
+	MaxLifetime time.Duration // tope desde el login, se renueve o no (0 = sin tope)
+}
+
+// expiry es la caducidad de una sesión abierta en created y renovada en now.
+func (p SessionPolicy) expiry(created, now time.Time) time.Time {
+	exp := now.Add(p.Lifetime)
+	if p.MaxLifetime > 0 {
+		if limit := created.Add(p.MaxLifetime); exp.After(limit) {
+			exp = limit
+		}
+	}
+	return exp
+}
+
+// RenewSession alarga ses (expiración deslizante) si desde su última
+// renovación ya pasó la fracción RenewAfter de Lifetime, sin pasar de
+// MaxLifetime. Devuelve la sesión y si cambió, para reenviar la cookie.
+func RenewSession(ctx context.Context, sessions store.SessionStore, ses models.Session, p SessionPolicy, now time.Time) (models.Session, bool, error) {
+	if p.RenewAfter <= 0 || p.Lifetime <= 0 {
+		return ses, false, nil
+	}
+	renewed := ses.ExpiresAt.Add(-p.Lifetime)
+	if now.Sub(renewed) < time.Duration(p.RenewAfter*float64(p.Lifetime)) {
+		return ses, false, nil
+	}
+	exp := p.expiry(ses.CreatedAt, now)
+	if !exp.After(ses.ExpiresAt) {
+		return ses, false, nil // ya en el tope
+	}
+	if err := sessions.Renew(ctx, ses.ID, exp); errors.Is(err, store.ErrNotFound) {
+		return ses, false, ErrNoSession
+	} else if err != nil {
+		return ses, false, err
+	}
+	ses.ExpiresAt = exp
+	return ses, true, nil
+}
+
+// RotateSession da a ses un token nuevo y borra el viejo; se usa cuando
+// cambian los privilegios de la cuenta (rol, 2FA, contraseña) para que un
+// token robado o fijado antes no los herede. Conserva el token CSRF, el
+// dispositivo y la hora del login (y con ella el tope de MaxLifetime).
+// Devuelve la sesión y el token para la cookie.
+func RotateSession(ctx context.Context, sessions store.SessionStore, ses models.Session, role string, now time.Time) (models.Session, string, error) {
+	token, hash, err := newToken()
+	if err != nil {
+		return models.Session{}, "", err
+	}
+	old := ses.ID
+	ses.ID = hash
+	ses.Role = role
+	ses.LastSeenAt = now
+	if err := sessions.Rotate(ctx, old, ses); errors.Is(err, store.ErrNotFound) {
+		return models.Session{}, "", ErrNoSession
+	} else if err != nil {
+		return models.Session{}, "", err
+	}
+	return ses, token, nil
+}
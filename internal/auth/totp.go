@@ -0,0 +1,360 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"forum/internal/models"
+	"forum/internal/store"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+/* =========================
+   TOTP (RFC 6238)
+   ========================= */
+
+const (
+	TOTPIssuer = "Forum" // nombre que enseña la app de autenticación
+
+	totpPeriod        = 30 // segundos por paso
+	totpDigits        = 6
+	totpSkew          = 1 // pasos de margen por relojes desajustados
+	recoveryCodeCount = 10
+	challengeTTL      = 5 * time.Minute // para escribir el código tras la contraseña
+)
+
+var (
+	ErrInvalidCode      = errors.New("invalid authentication code")
+	ErrWrongPassword    = errors.New("current password is incorrect")
+	ErrTwoFactorEnabled = errors.New("two-factor authentication is already on")
+	ErrTwoFactorOff     = errors.New("two-factor authentication is not on")
+)
+
+// NeedsSecondFactor lo devuelve Login cuando la contraseña es correcta pero
+// la cuenta tiene TOTP: la sesión la crea LoginSecondFactor con Challenge.
+type NeedsSecondFactor struct {
+	Challenge string
+}
+
+func (e *NeedsSecondFactor) Error() string { return "second factor required" }
+
+var b32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewTOTPSecret devuelve 160 bits aleatorios en base32, como piden las apps.
+func NewTOTPSecret() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return b32.EncodeToString(b), nil
+}
+
+// TOTPCode es el código de secret en t.
+func TOTPCode(secret string, t time.Time) (string, error) {
+	key, err := b32.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", err
+	}
+	return hotp(key, totpStep(t)), nil
+}
+
+func totpStep(t time.Time) int64 { return t.Unix() / totpPeriod }
+
+// hotp es RFC 4226 con HMAC-SHA1 y truncado dinámico.
+func hotp(key []byte, counter int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(counter))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	off := sum[len(sum)-1] & 0x0f
+	n := binary.BigEndian.Uint32(sum[off:off+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, n%1_000_000)
+}
+
+// matchTOTP busca code en los pasos alrededor de now y devuelve el que encaja.
+func matchTOTP(secret, code string, now time.Time) (int64, bool) {
+	key, err := b32.DecodeString(strings.ToUpper(secret))
+	if err != nil || len(code) != totpDigits {
+		return 0, false
+	}
+	cur := totpStep(now)
+	for d := int64(-totpSkew); d <= totpSkew; d++ {
+		if subtle.ConstantTimeCompare([]byte(hotp(key, cur+d)), []byte(code)) == 1 {
+			return cur + d, true
+		}
+	}
+	return 0, false
+}
+
+// TOTPURI es la URI otpauth:// que se codifica en el QR de alta.
+func TOTPURI(account, secret string) string {
+	label := url.PathEscape(TOTPIssuer + ":" + account)
+	q := url.Values{}
+	q.Set("secret", secret)
+	q.Set("issuer", TOTPIssuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", fmt.Sprint(totpDigits))
+	q.Set("period", fmt.Sprint(totpPeriod))
+	return "otpauth://totp/" + label + "?" + q.Encode()
+}
+
+/* =========================
+   Códigos de recuperación
+   ========================= */
+
+// Sin 0/o, 1/l/i: se copian a mano.
+const recoveryAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+
+// newRecoveryCodes devuelve los códigos (para enseñarlos una vez) y sus hashes.
+func newRecoveryCodes() (codes, hashes []string, err error) {
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 10)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		for j := range b {
+			b[j] = recoveryAlphabet[int(b[j])%len(recoveryAlphabet)]
+		}
+		code := string(b[:5]) + "-" + string(b[5:])
+		codes = append(codes, code)
+		hashes = append(hashes, HashToken(normalizeCode(code)))
+	}
+	return codes, hashes, nil
+}
+
+// normalizeCode quita espacios y guiones y pasa a minúsculas.
+func normalizeCode(code string) string {
+	return strings.ToLower(strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimSpace(code)))
+}
+
+/* =========================
+   Alta, baja y códigos
+   ========================= */
+
+// Enrollment es lo que necesita la app de autenticación para el alta.
+type Enrollment struct {
+	Secret string
+	URI    string
+}
+
+// BeginTOTP genera un secreto nuevo sin activar; se activa con EnableTOTP.
+func BeginTOTP(ctx context.Context, users store.UserStore, tfs store.TwoFactorStore, uid int64) (Enrollment, error) {
+	u, err := users.ByID(ctx, uid)
+	if err != nil {
+		return Enrollment{}, err
+	}
+	secret, err := NewTOTPSecret()
+	if err != nil {
+		return Enrollment{}, err
+	}
+	err = tfs.Begin(ctx, uid, secret, time.Now())
+	if errors.Is(err, store.ErrNotFound) {
+		return Enrollment{}, ErrTwoFactorEnabled
+	}
+	if err != nil {
+		return Enrollment{}, err
+	}
+	return Enrollment{Secret: secret, URI: TOTPURI(u.Email, secret)}, nil
+}
+
+// PendingTOTP devuelve el alta sin confirmar, si la hay.
+func PendingTOTP(ctx context.Context, users store.UserStore, tfs store.TwoFactorStore, uid int64) (*Enrollment, error) {
+	tf, err := tfs.Get(ctx, uid)
+	if errors.Is(err, store.ErrNotFound) || (err == nil && tf.EnabledAt != nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	u, err := users.ByID(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	return &Enrollment{Secret: tf.Secret, URI: TOTPURI(u.Email, tf.Secret)}, nil
+}
+
+// EnableTOTP confirma el alta con un código de la app y devuelve los códigos
+// de recuperación, que solo se pueden enseñar ahora.
+func EnableTOTP(ctx context.Context, tfs store.TwoFactorStore, uid int64, code string) ([]string, error) {
+	tf, err := tfs.Get(ctx, uid)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, ErrTwoFactorOff
+	}
+	if err != nil {
+		return nil, err
+	}
+	if tf.EnabledAt != nil {
+		return nil, ErrTwoFactorEnabled
+	}
+	now := time.Now()
+	step, ok := matchTOTP(tf.Secret, normalizeCode(code), now)
+	if !ok {
+		return nil, ErrInvalidCode
+	}
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := tfs.Enable(ctx, uid, step, hashes, now); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// DisableTOTP quita el segundo factor tras comprobar la contraseña.
+func DisableTOTP(ctx context.Context, users store.UserStore, tfs store.TwoFactorStore, uid int64, password string) error {
+	if err := checkPassword(ctx, users, uid, password); err != nil {
+		return err
+	}
+	err := tfs.Disable(ctx, uid)
+	if errors.Is(err, store.ErrNotFound) {
+		return ErrTwoFactorOff
+	}
+	return err
+}
+
+// RegenerateRecoveryCodes sustituye los códigos tras comprobar la contraseña.
+func RegenerateRecoveryCodes(ctx context.Context, users store.UserStore, tfs store.TwoFactorStore, uid int64, password string) ([]string, error) {
+	if err := checkPassword(ctx, users, uid, password); err != nil {
+		return nil, err
+	}
+	tf, err := tfs.Get(ctx, uid)
+	if errors.Is(err, store.ErrNotFound) || (err == nil && tf.EnabledAt == nil) {
+		return nil, ErrTwoFactorOff
+	}
+	if err != nil {
+		return nil, err
+	}
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := tfs.ReplaceRecoveryCodes(ctx, uid, hashes, time.Now()); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// TwoFactorEnabled indica si la cuenta pide código al entrar.
+func TwoFactorEnabled(ctx context.Context, tfs store.TwoFactorStore, uid int64) (models.TwoFactor, bool, error) {
+	tf, err := tfs.Get(ctx, uid)
+	if errors.Is(err, store.ErrNotFound) {
+		return models.TwoFactor{}, false, nil
+	}
+	return tf, err == nil && tf.EnabledAt != nil, err
+}
+
+func checkPassword(ctx context.Context, users store.UserStore, uid int64, password string) error {
+	u, err := users.ByID(ctx, uid)
+	if err != nil {
+		return err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)) != nil {
+		return ErrWrongPassword
+	}
+	return nil
+}
+
+/* =========================
+   Login: segundo paso
+   ========================= */
+
+// startChallenge guarda el login a medias y devuelve el token para el segundo paso.
+func startChallenge(ctx context.Context, tokens store.TokenStore, uid int64, now time.Time) (string, error) {
+	raw, hash, err := newToken()
+	if err != nil {
+		return "", err
+	}
+	if err := tokens.Create(ctx, store.TokenLoginChallenge, uid, hash, now.Add(challengeTTL), now); err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+// LoginSecondFactor termina un login que devolvió *NeedsSecondFactor. code es
+// un código TOTP o uno de recuperación (que se gasta). Un código incorrecto
+// cuenta como login fallido para el rate limit y deja el reto vivo para
+// reintentar; ErrInvalidToken si el reto caducó o ya se usó.
+func LoginSecondFactor(ctx context.Context, users store.UserStore, sessions store.SessionStore, tokens store.TokenStore, tfs store.TwoFactorStore, challenge, code string, opts LoginOptions) (string, models.Session, error) {
+	now := time.Now()
+	hash := HashToken(challenge)
+	uid, err := tokens.Lookup(ctx, store.TokenLoginChallenge, hash, now)
+	if errors.Is(err, store.ErrNotFound) {
+		return "", models.Session{}, ErrInvalidToken
+	}
+	if err != nil {
+		return "", models.Session{}, err
+	}
+	u, err := users.ByID(ctx, uid)
+	if err != nil {
+		return "", models.Session{}, err
+	}
+	if opts.Attempts != nil {
+		if err := checkLogin(ctx, opts.Attempts, opts.IP, u.Email, now); err != nil {
+			log.Printf("auth.LoginSecondFactor: throttled uid=%d ip=%s: %v", uid, opts.IP, err)
+			return "", models.Session{}, err
+		}
+	}
+	// Pudo ser sancionado entre los dos pasos
+	if sn := Barred(u, now); sn != nil {
+		return "", models.Session{}, sn
+	}
+
+	if err := useSecondFactor(ctx, tfs, uid, normalizeCode(code), now); err != nil {
+		if errors.Is(err, ErrInvalidCode) && opts.Attempts != nil {
+			recordLoginFailure(ctx, opts.Attempts, opts.IP, u.Email, now)
+		}
+		log.Printf("auth.LoginSecondFactor: uid=%d err=%v", uid, err)
+		return "", models.Session{}, err
+	}
+	// El reto vale una vez: si otro lo gastó a la vez, este pierde
+	if _, err := tokens.Consume(ctx, store.TokenLoginChallenge, hash, now); errors.Is(err, store.ErrNotFound) {
+		return "", models.Session{}, ErrInvalidToken
+	} else if err != nil {
+		return "", models.Session{}, err
+	}
+
+	token, ses, err := startSession(ctx, sessions, opts, u, now)
+	if err != nil {
+		return "", models.Session{}, err
+	}
+	log.Printf("auth.LoginSecondFactor: OK uid=%d", uid)
+	return token, ses, nil
+}
+
+// useSecondFactor acepta un TOTP (una vez por paso) o un código de recuperación.
+func useSecondFactor(ctx context.Context, tfs store.TwoFactorStore, uid int64, code string, now time.Time) error {
+	tf, enabled, err := TwoFactorEnabled(ctx, tfs, uid)
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		return ErrTwoFactorOff
+	}
+	if len(code) == totpDigits {
+		step, ok := matchTOTP(tf.Secret, code, now)
+		if !ok {
+			return ErrInvalidCode
+		}
+		err = tfs.UseStep(ctx, uid, step)
+	} else {
+		err = tfs.UseRecoveryCode(ctx, uid, HashToken(code), now)
+	}
+	if errors.Is(err, store.ErrNotFound) {
+		return ErrInvalidCode // código ya usado
+	}
+	return err
+}
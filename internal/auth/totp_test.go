@@ -0,0 +1,43 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// Vectores del apéndice B de RFC 6238 (SHA-1), con los 6 últimos dígitos.
+func TestTOTPCode(t *testing.T) {
+	secret := b32.EncodeToString([]byte("12345678901234567890"))
+	for unix, want := range map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1234567890:  "005924",
+		20000000000: "353130",
+	} {
+		got, err := TOTPCode(secret, time.Unix(unix, 0))
+		if err != nil || got != want {
+			t.Errorf("TOTPCode(%d) = %q, %v; want %q", unix, got, err, want)
+		}
+	}
+
+	// Un paso de margen a cada lado, no más
+	now := time.Unix(1234567890, 0)
+	for d, ok := range map[time.Duration]bool{-30 * time.Second: true, 30 * time.Second: true, 90 * time.Second: false} {
+		code, _ := TOTPCode(secret, now.Add(d))
+		if _, got := matchTOTP(secret, code, now); got != ok {
+			t.Errorf("matchTOTP(now%+v) = %v, want %v", d, got, ok)
+		}
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil || len(codes) != recoveryCodeCount || len(hashes) != recoveryCodeCount {
+		t.Fatalf("newRecoveryCodes = %d codes, %v", len(codes), err)
+	}
+	// Se aceptan con espacios, sin guion o en mayúsculas
+	if HashToken(normalizeCode(" "+strings.ToUpper(strings.Replace(codes[0], "-", "", 1))+" ")) != hashes[0] {
+		t.Fatal("normalized code doesn't match its hash")
+	}
+}
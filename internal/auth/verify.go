@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"forum/internal/store"
+)
+
+var ErrEmailNotVerified = errors.New("email address not verified")
+
+/* =========================
+   Email verification
+   ========================= */
+
+// CreateEmailVerification genera un token de verificación para el usuario y
+// devuelve el token en claro junto al email al que hay que enviarlo.
+// Si el email ya está verificado devuelve ("", "", nil).
+func CreateEmailVerification(ctx context.Context, users store.UserStore, tokens store.TokenStore, uid int64, ttl time.Duration) (string, string, error) {
+	u, err := users.ByID(ctx, uid)
+	if err != nil {
+		return "", "", err
+	}
+	if u.EmailVerifiedAt != nil {
+		return "", "", nil
+	}
+
+	raw, hash, err := newToken()
+	if err != nil {
+		return "", "", err
+	}
+	now := time.Now()
+	if err := tokens.Create(ctx, store.TokenEmailVerify, uid, hash, now.Add(ttl), now); err != nil {
+		return "", "", err
+	}
+	return raw, u.Email, nil
+}
+
+// UserIDByEmail busca un usuario por email (para reenviar la verificación sin sesión).
+func UserIDByEmail(ctx context.Context, users store.UserStore, email string) (int64, error) {
+	u, err := users.ByEmail(ctx, strings.TrimSpace(strings.ToLower(email)))
+	return u.ID, err
+}
+
+// VerifyEmail consume el token y marca el email del usuario como verificado.
+func VerifyEmail(ctx context.Context, users store.UserStore, tokens store.TokenStore, token string) (int64, error) {
+	if token == "" {
+		return 0, ErrInvalidToken
+	}
+	now := time.Now()
+	uid, err := tokens.Consume(ctx, store.TokenEmailVerify, HashToken(token), now)
+	if errors.Is(err, store.ErrNotFound) {
+		return 0, ErrInvalidToken
+	}
+	if err != nil {
+		return 0, err
+	}
+	return uid, users.MarkEmailVerified(ctx, uid, now)
+}
+
+// IsEmailVerified indica si el usuario ya confirmó su dirección.
+func IsEmailVerified(ctx context.Context, users store.UserStore, uid int64) (bool, error) {
+	u, err := users.ByID(ctx, uid)
+	if err != nil {
+		return false, err
+	}
+	return u.EmailVerifiedAt != nil, nil
+}
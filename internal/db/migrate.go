@@ -1,13 +1,242 @@
 package db
 
 import (
+	"context"
 	"database/sql"
-	"os"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
 )
 
-func Migrate(db *sql.DB, schemaPath string) error {
-	b, err := os.ReadFile(schemaPath)
-	if err != nil { return err }
-	_, err = db.Exec(string(b))
-	return err
+// Las migraciones van embebidas en el binario, un directorio por dialecto:
+// migrations/<dialecto>/NNNN_nombre.up.sql y NNNN_nombre.down.sql. Ambos
+// dialectos tienen las mismas versiones. Nunca se edita una migración ya
+// publicada; para cambiar el esquema se añade otra con el siguiente número.
+//
+//go:embed migrations/postgres/*.sql migrations/sqlite/*.sql
+var migrationFiles embed.FS
+
+// Migration es un paso del esquema con su vuelta atrás.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus es una migración conocida y, si se aplicó, cuándo.
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// migrationLockID es la clave de pg_advisory_lock: dos instancias que
+// arrancan a la vez no pueden aplicar migraciones en paralelo.
+const migrationLockID = 7_291_004_311
+
+var migrationName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Migrations devuelve las migraciones embebidas del dialecto ordenadas por versión.
+func Migrations(dialect Dialect) ([]Migration, error) {
+	return loadMigrations(migrationFiles, path.Join("migrations", string(dialect)))
+}
+
+func loadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int64]*Migration{}
+	for _, e := range entries {
+		m := migrationName.FindStringSubmatch(e.Name())
+		if m == nil {
+			return nil, fmt.Errorf("migrations: unexpected file %q", e.Name())
+		}
+		v, _ := strconv.ParseInt(m[1], 10, 64)
+		b, err := fs.ReadFile(fsys, path.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		mig := byVersion[v]
+		if mig == nil {
+			mig = &Migration{Version: v, Name: m[2]}
+			byVersion[v] = mig
+		}
+		if mig.Name != m[2] {
+			return nil, fmt.Errorf("migrations: version %d has two names (%s, %s)", v, mig.Name, m[2])
+		}
+		if m[3] == "up" {
+			mig.Up = string(b)
+		} else {
+			mig.Down = string(b)
+		}
+	}
+
+	out := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migrations: %04d_%s needs both up and down files", m.Version, m.Name)
+		}
+		out = append(out, *m)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	for i, m := range out {
+		if m.Version != int64(i+1) {
+			return nil, fmt.Errorf("migrations: expected version %d, found %d", i+1, m.Version)
+		}
+	}
+	return out, nil
+}
+
+/* =========================
+   Runner
+   ========================= */
+
+// MigrateUp aplica las migraciones pendientes y devuelve las aplicadas.
+func MigrateUp(ctx context.Context, db *sql.DB) ([]Migration, error) {
+	all, err := Migrations(DialectOf(db))
+	if err != nil {
+		return nil, err
+	}
+	var done []Migration
+	err = withMigrationLock(ctx, db, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, m := range all {
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+			if err := runMigration(ctx, conn, m, m.Up, `INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, $3)`, m.Version, m.Name, time.Now()); err != nil {
+				return err
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// MigrateDown deshace las últimas n migraciones aplicadas (la más nueva primero).
+func MigrateDown(ctx context.Context, db *sql.DB, n int) ([]Migration, error) {
+	all, err := Migrations(DialectOf(db))
+	if err != nil {
+		return nil, err
+	}
+	var done []Migration
+	err = withMigrationLock(ctx, db, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(all) - 1; i >= 0 && len(done) < n; i-- {
+			m := all[i]
+			if _, ok := applied[m.Version]; !ok {
+				continue
+			}
+			if err := runMigration(ctx, conn, m, m.Down, `DELETE FROM schema_migrations WHERE version = $1`, m.Version); err != nil {
+				return err
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// MigrationsStatus lista todas las migraciones conocidas y cuáles están aplicadas.
+func MigrationsStatus(ctx context.Context, db *sql.DB) ([]MigrationStatus, error) {
+	all, err := Migrations(DialectOf(db))
+	if err != nil {
+		return nil, err
+	}
+	var out []MigrationStatus
+	err = withMigrationLock(ctx, db, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, m := range all {
+			st := MigrationStatus{Migration: m}
+			if at, ok := applied[m.Version]; ok {
+				st.AppliedAt = &at
+			}
+			out = append(out, st)
+		}
+		return nil
+	})
+	return out, err
+}
+
+// withMigrationLock toma el advisory lock en una conexión propia (el lock es
+// de sesión) y crea schema_migrations si no existe. En SQLite no hace falta:
+// hay una sola conexión y el fichero no se comparte entre instancias.
+func withMigrationLock(ctx context.Context, db *sql.DB, fn func(*sql.Conn) error) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	tsType := "TIMESTAMP"
+	if DialectOf(db) == Postgres {
+		tsType = "TIMESTAMPTZ"
+		if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+			return fmt.Errorf("migrations: lock: %w", err)
+		}
+		defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockID)
+	}
+
+	if _, err := conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+		  version    BIGINT PRIMARY KEY,
+		  name       TEXT NOT NULL,
+		  applied_at `+tsType+` NOT NULL
+		)
+	`); err != nil {
+		return fmt.Errorf("migrations: create table: %w", err)
+	}
+	return fn(conn)
+}
+
+func appliedVersions(ctx context.Context, conn *sql.Conn) (map[int64]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := map[int64]time.Time{}
+	for rows.Next() {
+		var v int64
+		var at time.Time
+		if err := rows.Scan(&v, &at); err != nil {
+			return nil, err
+		}
+		out[v] = at
+	}
+	return out, rows.Err()
+}
+
+// runMigration ejecuta el SQL y actualiza schema_migrations en la misma
+// transacción (en Postgres y SQLite el DDL también es transaccional).
+func runMigration(ctx context.Context, conn *sql.Conn, m Migration, script, record string, args ...any) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return fmt.Errorf("migration %04d_%s: %w", m.Version, m.Name, err)
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return fmt.Errorf("migration %04d_%s: record: %w", m.Version, m.Name, err)
+	}
+	return tx.Commit()
 }
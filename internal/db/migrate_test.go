@@ -0,0 +1,180 @@
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestEmbeddedMigrations(t *testing.T) {
+	pg, err := Migrations(Postgres)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lite, err := Migrations(SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pg) == 0 || len(pg) != len(lite) {
+		t.Fatalf("postgres has %d migrations, sqlite %d", len(pg), len(lite))
+	}
+	for i := range pg {
+		if pg[i].Version != lite[i].Version || pg[i].Name != lite[i].Name {
+			t.Fatalf("dialects diverge at %04d: %s vs %s", pg[i].Version, pg[i].Name, lite[i].Name)
+		}
+		for _, m := range []Migration{pg[i], lite[i]} {
+			if strings.TrimSpace(m.Up) == "" || strings.TrimSpace(m.Down) == "" {
+				t.Fatalf("empty migration %04d_%s", m.Version, m.Name)
+			}
+		}
+	}
+}
+
+func TestLoadMigrationsRejectsBadSets(t *testing.T) {
+	cases := map[string]fstest.MapFS{
+		"missing down": {
+			"m/0001_a.up.sql": {Data: []byte("x")},
+		},
+		"gap": {
+			"m/0001_a.up.sql": {Data: []byte("x")}, "m/0001_a.down.sql": {Data: []byte("x")},
+			"m/0003_c.up.sql": {Data: []byte("x")}, "m/0003_c.down.sql": {Data: []byte("x")},
+		},
+		"bad name": {
+			"m/create_users.sql": {Data: []byte("x")},
+		},
+		"two names": {
+			"m/0001_a.up.sql": {Data: []byte("x")}, "m/0001_b.down.sql": {Data: []byte("x")},
+		},
+	}
+	for name, fsys := range cases {
+		if _, err := loadMigrations(fsys, "m"); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+// SQLite: BD nueva en un directorio temporal; se deshace todo y se rehace.
+func TestMigrateSQLite(t *testing.T) {
+	d, err := Open(filepath.Join(t.TempDir(), "forum.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if DialectOf(d) != SQLite {
+		t.Fatalf("dialect = %s", DialectOf(d))
+	}
+	all, _ := Migrations(SQLite)
+	testUpDownUp(t, d, len(all))
+	testAuditAppendOnly(t, d)
+	testSessionIDsHashed(t, d)
+}
+
+// Postgres necesita una BD de usar y tirar (FORUM_TEST_DATABASE_URL). Solo
+// se deshace la última migración, porque otros paquetes usan la misma BD
+// mientras tanto.
+func TestMigratePostgres(t *testing.T) {
+	dsn := os.Getenv("FORUM_TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("FORUM_TEST_DATABASE_URL not set")
+	}
+	d, err := Open(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	testUpDownUp(t, d, 1)
+	testAuditAppendOnly(t, d)
+}
+
+func testUpDownUp(t *testing.T, d *sql.DB, steps int) {
+	t.Helper()
+	ctx := context.Background()
+
+	all, _ := Migrations(DialectOf(d))
+	if _, err := MigrateUp(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	done, err := MigrateDown(ctx, d, steps)
+	if err != nil || len(done) != steps || done[0].Version != all[len(all)-1].Version {
+		t.Fatalf("down: %+v, %v", done, err)
+	}
+	if done, err := MigrateUp(ctx, d); err != nil || len(done) != steps {
+		t.Fatalf("up: %d, %v", len(done), err)
+	}
+	st, err := MigrationsStatus(ctx, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range st {
+		if s.AppliedAt == nil {
+			t.Fatalf("%04d_%s not applied", s.Version, s.Name)
+		}
+	}
+	if done, err := MigrateUp(ctx, d); err != nil || len(done) != 0 {
+		t.Fatalf("second up applied %d, %v", len(done), err)
+	}
+}
+
+// 0015 cambia el id de las sesiones abiertas por su SHA-256 (en SQLite con
+// la función sha256_hex registrada en sqlite.go).
+func testSessionIDsHashed(t *testing.T, d *sql.DB) {
+	t.Helper()
+	ctx := context.Background()
+	// Se deshacen 0015 y las posteriores
+	all, _ := Migrations(DialectOf(d))
+	steps := 0
+	for _, m := range all {
+		if m.Version >= 15 {
+			steps++
+		}
+	}
+	if _, err := MigrateDown(ctx, d, steps); err != nil {
+		t.Fatal(err)
+	}
+	var uid int64
+	err := d.QueryRowContext(ctx, `
+		INSERT INTO users (email, username, password_hash) VALUES ('hash@example.test', 'hash', 'x')
+		RETURNING id`).Scan(&uid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.ExecContext(ctx, `INSERT INTO sessions (id, user_id, expires_at) VALUES ('cookie-value', $1, $2)`,
+		uid, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := MigrateUp(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte("cookie-value"))
+	var n int
+	if err := d.QueryRowContext(ctx, `SELECT COUNT(*) FROM sessions WHERE id = $1`, hex.EncodeToString(sum[:])).Scan(&n); err != nil || n != 1 {
+		t.Fatalf("hashed sessions = %d, %v", n, err)
+	}
+}
+
+// audit_log solo admite INSERT (ver migración 0010).
+func testAuditAppendOnly(t *testing.T, d *sql.DB) {
+	t.Helper()
+	ctx := context.Background()
+	var id int64
+	err := d.QueryRowContext(ctx, `
+		INSERT INTO audit_log (action, target_type, target_id, created_at)
+		VALUES ('test.append', 'user', 0, $1)
+		RETURNING id`, time.Now()).Scan(&id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.ExecContext(ctx, `UPDATE audit_log SET action = 'x' WHERE id = $1`, id); err == nil {
+		t.Fatal("UPDATE on audit_log succeeded")
+	}
+	if _, err := d.ExecContext(ctx, `DELETE FROM audit_log WHERE id = $1`, id); err == nil {
+		t.Fatal("DELETE on audit_log succeeded")
+	}
+}
@@ -1,4 +1,5 @@
--- PostgreSQL DDL
+-- Esquema base. Usa IF NOT EXISTS para adoptar las BD creadas con el antiguo
+-- schema.pg.sql (que se ejecutaba entero en cada arranque).
 
 CREATE TABLE IF NOT EXISTS users (
   id           BIGSERIAL PRIMARY KEY,
@@ -52,10 +53,9 @@ CREATE TABLE IF NOT EXISTS reactions (
   UNIQUE(user_id, target_type, target_id)
 );
 
--- Índices útiles
-CREATE INDEX IF NOT EXISTS idx_posts_created   ON posts(created_at DESC);
-CREATE INDEX IF NOT EXISTS idx_comments_post   ON comments(post_id, created_at);
-CREATE INDEX IF NOT EXISTS idx_react_target    ON reactions(target_type, target_id);
+CREATE INDEX IF NOT EXISTS idx_posts_created ON posts(created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_comments_post ON comments(post_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_react_target  ON reactions(target_type, target_id);
 
 -- Seeds
 INSERT INTO categories (name) VALUES ('General'), ('Go'), ('DevOps'), ('Databases')
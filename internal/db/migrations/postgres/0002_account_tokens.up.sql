@@ -0,0 +1,41 @@
+-- Verificación de email, reseteo de contraseña, CSRF por sesión y tokens de API.
+
+-- Usuarios existentes quedan verificados (DEFAULT solo se aplica al añadir la columna)
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMPTZ DEFAULT NOW();
+ALTER TABLE users ALTER COLUMN email_verified_at DROP DEFAULT;
+
+-- Token CSRF por sesión (las sesiones existentes reciben uno aleatorio)
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS csrf_token TEXT NOT NULL
+  DEFAULT md5(random()::text || clock_timestamp()::text);
+
+CREATE TABLE IF NOT EXISTS password_reset_tokens (
+  id         BIGSERIAL PRIMARY KEY,
+  user_id    BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  token_hash TEXT NOT NULL UNIQUE,   -- SHA-256 del token enviado por email
+  expires_at TIMESTAMPTZ NOT NULL,
+  used_at    TIMESTAMPTZ,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS email_verification_tokens (
+  id         BIGSERIAL PRIMARY KEY,
+  user_id    BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  token_hash TEXT NOT NULL UNIQUE,   -- SHA-256 del token enviado por email
+  expires_at TIMESTAMPTZ NOT NULL,
+  used_at    TIMESTAMPTZ,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS api_tokens (
+  id           BIGSERIAL PRIMARY KEY,
+  user_id      BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  name         TEXT NOT NULL,
+  token_hash   TEXT NOT NULL UNIQUE,  -- SHA-256 del token "fpat_..."
+  scopes       TEXT NOT NULL,         -- lista separada por comas: read,post,react,admin
+  last_used_at TIMESTAMPTZ,
+  created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_reset_user      ON password_reset_tokens(user_id);
+CREATE INDEX IF NOT EXISTS idx_verify_user     ON email_verification_tokens(user_id);
+CREATE INDEX IF NOT EXISTS idx_api_tokens_user ON api_tokens(user_id);
@@ -0,0 +1,3 @@
+ALTER TABLE comments DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE posts    DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE users    DROP COLUMN IF EXISTS role;
@@ -0,0 +1,12 @@
+-- Roles (moderación) y marca de edición de posts y comentarios.
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'member'
+  CHECK (role IN ('member','moderator','admin'));
+
+ALTER TABLE posts    ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ; -- NULL = nunca editado
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ;
+
+-- reactions.target_id no tiene FK: limpia reacciones huérfanas de contenido borrado
+DELETE FROM reactions r
+ WHERE (r.target_type = 'post'    AND NOT EXISTS (SELECT 1 FROM posts    p WHERE p.id = r.target_id))
+    OR (r.target_type = 'comment' AND NOT EXISTS (SELECT 1 FROM comments c WHERE c.id = r.target_id));
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_comments_search;
+DROP INDEX IF EXISTS idx_posts_search;
+ALTER TABLE comments DROP COLUMN IF EXISTS search;
+ALTER TABLE posts    DROP COLUMN IF EXISTS search;
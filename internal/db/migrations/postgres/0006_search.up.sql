@@ -0,0 +1,13 @@
+-- Búsqueda de texto completo. Columnas tsvector generadas (se mantienen
+-- solas) con índices GIN; en posts el título pesa más que el cuerpo.
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS search tsvector
+  GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', title), 'A') ||
+    setweight(to_tsvector('english', content), 'B')
+  ) STORED;
+
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS search tsvector
+  GENERATED ALWAYS AS (to_tsvector('english', content)) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_posts_search    ON posts    USING GIN (search);
+CREATE INDEX IF NOT EXISTS idx_comments_search ON comments USING GIN (search);
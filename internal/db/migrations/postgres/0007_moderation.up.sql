@@ -0,0 +1,6 @@
+-- Herramientas de moderación: hilos cerrados a comentarios y posts fijados
+-- arriba de la portada. NULL = no.
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS locked_at TIMESTAMPTZ;
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS pinned_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_posts_pinned ON posts(pinned_at DESC) WHERE pinned_at IS NOT NULL;
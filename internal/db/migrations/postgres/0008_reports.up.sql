@@ -0,0 +1,16 @@
+-- Denuncias de posts y comentarios (cola de moderación en /mod/queue).
+
+CREATE TABLE IF NOT EXISTS reports (
+  id          BIGSERIAL PRIMARY KEY,
+  target_type TEXT NOT NULL CHECK (target_type IN ('post','comment')),
+  target_id   BIGINT NOT NULL,   -- sin FK: la denuncia sobrevive al contenido borrado
+  reporter_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  reason      TEXT NOT NULL,
+  status      TEXT NOT NULL DEFAULT 'open' CHECK (status IN ('open','resolved','dismissed')),
+  created_at  TIMESTAMPTZ NOT NULL,
+  handled_by  BIGINT REFERENCES users(id) ON DELETE SET NULL,  -- moderador que la cerró
+  handled_at  TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_reports_open   ON reports(created_at, id) WHERE status = 'open';
+CREATE INDEX IF NOT EXISTS idx_reports_target ON reports(target_type, target_id);
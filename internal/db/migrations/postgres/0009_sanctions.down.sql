@@ -0,0 +1,5 @@
+ALTER TABLE users DROP COLUMN IF EXISTS mute_reason;
+ALTER TABLE users DROP COLUMN IF EXISTS muted_until;
+ALTER TABLE users DROP COLUMN IF EXISTS ban_reason;
+ALTER TABLE users DROP COLUMN IF EXISTS suspended_until;
+ALTER TABLE users DROP COLUMN IF EXISTS banned_at;
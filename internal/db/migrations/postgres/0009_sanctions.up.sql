@@ -0,0 +1,7 @@
+-- Sanciones: baneo permanente, suspensión temporal y silencio (solo lectura).
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS banned_at       TIMESTAMPTZ; -- NULL = no baneado
+ALTER TABLE users ADD COLUMN IF NOT EXISTS suspended_until TIMESTAMPTZ; -- sin acceso hasta entonces
+ALTER TABLE users ADD COLUMN IF NOT EXISTS ban_reason      TEXT NOT NULL DEFAULT ''; -- del baneo o la suspensión
+ALTER TABLE users ADD COLUMN IF NOT EXISTS muted_until     TIMESTAMPTZ; -- puede leer, no escribir
+ALTER TABLE users ADD COLUMN IF NOT EXISTS mute_reason     TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,29 @@
+-- Registro de acciones privilegiadas o destructivas (/admin/audit).
+-- Solo se añaden filas: un trigger rechaza UPDATE y DELETE.
+
+CREATE TABLE IF NOT EXISTS audit_log (
+  id          BIGSERIAL PRIMARY KEY,
+  actor_id    BIGINT,            -- sin FK: el registro sobrevive al usuario; NULL = línea de comandos
+  action      TEXT NOT NULL,     -- post.delete, user.ban, user.role, ...
+  target_type TEXT NOT NULL,     -- post | comment | user | report
+  target_id   BIGINT NOT NULL,
+  before      JSONB,             -- estado anterior (NULL si no aplica)
+  after       JSONB,             -- estado nuevo (NULL si se borró)
+  ip          TEXT NOT NULL DEFAULT '',
+  created_at  TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_created ON audit_log(created_at, id);
+CREATE INDEX IF NOT EXISTS idx_audit_actor   ON audit_log(actor_id);
+CREATE INDEX IF NOT EXISTS idx_audit_target  ON audit_log(target_type, target_id);
+
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+  RAISE EXCEPTION 'audit_log is append-only';
+END
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_log_append_only ON audit_log;
+CREATE TRIGGER audit_log_append_only
+  BEFORE UPDATE OR DELETE ON audit_log
+  FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
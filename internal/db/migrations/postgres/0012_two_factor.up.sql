@@ -0,0 +1,30 @@
+-- Segundo factor TOTP (RFC 6238), códigos de recuperación y el paso intermedio del login.
+
+CREATE TABLE IF NOT EXISTS user_totp (
+  user_id    BIGINT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+  secret     TEXT NOT NULL,          -- base32; hace falta en claro para calcular los códigos
+  enabled_at TIMESTAMPTZ,            -- NULL = alta empezada pero sin confirmar
+  last_step  BIGINT NOT NULL DEFAULT 0,  -- último paso de 30s aceptado (evita reusar un código)
+  created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS recovery_codes (
+  id         BIGSERIAL PRIMARY KEY,
+  user_id    BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  code_hash  TEXT NOT NULL,          -- SHA-256 del código normalizado
+  used_at    TIMESTAMPTZ,
+  created_at TIMESTAMPTZ NOT NULL
+);
+
+-- Login a medias: contraseña correcta, falta el código (ver auth.LoginSecondFactor)
+CREATE TABLE IF NOT EXISTS login_challenges (
+  id         BIGSERIAL PRIMARY KEY,
+  user_id    BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  token_hash TEXT NOT NULL UNIQUE,
+  expires_at TIMESTAMPTZ NOT NULL,
+  used_at    TIMESTAMPTZ,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_recovery_codes_user   ON recovery_codes(user_id);
+CREATE INDEX IF NOT EXISTS idx_login_challenges_user ON login_challenges(user_id);
@@ -0,0 +1,2 @@
+ALTER TABLE sessions DROP COLUMN IF EXISTS role;
+ALTER TABLE sessions DROP COLUMN IF EXISTS persistent;
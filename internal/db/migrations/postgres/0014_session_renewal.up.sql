@@ -0,0 +1,6 @@
+-- Sesiones deslizantes. persistent: la cookie sobrevive al navegador
+-- ("remember me"). role: rol con el que se emitió el id; si cambia, el id rota.
+-- created_at pasa a ser la hora del login y limita la vida total de la sesión.
+
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS persistent BOOLEAN NOT NULL DEFAULT TRUE;
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS role       TEXT NOT NULL DEFAULT '';
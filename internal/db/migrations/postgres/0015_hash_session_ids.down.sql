@@ -0,0 +1,2 @@
+-- Un hash no se puede deshacer: las sesiones se cierran y toca volver a entrar.
+DELETE FROM sessions;
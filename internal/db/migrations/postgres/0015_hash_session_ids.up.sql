@@ -0,0 +1,5 @@
+-- sessions.id pasa a ser el SHA-256 (hex) del token que lleva la cookie; el
+-- token en claro ya no se guarda. Las sesiones abiertas siguen valiendo: su
+-- id actual es el valor de la cookie, así que basta con hashearlo.
+
+UPDATE sessions SET id = encode(sha256(convert_to(id, 'UTF8')), 'hex');
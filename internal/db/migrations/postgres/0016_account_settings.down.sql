@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS username_history;
+DROP TABLE IF EXISTS email_changes;
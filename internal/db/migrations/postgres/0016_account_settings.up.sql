@@ -0,0 +1,26 @@
+-- Ajustes de cuenta.
+
+-- Cambio de email: la dirección nueva espera aquí hasta que se confirma con
+-- el enlace que se le manda (solo el hash del token, como el resto).
+CREATE TABLE IF NOT EXISTS email_changes (
+  id         BIGSERIAL PRIMARY KEY,
+  user_id    BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  new_email  TEXT NOT NULL,
+  token_hash TEXT NOT NULL UNIQUE,   -- SHA-256 del token enviado por email
+  expires_at TIMESTAMPTZ NOT NULL,
+  used_at    TIMESTAMPTZ,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Nombres de usuario anteriores. Siguen siendo de su dueño: nadie más puede
+-- registrarlos ni cambiarse a ellos, para que no se haga pasar por él.
+CREATE TABLE IF NOT EXISTS username_history (
+  id         BIGSERIAL PRIMARY KEY,
+  user_id    BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  username   TEXT NOT NULL,
+  changed_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_changes_user    ON email_changes(user_id);
+CREATE INDEX IF NOT EXISTS idx_username_history_name ON username_history(username);
+CREATE INDEX IF NOT EXISTS idx_username_history_user ON username_history(user_id);
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS api_tokens;
+DROP TABLE IF EXISTS email_verification_tokens;
+DROP TABLE IF EXISTS password_reset_tokens;
+ALTER TABLE sessions DROP COLUMN csrf_token;
+ALTER TABLE users DROP COLUMN email_verified_at;
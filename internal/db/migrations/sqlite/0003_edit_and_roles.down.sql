@@ -0,0 +1,3 @@
+ALTER TABLE comments DROP COLUMN updated_at;
+ALTER TABLE posts    DROP COLUMN updated_at;
+ALTER TABLE users    DROP COLUMN role;
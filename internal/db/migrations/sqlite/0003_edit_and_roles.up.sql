@@ -0,0 +1,12 @@
+-- Roles (moderación) y marca de edición de posts y comentarios.
+
+ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'member'
+  CHECK (role IN ('member','moderator','admin'));
+
+ALTER TABLE posts    ADD COLUMN updated_at TIMESTAMP; -- NULL = nunca editado
+ALTER TABLE comments ADD COLUMN updated_at TIMESTAMP;
+
+-- reactions.target_id no tiene FK: limpia reacciones huérfanas de contenido borrado
+DELETE FROM reactions
+ WHERE (target_type = 'post'    AND NOT EXISTS (SELECT 1 FROM posts    p WHERE p.id = reactions.target_id))
+    OR (target_type = 'comment' AND NOT EXISTS (SELECT 1 FROM comments c WHERE c.id = reactions.target_id));
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_posts_keyset;
+CREATE INDEX IF NOT EXISTS idx_posts_created ON posts(created_at DESC);
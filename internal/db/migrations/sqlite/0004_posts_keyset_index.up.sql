@@ -0,0 +1,3 @@
+-- Paginación por cursor (created_at, id)
+DROP INDEX IF EXISTS idx_posts_created;
+CREATE INDEX IF NOT EXISTS idx_posts_keyset ON posts(created_at DESC, id DESC);
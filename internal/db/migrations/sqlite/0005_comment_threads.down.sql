@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_comments_parent;
+ALTER TABLE comments DROP COLUMN parent_id;
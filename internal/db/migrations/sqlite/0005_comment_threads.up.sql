@@ -0,0 +1,5 @@
+-- Respuestas anidadas: parent_id NULL = comentario de primer nivel. Borrar un
+-- comentario se lleva por delante su subárbol.
+ALTER TABLE comments ADD COLUMN parent_id INTEGER REFERENCES comments(id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS idx_comments_parent ON comments(parent_id);
@@ -0,0 +1,8 @@
+DROP TRIGGER IF EXISTS comments_fts_au;
+DROP TRIGGER IF EXISTS comments_fts_ad;
+DROP TRIGGER IF EXISTS comments_fts_ai;
+DROP TRIGGER IF EXISTS posts_fts_au;
+DROP TRIGGER IF EXISTS posts_fts_ad;
+DROP TRIGGER IF EXISTS posts_fts_ai;
+DROP TABLE IF EXISTS comments_fts;
+DROP TABLE IF EXISTS posts_fts;
@@ -0,0 +1,33 @@
+-- Búsqueda de texto completo con FTS5. Las tablas virtuales apuntan a posts
+-- y comments (content=) y los triggers las mantienen al día.
+CREATE VIRTUAL TABLE posts_fts USING fts5(
+  title, content, content='posts', content_rowid='id', tokenize='porter unicode61'
+);
+CREATE VIRTUAL TABLE comments_fts USING fts5(
+  content, content='comments', content_rowid='id', tokenize='porter unicode61'
+);
+
+INSERT INTO posts_fts(posts_fts)       VALUES ('rebuild');
+INSERT INTO comments_fts(comments_fts) VALUES ('rebuild');
+
+CREATE TRIGGER posts_fts_ai AFTER INSERT ON posts BEGIN
+  INSERT INTO posts_fts(rowid, title, content) VALUES (new.id, new.title, new.content);
+END;
+CREATE TRIGGER posts_fts_ad AFTER DELETE ON posts BEGIN
+  INSERT INTO posts_fts(posts_fts, rowid, title, content) VALUES ('delete', old.id, old.title, old.content);
+END;
+CREATE TRIGGER posts_fts_au AFTER UPDATE OF title, content ON posts BEGIN
+  INSERT INTO posts_fts(posts_fts, rowid, title, content) VALUES ('delete', old.id, old.title, old.content);
+  INSERT INTO posts_fts(rowid, title, content) VALUES (new.id, new.title, new.content);
+END;
+
+CREATE TRIGGER comments_fts_ai AFTER INSERT ON comments BEGIN
+  INSERT INTO comments_fts(rowid, content) VALUES (new.id, new.content);
+END;
+CREATE TRIGGER comments_fts_ad AFTER DELETE ON comments BEGIN
+  INSERT INTO comments_fts(comments_fts, rowid, content) VALUES ('delete', old.id, old.content);
+END;
+CREATE TRIGGER comments_fts_au AFTER UPDATE OF content ON comments BEGIN
+  INSERT INTO comments_fts(comments_fts, rowid, content) VALUES ('delete', old.id, old.content);
+  INSERT INTO comments_fts(rowid, content) VALUES (new.id, new.content);
+END;
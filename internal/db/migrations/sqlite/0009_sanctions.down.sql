@@ -0,0 +1,5 @@
+ALTER TABLE users DROP COLUMN mute_reason;
+ALTER TABLE users DROP COLUMN muted_until;
+ALTER TABLE users DROP COLUMN ban_reason;
+ALTER TABLE users DROP COLUMN suspended_until;
+ALTER TABLE users DROP COLUMN banned_at;
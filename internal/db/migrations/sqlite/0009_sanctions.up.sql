@@ -0,0 +1,7 @@
+-- Sanciones: baneo permanente, suspensión temporal y silencio (solo lectura).
+
+ALTER TABLE users ADD COLUMN banned_at       TIMESTAMP; -- NULL = no baneado
+ALTER TABLE users ADD COLUMN suspended_until TIMESTAMP; -- sin acceso hasta entonces
+ALTER TABLE users ADD COLUMN ban_reason      TEXT NOT NULL DEFAULT ''; -- del baneo o la suspensión
+ALTER TABLE users ADD COLUMN muted_until     TIMESTAMP; -- puede leer, no escribir
+ALTER TABLE users ADD COLUMN mute_reason     TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,26 @@
+-- Registro de acciones privilegiadas o destructivas (/admin/audit).
+-- Solo se añaden filas: los triggers rechazan UPDATE y DELETE.
+
+CREATE TABLE audit_log (
+  id          INTEGER PRIMARY KEY,
+  actor_id    INTEGER,           -- sin FK: el registro sobrevive al usuario; NULL = línea de comandos
+  action      TEXT NOT NULL,     -- post.delete, user.ban, user.role, ...
+  target_type TEXT NOT NULL,     -- post | comment | user | report
+  target_id   INTEGER NOT NULL,
+  before      TEXT,              -- JSON del estado anterior (NULL si no aplica)
+  after       TEXT,              -- JSON del estado nuevo (NULL si se borró)
+  ip          TEXT NOT NULL DEFAULT '',
+  created_at  TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_audit_created ON audit_log(created_at, id);
+CREATE INDEX idx_audit_actor   ON audit_log(actor_id);
+CREATE INDEX idx_audit_target  ON audit_log(target_type, target_id);
+
+CREATE TRIGGER audit_log_no_update BEFORE UPDATE ON audit_log BEGIN
+  SELECT RAISE(ABORT, 'audit_log is append-only');
+END;
+
+CREATE TRIGGER audit_log_no_delete BEFORE DELETE ON audit_log BEGIN
+  SELECT RAISE(ABORT, 'audit_log is append-only');
+END;
@@ -0,0 +1,11 @@
+-- Intentos de login fallidos, para el rate limit por IP y por cuenta.
+-- key es "ip:<dirección>" o "account:<email>"; se cuenta en una ventana deslizante.
+
+CREATE TABLE login_failures (
+  id        INTEGER PRIMARY KEY,
+  key       TEXT NOT NULL,
+  failed_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_login_failures_key ON login_failures(key, failed_at DESC);
+CREATE INDEX idx_login_failures_at  ON login_failures(failed_at);
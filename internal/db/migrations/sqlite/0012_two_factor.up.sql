@@ -0,0 +1,30 @@
+-- Segundo factor TOTP (RFC 6238), códigos de recuperación y el paso intermedio del login.
+
+CREATE TABLE user_totp (
+  user_id    INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+  secret     TEXT NOT NULL,          -- base32; hace falta en claro para calcular los códigos
+  enabled_at TIMESTAMP,              -- NULL = alta empezada pero sin confirmar
+  last_step  INTEGER NOT NULL DEFAULT 0, -- último paso de 30s aceptado (evita reusar un código)
+  created_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE recovery_codes (
+  id         INTEGER PRIMARY KEY,
+  user_id    INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  code_hash  TEXT NOT NULL,          -- SHA-256 del código normalizado
+  used_at    TIMESTAMP,
+  created_at TIMESTAMP NOT NULL
+);
+
+-- Login a medias: contraseña correcta, falta el código (ver auth.LoginSecondFactor)
+CREATE TABLE login_challenges (
+  id         INTEGER PRIMARY KEY,
+  user_id    INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  token_hash TEXT NOT NULL UNIQUE,
+  expires_at TIMESTAMP NOT NULL,
+  used_at    TIMESTAMP,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_recovery_codes_user   ON recovery_codes(user_id);
+CREATE INDEX idx_login_challenges_user ON login_challenges(user_id);
@@ -0,0 +1,11 @@
+-- Varias sesiones por usuario: cada una recuerda desde dónde se abrió y
+-- cuándo se usó por última vez, para la página de dispositivos.
+
+ALTER TABLE sessions ADD COLUMN user_agent   TEXT NOT NULL DEFAULT '';
+ALTER TABLE sessions ADD COLUMN ip           TEXT NOT NULL DEFAULT '';
+ALTER TABLE sessions ADD COLUMN last_seen_at TIMESTAMP;
+
+UPDATE sessions SET last_seen_at = created_at WHERE last_seen_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_sessions_user    ON sessions(user_id);
+CREATE INDEX IF NOT EXISTS idx_sessions_expires ON sessions(expires_at);
@@ -0,0 +1,2 @@
+ALTER TABLE sessions DROP COLUMN role;
+ALTER TABLE sessions DROP COLUMN persistent;
@@ -0,0 +1,6 @@
+-- Sesiones deslizantes. persistent: la cookie sobrevive al navegador
+-- ("remember me"). role: rol con el que se emitió el id; si cambia, el id rota.
+-- created_at pasa a ser la hora del login y limita la vida total de la sesión.
+
+ALTER TABLE sessions ADD COLUMN persistent BOOLEAN NOT NULL DEFAULT 1;
+ALTER TABLE sessions ADD COLUMN role       TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,6 @@
+-- sessions.id pasa a ser el SHA-256 (hex) del token que lleva la cookie; el
+-- token en claro ya no se guarda. Las sesiones abiertas siguen valiendo: su
+-- id actual es el valor de la cookie, así que basta con hashearlo.
+-- sha256_hex la registra internal/db/sqlite.go.
+
+UPDATE sessions SET id = sha256_hex(id);
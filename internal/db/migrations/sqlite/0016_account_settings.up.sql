@@ -0,0 +1,26 @@
+-- Ajustes de cuenta.
+
+-- Cambio de email: la dirección nueva espera aquí hasta que se confirma con
+-- el enlace que se le manda (solo el hash del token, como el resto).
+CREATE TABLE email_changes (
+  id         INTEGER PRIMARY KEY,
+  user_id    INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  new_email  TEXT NOT NULL,
+  token_hash TEXT NOT NULL UNIQUE,   -- SHA-256 del token enviado por email
+  expires_at TIMESTAMP NOT NULL,
+  used_at    TIMESTAMP,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Nombres de usuario anteriores. Siguen siendo de su dueño: nadie más puede
+-- registrarlos ni cambiarse a ellos, para que no se haga pasar por él.
+CREATE TABLE username_history (
+  id         INTEGER PRIMARY KEY,
+  user_id    INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  username   TEXT NOT NULL,
+  changed_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_email_changes_user    ON email_changes(user_id);
+CREATE INDEX idx_username_history_name ON username_history(username);
+CREATE INDEX idx_username_history_user ON username_history(user_id);
@@ -0,0 +1,113 @@
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"modernc.org/sqlite"
+)
+
+/* =========================
+   SQLite
+   ========================= */
+
+// SQLiteTimeFormat es como se guardan las fechas en SQLite: texto en UTC de
+// ancho fijo, para que comparar y ordenar como texto sea lo mismo que
+// hacerlo como fecha (la paginación por cursor depende de ello).
+const SQLiteTimeFormat = "2006-01-02 15:04:05.000000000"
+
+const sqliteDriverName = "forum-sqlite"
+
+func init() {
+	// Se envuelve el driver global de modernc ("sqlite"): las funciones
+	// registradas con sqlite.Register* solo llegan a sus conexiones.
+	base, _ := sql.Open("sqlite", "")
+	sql.Register(sqliteDriverName, sqliteDriver{base.Driver().(*sqlite.Driver)})
+	base.Close()
+	sqlite.MustRegisterDeterministicScalarFunction("sha256_hex", 1, sqliteSHA256Hex)
+}
+
+// sqliteSHA256Hex es sha256_hex(texto): el SHA-256 en hex, como
+// encode(sha256(...), 'hex') en Postgres. La usan las migraciones que
+// hashean tokens ya guardados.
+func sqliteSHA256Hex(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+	var b []byte
+	switch v := args[0].(type) {
+	case nil:
+		return nil, nil
+	case string:
+		b = []byte(v)
+	case []byte:
+		b = v
+	default:
+		return nil, fmt.Errorf("sha256_hex: unsupported argument %T", v)
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// sqliteDriver envuelve el driver de modernc.org/sqlite (Go puro, sin cgo)
+// solo para normalizar los time.Time que se pasan como argumento.
+type sqliteDriver struct{ d *sqlite.Driver }
+
+func (s sqliteDriver) Open(name string) (driver.Conn, error) {
+	c, err := s.d.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return sqliteConn{c.(sqliteConnIface)}, nil
+}
+
+// sqliteConnIface son las interfaces que implementa la conexión de modernc y
+// que database/sql usa si están (Exec sin preparar, necesario para los
+// scripts de migración con varias sentencias).
+type sqliteConnIface interface {
+	driver.Conn
+	driver.ConnBeginTx
+	driver.ConnPrepareContext
+	driver.ExecerContext
+	driver.QueryerContext
+}
+
+type sqliteConn struct{ sqliteConnIface }
+
+// CheckNamedValue convierte time.Time a SQLiteTimeFormat; el resto sigue la
+// conversión por defecto.
+func (sqliteConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if t, ok := nv.Value.(time.Time); ok {
+		nv.Value = t.UTC().Format(SQLiteTimeFormat)
+		return nil
+	}
+	return driver.ErrSkip
+}
+
+var _ driver.NamedValueChecker = sqliteConn{}
+
+// openSQLite abre el fichero con claves foráneas y espera en vez de fallar si
+// está bloqueado. Una sola conexión: SQLite serializa las escrituras de todos
+// modos y así no hay "database is locked" entre conexiones del mismo proceso.
+func openSQLite(dsn string) (*sql.DB, error) {
+	dsn = strings.TrimPrefix(dsn, "sqlite://")
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	dsn += sep + "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+
+	d, err := sql.Open(sqliteDriverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	d.SetMaxOpenConns(1)
+	if err := d.PingContext(context.Background()); err != nil {
+		d.Close()
+		return nil, err
+	}
+	return d, nil
+}
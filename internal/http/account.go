@@ -0,0 +1,177 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"forum/internal/auth"
+	"forum/internal/mail"
+	"forum/internal/util"
+)
+
+type accountVM struct {
+	Username      string
+	Email         string
+	Verified      bool
+	PendingEmail  string // cambio pedido y aún sin confirmar
+	PastUsernames []string
+}
+
+// ---------------------------------------------------------------------------------
+// ------------HandleSettingsAccount Function--------------------------------------
+// GET /settings: nombre, email y contraseña de la cuenta.
+func (s *Server) handleSettingsAccount(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	uid, _ := auth.UserIDFrom(ctx)
+
+	var data pageData
+	data.Title = "Account settings"
+	if d := r.URL.Query().Get("done"); d != "" {
+		data.Flash, data.FlashOK = d, true
+	} else if e := r.URL.Query().Get("err"); e != "" {
+		data.Flash = e
+	}
+
+	u, err := s.Store.Users.ByID(ctx, uid)
+	if err != nil {
+		http.Error(w, "account: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	vm := &accountVM{Username: u.Username, Email: u.Email, Verified: u.EmailVerifiedAt != nil}
+	if vm.PendingEmail, err = auth.PendingEmail(ctx, s.Store.EmailChanges, uid); err != nil {
+		http.Error(w, "account: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	past, err := auth.PastUsernames(ctx, s.Store.Users, uid)
+	if err != nil {
+		http.Error(w, "account: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for _, p := range past {
+		vm.PastUsernames = append(vm.PastUsernames, p.Username)
+	}
+	data.Account = vm
+
+	s.fillUserMeta(ctx, &data)
+	util.Render(w, "settings_account.html", data)
+}
+
+// ---------------------------------------------------------------------------------
+// ------------HandleSettingsAccountAction Function--------------------------------
+// POST /settings/{password|email|username}. password y email piden la
+// contraseña actual; el email nuevo no vale hasta que se confirma el enlace.
+func (s *Server) handleSettingsAccountAction(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	uid, _ := auth.UserIDFrom(ctx)
+	fail := func(msg string) {
+		http.Redirect(w, r, "/settings?err="+url.QueryEscape(msg), http.StatusSeeOther)
+	}
+
+	var done string
+	var err error
+	switch action := r.PathValue("action"); action {
+	case "password":
+		if r.FormValue("new_password") != r.FormValue("confirm") {
+			fail("The new passwords don't match")
+			return
+		}
+		err = auth.ChangePassword(ctx, s.Store.Users, uid,
+			r.FormValue("current_password"), r.FormValue("new_password"), sessionID(ctx))
+		done = "Password changed. You were signed out everywhere else and your API tokens were revoked."
+	case "email":
+		var token, email string
+		token, email, err = auth.RequestEmailChange(ctx, s.Store.Users, s.Store.EmailChanges, uid,
+			r.FormValue("password"), r.FormValue("email"), s.Cfg.VerifyTokenTTL)
+		if err == nil {
+			err = s.sendEmailChange(ctx, email, token)
+		}
+		done = "Check your new inbox: the change takes effect once you open the link we sent there."
+	case "username":
+		err = auth.ChangeUsername(ctx, s.Store.Users, uid, r.FormValue("username"))
+		done = "Username changed"
+	default:
+		s.notFound(w, r)
+		return
+	}
+	switch {
+	case errors.Is(err, auth.ErrWrongPassword), errors.Is(err, auth.ErrWeakPassword),
+		errors.Is(err, auth.ErrInvalidEmail), errors.Is(err, auth.ErrSameEmail), errors.Is(err, auth.ErrEmailTaken),
+		errors.Is(err, auth.ErrNoUsername), errors.Is(err, auth.ErrUsernameTaken):
+		fail(err.Error())
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("account %s uid=%d", r.PathValue("action"), uid)
+	// La contraseña nueva deja atrás cualquier token de esta sesión que se
+	// hubiera filtrado con la vieja
+	if r.PathValue("action") == "password" {
+		if err := s.rotateSession(w, r); err != nil {
+			log.Printf("account password: rotate session uid=%d err=%v", uid, err)
+		}
+	}
+	http.Redirect(w, r, "/settings?done="+url.QueryEscape(done), http.StatusSeeOther)
+}
+
+// sendEmailChange manda el enlace de confirmación a la dirección nueva.
+func (s *Server) sendEmailChange(ctx context.Context, email, token string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	link := s.Cfg.BaseURL + "/email/confirm?token=" + url.QueryEscape(token)
+	return s.Mailer.Send(ctx, mail.Message{
+		To:      email,
+		Subject: "Confirm your new forum email address",
+		Body: "Someone asked to use this address for their forum account.\n\n" +
+			"Open this link to confirm it (valid for " + s.Cfg.VerifyTokenTTL.String() + "):\n" +
+			link + "\n\nUntil then the account keeps its old address. If it wasn't you, just ignore this email.",
+	})
+}
+
+// ---------------------------------------------------------------------------------
+// ------------HandleEmailConfirm Function-----------------------------------------
+// GET /email/confirm?token=: aplica el cambio de email (no hace falta sesión:
+// el enlace puede abrirse en otro dispositivo) y avisa a la dirección vieja.
+func (s *Server) handleEmailConfirm(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	_, logged := auth.UserIDFrom(ctx)
+	back := "/login"
+	if logged {
+		back = "/settings"
+	}
+	old, newEmail, err := auth.ConfirmEmailChange(ctx, s.Store.Users, s.Store.EmailChanges, r.URL.Query().Get("token"))
+	if err != nil {
+		msg := "This confirmation link is invalid or has expired."
+		if errors.Is(err, auth.ErrEmailTaken) {
+			msg = "That email address is already used by another account."
+		} else if !errors.Is(err, auth.ErrInvalidToken) {
+			log.Printf("email confirm: err: %v", err)
+		}
+		http.Redirect(w, r, back+"?err="+url.QueryEscape(msg), http.StatusSeeOther)
+		return
+	}
+	log.Printf("account email confirmed uid=%d", old.ID)
+
+	// Aviso a la dirección anterior, por si el cambio no lo hizo su dueño
+	mctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := s.Mailer.Send(mctx, mail.Message{
+		To:      old.Email,
+		Subject: "Your forum email address was changed",
+		Body: "The forum account " + old.Username + " now uses " + newEmail + " instead of this address.\n\n" +
+			"If you didn't do this, reset your password and contact an administrator.",
+	}); err != nil {
+		log.Printf("email confirm: notify old address uid=%d err: %v", old.ID, err)
+	}
+
+	if logged {
+		http.Redirect(w, r, "/settings?done="+url.QueryEscape("Email address changed"), http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, "/login?verified=1", http.StatusSeeOther)
+}
@@ -0,0 +1,619 @@
+package httpx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"forum/internal/app"
+	"forum/internal/auth"
+	"forum/internal/models"
+)
+
+// API JSON versionada bajo /api/v1. No usa la cookie de sesión ni CSRF:
+// la autenticación va en la cabecera "Authorization: Bearer <token>".
+
+const apiPrefix = "/api/v1"
+
+func (s *Server) apiRoutes() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("POST "+apiPrefix+"/auth/token", s.apiLogin)
+	mux.HandleFunc("POST "+apiPrefix+"/auth/token/2fa", s.apiLoginSecondFactor)
+	mux.Handle("DELETE "+apiPrefix+"/auth/token", s.apiRequireAuth(http.HandlerFunc(s.apiLogout)))
+	mux.Handle("GET "+apiPrefix+"/me", s.apiRequireAuth(s.requireScope(auth.ScopeRead, s.apiMe)))
+
+	mux.Handle("GET "+apiPrefix+"/categories", s.requireScope(auth.ScopeRead, s.apiCategories))
+	mux.Handle("GET "+apiPrefix+"/posts", s.requireScope(auth.ScopeRead, s.apiListPosts))
+	mux.Handle("GET "+apiPrefix+"/posts/{id}", s.requireScope(auth.ScopeRead, s.apiGetPost))
+
+	mux.Handle("POST "+apiPrefix+"/posts", s.apiWrite(auth.ScopePost, s.apiCreatePost))
+	mux.Handle("POST "+apiPrefix+"/posts/{id}/comments", s.apiWrite(auth.ScopePost, s.apiCreateComment))
+	mux.Handle("PUT "+apiPrefix+"/posts/{id}/reaction", s.apiWrite(auth.ScopeReact, s.apiReact("post")))
+	mux.Handle("PUT "+apiPrefix+"/comments/{id}/reaction", s.apiWrite(auth.ScopeReact, s.apiReact("comment")))
+	mux.Handle("DELETE "+apiPrefix+"/posts/{id}/reaction", s.apiWrite(auth.ScopeReact, s.apiUnreact("post")))
+	mux.Handle("DELETE "+apiPrefix+"/comments/{id}/reaction", s.apiWrite(auth.ScopeReact, s.apiUnreact("comment")))
+
+	mux.Handle("GET "+apiPrefix+"/admin/audit", s.apiRequireAuth(s.requireScope(auth.ScopeAdmin, s.apiAudit)))
+
+	mux.HandleFunc(apiPrefix+"/", func(w http.ResponseWriter, r *http.Request) {
+		writeAPIError(w, http.StatusNotFound, "not_found", "no such endpoint")
+	})
+
+	return s.withBearer(mux)
+}
+
+/* =========================
+   Respuestas
+   ========================= */
+
+type apiErrorBody struct {
+	Error apiError `json:"error"`
+}
+
+type apiError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type apiComment struct {
+	ID         int64     `json:"id"`
+	ParentID   int64     `json:"parent_id,omitempty"`
+	Author     string    `json:"author"`
+	Content    string    `json:"content"`
+	Likes      int       `json:"likes"`
+	Dislikes   int       `json:"dislikes"`
+	MyReaction int       `json:"my_reaction,omitempty"` // solo con token: 1 / -1
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type apiPost struct {
+	ID         int64        `json:"id"`
+	Title      string       `json:"title"`
+	Content    string       `json:"content"`
+	Author     string       `json:"author"`
+	Categories []string     `json:"categories"`
+	Likes      int          `json:"likes"`
+	Dislikes   int          `json:"dislikes"`
+	MyReaction int          `json:"my_reaction,omitempty"` // solo con token: 1 / -1
+	Pinned     bool         `json:"pinned"`
+	Locked     bool         `json:"locked"`
+	CreatedAt  time.Time    `json:"created_at"`
+	Comments   []apiComment `json:"comments,omitempty"`
+}
+
+func toAPIPost(p postVM, withComments bool) apiPost {
+	out := apiPost{
+		ID:         p.ID,
+		Title:      p.Title,
+		Content:    p.Content,
+		Author:     p.Author,
+		Categories: p.Cats,
+		Likes:      p.Likes,
+		Dislikes:   p.Dislikes,
+		MyReaction: p.MyReaction,
+		Pinned:     p.Pinned,
+		Locked:     p.Locked,
+		CreatedAt:  p.CreatedAt,
+	}
+	if out.Categories == nil {
+		out.Categories = []string{}
+	}
+	if withComments {
+		out.Comments = make([]apiComment, 0, len(p.Comments))
+		for _, c := range p.Comments {
+			out.Comments = append(out.Comments, apiComment{
+				ID:         c.ID,
+				ParentID:   c.ParentID,
+				Author:     c.Author,
+				Content:    c.Content,
+				Likes:      c.Likes,
+				Dislikes:   c.Dislikes,
+				MyReaction: c.MyReaction,
+				CreatedAt:  c.CreatedAt,
+			})
+		}
+	}
+	return out
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("api: encode response err: %v", err)
+	}
+}
+
+func writeAPIError(w http.ResponseWriter, status int, code, msg string) {
+	writeJSON(w, status, apiErrorBody{Error: apiError{Code: code, Message: msg}})
+}
+
+// writeAPIInternal registra el error real y devuelve un 500 genérico.
+func writeAPIInternal(w http.ResponseWriter, r *http.Request, err error) {
+	log.Printf("api %s %s: %v", r.Method, r.URL.Path, err)
+	writeAPIError(w, http.StatusInternalServerError, "internal", "internal server error")
+}
+
+// decodeJSON lee el cuerpo (máx. 1 MiB) en dst; escribe el 400 si falla.
+func decodeJSON(w http.ResponseWriter, r *http.Request, dst any) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+	if err := json.NewDecoder(r.Body).Decode(dst); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_json", "request body must be valid JSON")
+		return false
+	}
+	return true
+}
+
+func pathID(r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	return id, err == nil && id > 0
+}
+
+/* =========================
+   Auth (Bearer)
+   ========================= */
+
+// withBearer resuelve "Authorization: Bearer <token>" al usuario. Acepta
+// tokens personales (fpat_..., con scopes) y tokens de sesión de /auth/token.
+// Un token presente pero inválido es un 401; sin cabecera la request sigue
+// como anónima.
+func (s *Server) withBearer(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := r.Header.Get("Authorization")
+		if h == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		token, ok := strings.CutPrefix(h, "Bearer ")
+		if !ok || token == "" {
+			writeAPIError(w, http.StatusUnauthorized, "invalid_token", "expected a Bearer token")
+			return
+		}
+
+		if strings.HasPrefix(token, auth.APITokenPrefix) {
+			t, err := auth.UserFromAPIToken(r.Context(), s.Store.APITokens, token)
+			if errors.Is(err, auth.ErrNoAPIToken) {
+				writeAPIError(w, http.StatusUnauthorized, "invalid_token", "token is invalid or revoked")
+				return
+			}
+			if err != nil {
+				writeAPIInternal(w, r, err)
+				return
+			}
+			if s.apiBarred(w, r, t.UserID) {
+				return
+			}
+			ctx := auth.WithUserID(r.Context(), t.UserID)
+			ctx = auth.WithScopes(ctx, t.Scopes)
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+
+		ses, err := auth.UserFromSession(r.Context(), s.Store.Sessions, token)
+		if err != nil || !ses.ExpiresAt.After(time.Now()) {
+			if err != nil && !errors.Is(err, auth.ErrNoSession) {
+				writeAPIInternal(w, r, err)
+				return
+			}
+			writeAPIError(w, http.StatusUnauthorized, "invalid_token", "token is invalid or expired")
+			return
+		}
+		if s.apiBarred(w, r, ses.UserID) {
+			return
+		}
+		// Expiración deslizante también para los tokens de sesión; el token no
+		// cambia, así que aquí no se rota
+		if renewed, _, err := auth.RenewSession(r.Context(), s.Store.Sessions, ses, s.sessionPolicy(), time.Now()); err != nil {
+			log.Printf("api session renew uid=%d err=%v", ses.UserID, err)
+		} else {
+			ses = renewed
+		}
+		if err := auth.TouchSession(r.Context(), s.Store.Sessions, ses, clientIP(r), time.Now()); err != nil {
+			log.Printf("api session touch uid=%d err=%v", ses.UserID, err)
+		}
+		ctx := auth.WithUserID(r.Context(), ses.UserID)
+		ctx = context.WithValue(ctx, ctxKeyBearer{}, token)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+type ctxKeyBearer struct{}
+
+// apiBarred responde 403 (code "banned" o "suspended") y mata las sesiones
+// si la cuenta de uid no puede entrar; devuelve true si ya respondió.
+func (s *Server) apiBarred(w http.ResponseWriter, r *http.Request, uid int64) bool {
+	u, err := s.Store.Users.ByID(r.Context(), uid)
+	if err != nil {
+		writeAPIInternal(w, r, err)
+		return true
+	}
+	sn := auth.Barred(u, time.Now())
+	if sn == nil {
+		return false
+	}
+	if err := s.Store.Sessions.DeleteForUser(r.Context(), uid); err != nil {
+		writeAPIInternal(w, r, err)
+		return true
+	}
+	writeAPIError(w, http.StatusForbidden, sn.Kind, sn.Error())
+	return true
+}
+
+func (s *Server) apiRequireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := auth.UserIDFrom(r.Context()); !ok {
+			writeAPIError(w, http.StatusUnauthorized, "unauthorized", "authentication required")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireScope rechaza con 403 los tokens personales que no tienen ese scope.
+func (s *Server) requireScope(scope string, h http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !auth.HasScope(r.Context(), scope) {
+			writeAPIError(w, http.StatusForbidden, "insufficient_scope", "token lacks the "+scope+" scope")
+			return
+		}
+		h(w, r)
+	})
+}
+
+// apiWrite = autenticado + scope + email verificado (según la política configurada).
+func (s *Server) apiWrite(scope string, h http.HandlerFunc) http.Handler {
+	return s.apiRequireAuth(s.requireScope(scope, func(w http.ResponseWriter, r *http.Request) {
+		if s.Cfg.EmailPolicy != app.EmailPolicyOff {
+			uid, _ := auth.UserIDFrom(r.Context())
+			ok, err := auth.IsEmailVerified(r.Context(), s.Store.Users, uid)
+			if err != nil {
+				writeAPIInternal(w, r, err)
+				return
+			}
+			if !ok {
+				writeAPIError(w, http.StatusForbidden, "email_not_verified", "confirm your email address first")
+				return
+			}
+		}
+		uid, _ := auth.UserIDFrom(r.Context())
+		err := auth.CheckWrite(r.Context(), s.Store.Users, uid)
+		var sn *auth.Sanction
+		if errors.As(err, &sn) {
+			writeAPIError(w, http.StatusForbidden, sn.Kind, sn.Error())
+			return
+		}
+		if err != nil {
+			writeAPIInternal(w, r, err)
+			return
+		}
+		h(w, r)
+	}))
+}
+
+func (s *Server) apiLogin(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+	if !decodeJSON(w, r, &in) {
+		return
+	}
+	sid, ses, err := auth.Login(r.Context(), s.Store.Users, s.Store.Sessions, in.Email, in.Password, auth.LoginOptions{
+		Lifetime:        s.Cfg.SessionLifetime,
+		MaxLifetime:     s.Cfg.SessionMaxAge,
+		Remember:        true, // no hay cookie; el cliente guarda el token
+		RequireVerified: s.Cfg.EmailPolicy == app.EmailPolicyLogin,
+		Attempts:        s.Store.LoginAttempts,
+		IP:              clientIP(r),
+		UserAgent:       r.UserAgent(),
+		TwoFactor:       s.Store.TwoFactor,
+		Tokens:          s.Store.Tokens,
+	})
+	s.apiLoginResult(w, r, sid, ses, err)
+}
+
+// POST /api/v1/auth/token/2fa {"challenge": "...", "code": "123456"}: segundo
+// paso para cuentas con TOTP; code también puede ser un código de recuperación.
+func (s *Server) apiLoginSecondFactor(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		Challenge string `json:"challenge"`
+		Code      string `json:"code"`
+	}
+	if !decodeJSON(w, r, &in) {
+		return
+	}
+	sid, ses, err := auth.LoginSecondFactor(r.Context(), s.Store.Users, s.Store.Sessions, s.Store.Tokens, s.Store.TwoFactor,
+		in.Challenge, in.Code, auth.LoginOptions{
+			Lifetime:    s.Cfg.SessionLifetime,
+			MaxLifetime: s.Cfg.SessionMaxAge,
+			Remember:    true,
+			Attempts:    s.Store.LoginAttempts,
+			IP:          clientIP(r),
+			UserAgent:   r.UserAgent(),
+		})
+	if errors.Is(err, auth.ErrInvalidToken) || errors.Is(err, auth.ErrTwoFactorOff) {
+		writeAPIError(w, http.StatusUnauthorized, "invalid_challenge", "challenge expired or already used; sign in again")
+		return
+	}
+	s.apiLoginResult(w, r, sid, ses, err)
+}
+
+// apiLoginResult responde a los dos pasos del login.
+func (s *Server) apiLoginResult(w http.ResponseWriter, r *http.Request, sid string, ses models.Session, err error) {
+	var sn *auth.Sanction
+	var th *auth.Throttled
+	var nsf *auth.NeedsSecondFactor
+	switch {
+	case errors.As(err, &nsf):
+		writeJSON(w, http.StatusUnauthorized, map[string]any{
+			"error":     apiError{Code: "second_factor_required", Message: "send a TOTP or recovery code to /auth/token/2fa"},
+			"challenge": nsf.Challenge,
+		})
+		return
+	case errors.Is(err, auth.ErrInvalidCode):
+		writeAPIError(w, http.StatusUnauthorized, "invalid_code", "invalid authentication code")
+		return
+	case errors.As(err, &th):
+		code := "too_many_attempts"
+		if th.Locked {
+			code = "locked"
+		}
+		w.Header().Set("Retry-After", strconv.Itoa(th.RetryAfter(time.Now())))
+		writeAPIError(w, http.StatusTooManyRequests, code, th.Message(time.Now()))
+		return
+	case errors.Is(err, auth.ErrInvalidLogin):
+		writeAPIError(w, http.StatusUnauthorized, "invalid_credentials", "invalid email or password")
+		return
+	case errors.As(err, &sn):
+		writeAPIError(w, http.StatusForbidden, sn.Kind, sn.Error())
+		return
+	case errors.Is(err, auth.ErrEmailNotVerified):
+		writeAPIError(w, http.StatusForbidden, "email_not_verified", "confirm your email address first")
+		return
+	case err != nil:
+		writeAPIInternal(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"token":      sid,
+		"token_type": "Bearer",
+		"expires_at": ses.ExpiresAt.UTC(),
+		"user_id":    ses.UserID,
+	})
+}
+
+func (s *Server) apiLogout(w http.ResponseWriter, r *http.Request) {
+	token, _ := r.Context().Value(ctxKeyBearer{}).(string)
+	if token == "" {
+		// los tokens personales se revocan desde /settings/tokens
+		writeAPIError(w, http.StatusBadRequest, "not_a_session", "personal tokens are revoked from the settings page")
+		return
+	}
+	if err := auth.Logout(r.Context(), s.Store.Sessions, token); err != nil {
+		writeAPIInternal(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) apiMe(w http.ResponseWriter, r *http.Request) {
+	uid, _ := auth.UserIDFrom(r.Context())
+	u, err := s.Store.Users.ByID(r.Context(), uid)
+	if err != nil {
+		writeAPIInternal(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"id": uid, "username": u.Username, "email": u.Email})
+}
+
+/* =========================
+   Lectura
+   ========================= */
+
+func (s *Server) apiCategories(w http.ResponseWriter, r *http.Request) {
+	cats, err := s.loadCategories(r.Context())
+	if err != nil {
+		writeAPIInternal(w, r, err)
+		return
+	}
+	out := make([]map[string]any, 0, len(cats))
+	for _, c := range cats {
+		out = append(out, map[string]any{"id": c.ID, "name": c.Name})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"categories": out})
+}
+
+// GET /api/v1/posts?cat=Go&mine=1&liked=1&after=<cursor>&limit=20
+// (mismos filtros y cursores que la portada)
+func (s *Server) apiListPosts(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	q := r.URL.Query()
+	f := postFilter{Category: q.Get("cat"), Mine: q.Has("mine"), Liked: q.Has("liked")}
+	uid, logged := auth.UserIDFrom(r.Context())
+	if (f.Mine || f.Liked) && !logged {
+		writeAPIError(w, http.StatusUnauthorized, "unauthorized", "the mine and liked filters require authentication")
+		return
+	}
+
+	pg, err := parsePageReq(q)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_cursor", err.Error())
+		return
+	}
+	page, err := s.listPosts(ctx, uid, f, pg, noComments)
+	if err != nil {
+		writeAPIInternal(w, r, err)
+		return
+	}
+	out := make([]apiPost, 0, len(page.Posts))
+	for _, p := range page.Posts {
+		out = append(out, toAPIPost(p, false))
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"posts":       out,
+		"next_cursor": page.Next, // pásalo como ?after=
+		"prev_cursor": page.Prev, // pásalo como ?before=
+	})
+}
+
+func (s *Server) apiGetPost(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(r)
+	if !ok {
+		writeAPIError(w, http.StatusBadRequest, "invalid_id", "post id must be a positive integer")
+		return
+	}
+	uid, _ := auth.UserIDFrom(r.Context())
+	p, err := s.getPost(r.Context(), uid, id)
+	if errors.Is(err, errNotFound) {
+		writeAPIError(w, http.StatusNotFound, "not_found", "post not found")
+		return
+	}
+	if err != nil {
+		writeAPIInternal(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toAPIPost(p, true))
+}
+
+/* =========================
+   Escritura
+   ========================= */
+
+func (s *Server) apiCreatePost(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		Title      string   `json:"title"`
+		Content    string   `json:"content"`
+		Categories []string `json:"categories"`
+	}
+	if !decodeJSON(w, r, &in) {
+		return
+	}
+	in.Title = strings.TrimSpace(in.Title)
+	in.Content = strings.TrimSpace(in.Content)
+	if in.Title == "" || in.Content == "" {
+		writeAPIError(w, http.StatusUnprocessableEntity, "validation", "title and content are required")
+		return
+	}
+	if len(in.Categories) == 0 {
+		writeAPIError(w, http.StatusUnprocessableEntity, "validation", "at least one category is required")
+		return
+	}
+
+	uid, _ := auth.UserIDFrom(r.Context())
+	pid, err := s.createPost(r.Context(), uid, in.Title, in.Content, in.Categories)
+	if err != nil {
+		writeAPIInternal(w, r, err)
+		return
+	}
+	p, err := s.getPost(r.Context(), uid, pid)
+	if err != nil {
+		writeAPIInternal(w, r, err)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("%s/posts/%d", apiPrefix, pid))
+	writeJSON(w, http.StatusCreated, toAPIPost(p, true))
+}
+
+func (s *Server) apiCreateComment(w http.ResponseWriter, r *http.Request) {
+	pid, ok := pathID(r)
+	if !ok {
+		writeAPIError(w, http.StatusBadRequest, "invalid_id", "post id must be a positive integer")
+		return
+	}
+	var in struct {
+		Content  string `json:"content"`
+		ParentID int64  `json:"parent_id"` // opcional: responde a ese comentario
+	}
+	if !decodeJSON(w, r, &in) {
+		return
+	}
+	in.Content = strings.TrimSpace(in.Content)
+	if in.Content == "" {
+		writeAPIError(w, http.StatusUnprocessableEntity, "validation", "content is required")
+		return
+	}
+	if in.ParentID < 0 {
+		writeAPIError(w, http.StatusUnprocessableEntity, "validation", "parent_id must be a positive integer")
+		return
+	}
+
+	uid, _ := auth.UserIDFrom(r.Context())
+	cid, err := s.createComment(r.Context(), uid, pid, in.ParentID, in.Content)
+	if errors.Is(err, errNotFound) {
+		msg := "post not found"
+		if in.ParentID != 0 {
+			msg = "post or parent comment not found"
+		}
+		writeAPIError(w, http.StatusNotFound, "not_found", msg)
+		return
+	}
+	if errors.Is(err, errLocked) {
+		writeAPIError(w, http.StatusConflict, "locked", "thread is locked")
+		return
+	}
+	if err != nil {
+		writeAPIInternal(w, r, err)
+		return
+	}
+	out := map[string]any{"id": cid, "post_id": pid, "content": in.Content}
+	if in.ParentID != 0 {
+		out["parent_id"] = in.ParentID
+	}
+	writeJSON(w, http.StatusCreated, out)
+}
+
+// PUT /api/v1/{posts|comments}/{id}/reaction  {"value": 1 | -1}
+func (s *Server) apiReact(target string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := pathID(r)
+		if !ok {
+			writeAPIError(w, http.StatusBadRequest, "invalid_id", target+" id must be a positive integer")
+			return
+		}
+		var in struct {
+			Value int `json:"value"`
+		}
+		if !decodeJSON(w, r, &in) {
+			return
+		}
+
+		uid, _ := auth.UserIDFrom(r.Context())
+		err := s.react(r.Context(), uid, target, id, in.Value)
+		switch {
+		case errors.Is(err, errBadRequest):
+			writeAPIError(w, http.StatusUnprocessableEntity, "validation", "value must be 1 or -1")
+		case errors.Is(err, errNotFound):
+			writeAPIError(w, http.StatusNotFound, "not_found", target+" not found")
+		case err != nil:
+			writeAPIInternal(w, r, err)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+}
+
+// DELETE /api/v1/{posts|comments}/{id}/reaction  (idempotente)
+func (s *Server) apiUnreact(target string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := pathID(r)
+		if !ok {
+			writeAPIError(w, http.StatusBadRequest, "invalid_id", target+" id must be a positive integer")
+			return
+		}
+		uid, _ := auth.UserIDFrom(r.Context())
+		if err := s.unreact(r.Context(), uid, target, id); err != nil {
+			writeAPIInternal(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,294 @@
+package httpx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"forum/internal/auth"
+	"forum/internal/models"
+	"forum/internal/store"
+	"forum/internal/util"
+)
+
+const (
+	auditPageSize  = 50
+	auditExportMax = 10000 // filas como mucho en /admin/audit/export
+)
+
+// audit apunta la acción de quien hace la request. Un fallo se loguea pero
+// no deshace la acción, que ya está hecha.
+func (s *Server) audit(r *http.Request, action, targetType string, targetID int64, before, after any) {
+	uid, _ := auth.UserIDFrom(r.Context())
+	if err := auth.Audit(r.Context(), s.Store.Audit, uid, clientIP(r), action, targetType, targetID, before, after); err != nil {
+		log.Printf("audit %s %s=%d by uid=%d: %v", action, targetType, targetID, uid, err)
+	}
+}
+
+// clientIP es la IP de la conexión. Detrás de un proxy sería la del proxy:
+// no se confía en X-Forwarded-For.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// Estado de un post o comentario tal como se audita.
+func auditPost(p models.Post) map[string]any {
+	return map[string]any{"title": p.Title, "content": p.Content, "author_id": p.UserID}
+}
+
+func auditComment(c models.Comment) map[string]any {
+	return map[string]any{"post_id": c.PostID, "content": c.Content, "author_id": c.UserID}
+}
+
+type auditEntryVM struct {
+	ID      int64
+	Created string
+	Actor   string // "" = línea de comandos
+	Action  string
+	Target  string
+	URL     string // enlace al objetivo, si tiene página
+	Before  string
+	After   string
+	IP      string
+}
+
+func toAuditEntryVM(e models.AuditEntry) auditEntryVM {
+	vm := auditEntryVM{
+		ID:      e.ID,
+		Created: e.CreatedAt.Local().Format("2006-01-02 15:04:05"),
+		Actor:   e.Actor,
+		Action:  e.Action,
+		Target:  fmt.Sprintf("%s #%d", e.TargetType, e.TargetID),
+		Before:  e.Before,
+		After:   e.After,
+		IP:      e.IP,
+	}
+	if e.ActorID != nil && e.Actor == "" {
+		vm.Actor = fmt.Sprintf("#%d", *e.ActorID)
+	}
+	switch e.TargetType {
+	case "post":
+		vm.URL = fmt.Sprintf("/post/%d", e.TargetID)
+	case "report":
+		vm.URL = "/mod/queue"
+	}
+	return vm
+}
+
+// parseAuditQuery lee los filtros de /admin/audit (y de su export y la API):
+// actor, action, type, id, from y to (AAAA-MM-DD; to incluye el día entero).
+func parseAuditQuery(q url.Values, loc *time.Location) (store.AuditQuery, error) {
+	aq := store.AuditQuery{
+		Actor:      strings.TrimSpace(q.Get("actor")),
+		Action:     strings.TrimSpace(q.Get("action")),
+		TargetType: q.Get("type"),
+	}
+	if v := q.Get("id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || id <= 0 {
+			return aq, fmt.Errorf("id must be a positive integer")
+		}
+		aq.TargetID = id
+	}
+	for _, k := range []string{"from", "to"} {
+		v := q.Get(k)
+		if v == "" {
+			continue
+		}
+		day, err := time.ParseInLocation("2006-01-02", v, loc)
+		if err != nil {
+			return aq, fmt.Errorf("%s: expects a date like 2024-01-31", k)
+		}
+		if k == "from" {
+			aq.From = day
+		} else {
+			aq.To = day.AddDate(0, 0, 1)
+		}
+	}
+	if v := q.Get("before"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || id <= 0 {
+			return aq, fmt.Errorf("before must be a positive integer")
+		}
+		aq.BeforeID = id
+	}
+	return aq, nil
+}
+
+// ---------------------------------------------------------------------------------
+// ------------HandleAdminAudit Function-------------------------------------------
+// GET /admin/audit: registro de acciones privilegiadas, lo más nuevo primero.
+func (s *Server) handleAdminAudit(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	var data pageData
+	data.Title = "Audit log"
+	data.AuditActions = auth.AuditActions
+	data.AuditFilter = r.URL.Query()
+
+	q, err := parseAuditQuery(r.URL.Query(), time.Local)
+	if err != nil {
+		data.Flash = err.Error()
+	} else {
+		q.Limit = auditPageSize
+		entries, more, err := s.Store.Audit.List(ctx, q)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		for _, e := range entries {
+			data.Audit = append(data.Audit, toAuditEntryVM(e))
+		}
+		if q.BeforeID != 0 {
+			data.PrevURL = auditURL("/admin/audit", r.URL.Query(), 0)
+		}
+		if more {
+			data.NextURL = auditURL("/admin/audit", r.URL.Query(), entries[len(entries)-1].ID)
+		}
+		data.ExportURL = auditURL("/admin/audit/export", r.URL.Query(), 0)
+	}
+	s.fillUserMeta(r.Context(), &data)
+	util.Render(w, "admin_audit.html", data)
+}
+
+// auditURL repite los filtros con otra página (before = 0: la primera).
+func auditURL(path string, filters url.Values, before int64) string {
+	v := url.Values{}
+	for k, vs := range filters {
+		if k != "before" && len(vs) > 0 && vs[0] != "" {
+			v.Set(k, vs[0])
+		}
+	}
+	if before != 0 {
+		v.Set("before", strconv.FormatInt(before, 10))
+	}
+	if len(v) == 0 {
+		return path
+	}
+	return path + "?" + v.Encode()
+}
+
+// apiAuditEntry es el formato JSON del export y de la API.
+type apiAuditEntry struct {
+	ID         int64           `json:"id"`
+	ActorID    *int64          `json:"actor_id"` // null = línea de comandos
+	Actor      string          `json:"actor,omitempty"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"target_type"`
+	TargetID   int64           `json:"target_id"`
+	Before     json.RawMessage `json:"before"`
+	After      json.RawMessage `json:"after"`
+	IP         string          `json:"ip"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+func toAPIAuditEntry(e models.AuditEntry) apiAuditEntry {
+	out := apiAuditEntry{
+		ID:         e.ID,
+		ActorID:    e.ActorID,
+		Actor:      e.Actor,
+		Action:     e.Action,
+		TargetType: e.TargetType,
+		TargetID:   e.TargetID,
+		IP:         e.IP,
+		CreatedAt:  e.CreatedAt.UTC(),
+	}
+	if e.Before != "" {
+		out.Before = json.RawMessage(e.Before)
+	}
+	if e.After != "" {
+		out.After = json.RawMessage(e.After)
+	}
+	return out
+}
+
+// ---------------------------------------------------------------------------------
+// ------------HandleAdminAuditExport Function-------------------------------------
+// GET /admin/audit/export: lo mismo que /admin/audit con sus filtros, entero
+// (hasta auditExportMax filas) y en JSON para descargar.
+func (s *Server) handleAdminAuditExport(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	q, err := parseAuditQuery(r.URL.Query(), time.Local)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	out := []apiAuditEntry{}
+	for len(out) < auditExportMax {
+		q.Limit = min(500, auditExportMax-len(out))
+		entries, more, err := s.Store.Audit.List(ctx, q)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		for _, e := range entries {
+			out = append(out, toAPIAuditEntry(e))
+		}
+		if !more {
+			break
+		}
+		q.BeforeID = entries[len(entries)-1].ID
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="audit-%s.json"`, time.Now().Format("20060102-150405")))
+	writeJSON(w, http.StatusOK, out)
+}
+
+// GET /api/v1/admin/audit?actor=&action=&type=&id=&from=&to=&before=&limit=
+// Solo admins, y con un token personal hace falta el scope admin.
+func (s *Server) apiAudit(w http.ResponseWriter, r *http.Request) {
+	uid, _ := auth.UserIDFrom(r.Context())
+	role, err := auth.UserRole(r.Context(), s.Store.Users, uid)
+	if err != nil {
+		writeAPIInternal(w, r, err)
+		return
+	}
+	if !auth.HasRole(role, auth.RoleAdmin) {
+		writeAPIError(w, http.StatusForbidden, "forbidden", "admins only")
+		return
+	}
+	q, err := parseAuditQuery(r.URL.Query(), time.UTC)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_filter", err.Error())
+		return
+	}
+	q.Limit = auditPageSize
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 500 {
+			writeAPIError(w, http.StatusBadRequest, "invalid_limit", "limit must be between 1 and 500")
+			return
+		}
+		q.Limit = n
+	}
+	entries, more, err := s.Store.Audit.List(r.Context(), q)
+	if err != nil {
+		writeAPIInternal(w, r, err)
+		return
+	}
+	out := make([]apiAuditEntry, 0, len(entries))
+	for _, e := range entries {
+		out = append(out, toAPIAuditEntry(e))
+	}
+	var next *int64
+	if more {
+		next = &entries[len(entries)-1].ID
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"entries":     out,
+		"next_before": next, // pásalo como ?before=
+	})
+}
@@ -0,0 +1,232 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"forum/internal/auth"
+	"forum/internal/util"
+)
+
+// canModify: el autor o un moderador/admin pueden editar y borrar.
+func (s *Server) canModify(ctx context.Context, owner int64) (bool, error) {
+	uid, ok := auth.UserIDFrom(ctx)
+	if !ok {
+		return false, nil
+	}
+	if uid == owner {
+		return true, nil
+	}
+	role, err := auth.UserRole(ctx, s.Store.Users, uid)
+	if err != nil {
+		return false, err
+	}
+	return auth.CanModerate(role), nil
+}
+
+// ---------------------------------------------------------------------------------
+// ------------HandlePostEdit Function---------------------------------------------
+func (s *Server) handlePostEdit(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, _ := strconv.ParseInt(r.PathValue("id"), 10, 64)
+
+	owner, err := s.postOwner(ctx, id)
+	if errors.Is(err, errNotFound) {
+		s.notFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if ok, err := s.canModify(ctx, owner); err != nil || !ok {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		title := strings.TrimSpace(r.FormValue("title"))
+		content := strings.TrimSpace(r.FormValue("content"))
+		if title == "" || content == "" {
+			http.Redirect(w, r, fmt.Sprintf("/post/%d/edit?err=%s", id, url.QueryEscape("Title and content required")), http.StatusSeeOther)
+			return
+		}
+		before, err := s.Store.Posts.Get(ctx, id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := s.updatePost(ctx, id, title, content); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		uid, _ := auth.UserIDFrom(ctx)
+		log.Printf("edit post id=%d by uid=%d", id, uid)
+		if uid != owner {
+			after := before
+			after.Title, after.Content = title, content
+			s.audit(r, auth.AuditPostEdit, "post", id, auditPost(before), auditPost(after))
+		}
+		http.Redirect(w, r, fmt.Sprintf("/post/%d", id), http.StatusSeeOther)
+		return
+	}
+
+	p, err := s.getPost(ctx, 0, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var data pageData
+	data.Title = "Edit post"
+	data.Post = &p
+	if e := r.URL.Query().Get("err"); e != "" {
+		data.Flash = e
+	}
+	s.fillUserMeta(ctx, &data)
+	util.Render(w, "post_edit.html", data)
+}
+
+// ---------------------------------------------------------------------------------
+// ------------HandlePostDelete Function-------------------------------------------
+func (s *Server) handlePostDelete(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, _ := strconv.ParseInt(r.PathValue("id"), 10, 64)
+
+	owner, err := s.postOwner(ctx, id)
+	if errors.Is(err, errNotFound) {
+		s.notFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if ok, err := s.canModify(ctx, owner); err != nil || !ok {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	before, err := s.Store.Posts.Get(ctx, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := s.deletePost(ctx, id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	uid, _ := auth.UserIDFrom(ctx)
+	log.Printf("delete post id=%d owner=%d by uid=%d", id, owner, uid)
+	// Los borrados se apuntan siempre, también los del propio autor
+	s.audit(r, auth.AuditPostDelete, "post", id, auditPost(before), nil)
+	http.Redirect(w, r, "/?deleted=1", http.StatusSeeOther)
+}
+
+// ---------------------------------------------------------------------------------
+// ------------HandleCommentEdit Function------------------------------------------
+func (s *Server) handleCommentEdit(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, _ := strconv.ParseInt(r.PathValue("id"), 10, 64)
+
+	owner, pid, err := s.commentOwner(ctx, id)
+	if errors.Is(err, errNotFound) {
+		s.notFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if ok, err := s.canModify(ctx, owner); err != nil || !ok {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		content := strings.TrimSpace(r.FormValue("content"))
+		if content == "" {
+			http.Redirect(w, r, fmt.Sprintf("/comment/%d/edit?err=%s", id, url.QueryEscape("Comment can't be empty")), http.StatusSeeOther)
+			return
+		}
+		before, err := s.Store.Comments.Get(ctx, id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := s.updateComment(ctx, id, content); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		uid, _ := auth.UserIDFrom(ctx)
+		log.Printf("edit comment id=%d by uid=%d", id, uid)
+		if uid != owner {
+			after := before
+			after.Content = content
+			s.audit(r, auth.AuditCommentEdit, "comment", id, auditComment(before), auditComment(after))
+		}
+		http.Redirect(w, r, fmt.Sprintf("/post/%d#comment-%d", pid, id), http.StatusSeeOther)
+		return
+	}
+
+	p, err := s.getPost(ctx, 0, pid)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var data pageData
+	data.Title = "Edit comment"
+	data.Post = &p
+	for i := range p.Comments {
+		if p.Comments[i].ID == id {
+			data.Comment = &p.Comments[i]
+		}
+	}
+	if data.Comment == nil {
+		s.notFound(w, r)
+		return
+	}
+	if e := r.URL.Query().Get("err"); e != "" {
+		data.Flash = e
+	}
+	s.fillUserMeta(ctx, &data)
+	util.Render(w, "comment_edit.html", data)
+}
+
+// ---------------------------------------------------------------------------------
+// ------------HandleCommentDelete Function----------------------------------------
+func (s *Server) handleCommentDelete(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, _ := strconv.ParseInt(r.PathValue("id"), 10, 64)
+
+	owner, pid, err := s.commentOwner(ctx, id)
+	if errors.Is(err, errNotFound) {
+		s.notFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if ok, err := s.canModify(ctx, owner); err != nil || !ok {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	before, err := s.Store.Comments.Get(ctx, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := s.deleteComment(ctx, id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	uid, _ := auth.UserIDFrom(ctx)
+	log.Printf("delete comment id=%d owner=%d by uid=%d", id, owner, uid)
+	s.audit(r, auth.AuditCommentDelete, "comment", id, auditComment(before), nil)
+	http.Redirect(w, r, fmt.Sprintf("/post/%d#comments", pid), http.StatusSeeOther)
+}
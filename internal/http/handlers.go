@@ -2,7 +2,6 @@ package httpx
 
 import (
 	"context"
-	"database/sql"
 	"errors"
 	"fmt"
 	"log"
@@ -14,44 +13,106 @@ import (
 
 	"forum/internal/app"
 	"forum/internal/auth"
+	"forum/internal/mail"
+	"forum/internal/store"
 	"forum/internal/util"
 )
 
 type Server struct {
-	DB  *sql.DB
-	Cfg app.Config
-	Mux *http.ServeMux
-}
-
-func NewServer(db *sql.DB, cfg app.Config) *Server {
-	s := &Server{DB: db, Cfg: cfg, Mux: http.NewServeMux()}
-	fs := http.FileServer(http.Dir("web/static"))
-	s.Mux.Handle("/static/", http.StripPrefix("/static/", fs))
-
-	// routes
-	s.Mux.Handle("/", s.withSession(http.HandlerFunc(s.handleIndex)))
-	s.Mux.Handle("/register", s.withSession(http.HandlerFunc(s.handleRegister)))
-	s.Mux.Handle("/login", s.withSession(http.HandlerFunc(s.handleLogin)))
-	s.Mux.Handle("/logout", s.withSession(http.HandlerFunc(s.handleLogout)))
-	s.Mux.Handle("/forgot", s.withSession(http.HandlerFunc(s.handleForgot)))
+	Store  *store.Store
+	Cfg    app.Config
+	Mux    *http.ServeMux
+	Mailer mail.Mailer
 
-	s.Mux.Handle("/post/new", s.withSession(s.requireAuth(http.HandlerFunc(s.handlePostNew))))
-	s.Mux.Handle("/post/create", s.withSession(s.requireAuth(http.HandlerFunc(s.handlePostCreate))))
-	s.Mux.Handle("/comment/create", s.withSession(s.requireAuth(http.HandlerFunc(s.handleCommentCreate))))
-	s.Mux.Handle("/react", s.withSession(s.requireAuth(http.HandlerFunc(s.handleReact))))
+	root http.Handler // Mux envuelto con los middlewares comunes
+}
 
-	s.Mux.Handle("/debug/me", s.withSession(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+func NewServer(st *store.Store, cfg app.Config) *Server {
+	s := &Server{Store: st, Cfg: cfg, Mux: http.NewServeMux(), Mailer: mail.FromConfig(cfg.MailDir)}
+
+	// routes (todas pasan por withSession + withCSRF, ver abajo). Cada ruta
+	// declara sus métodos: las que cambian algo solo existen por POST, y el
+	// CSRF no deja pasar un POST sin token. Los handlers no miran r.Method
+	// salvo para elegir entre pintar (GET) y procesar (POST).
+	s.Mux.Handle("/", http.HandlerFunc(s.handleNoRoute))
+	s.Mux.Handle("GET /{$}", http.HandlerFunc(s.handleIndex))
+	s.Mux.Handle("GET /register", http.HandlerFunc(s.handleRegister))
+	s.Mux.Handle("POST /register", http.HandlerFunc(s.handleRegister))
+	s.Mux.Handle("GET /login", http.HandlerFunc(s.handleLogin))
+	s.Mux.Handle("POST /login", http.HandlerFunc(s.handleLogin))
+	s.Mux.Handle("GET /login/2fa", http.HandlerFunc(s.handleLoginSecondFactor))
+	s.Mux.Handle("POST /login/2fa", http.HandlerFunc(s.handleLoginSecondFactor))
+	s.Mux.Handle("POST /logout", http.HandlerFunc(s.handleLogout))
+	s.Mux.Handle("GET /forgot", http.HandlerFunc(s.handleForgot))
+	s.Mux.Handle("POST /forgot", http.HandlerFunc(s.handleForgot))
+	s.Mux.Handle("GET /reset", http.HandlerFunc(s.handleReset))
+	s.Mux.Handle("POST /reset", http.HandlerFunc(s.handleReset))
+	s.Mux.Handle("GET /verify", http.HandlerFunc(s.handleVerify))
+	s.Mux.Handle("POST /verify/resend", http.HandlerFunc(s.handleVerifyResend))
+	s.Mux.Handle("GET /email/confirm", http.HandlerFunc(s.handleEmailConfirm))
+
+	s.Mux.Handle("GET /search", http.HandlerFunc(s.handleSearch))
+	s.Mux.Handle("GET /post/{id}", http.HandlerFunc(s.handlePostView))
+	postEdit := s.requireAuth(s.requireVerified(s.requireWritable(http.HandlerFunc(s.handlePostEdit))))
+	s.Mux.Handle("GET /post/{id}/edit", postEdit)
+	s.Mux.Handle("POST /post/{id}/edit", postEdit)
+	// Borrar lo propio sigue permitido aunque la cuenta esté silenciada (sin
+	// requireWritable): quitar contenido no molesta a nadie. Lo mismo en comentarios.
+	s.Mux.Handle("POST /post/{id}/delete", s.requireAuth(http.HandlerFunc(s.handlePostDelete)))
+	commentEdit := s.requireAuth(s.requireVerified(s.requireWritable(http.HandlerFunc(s.handleCommentEdit))))
+	s.Mux.Handle("GET /comment/{id}/edit", commentEdit)
+	s.Mux.Handle("POST /comment/{id}/edit", commentEdit)
+	s.Mux.Handle("POST /comment/{id}/delete", s.requireAuth(http.HandlerFunc(s.handleCommentDelete)))
+	s.Mux.Handle("GET /post/new", s.requireAuth(s.requireVerified(s.requireWritable(http.HandlerFunc(s.handlePostNew)))))
+	s.Mux.Handle("POST /post/create", s.requireAuth(s.requireVerified(s.requireWritable(http.HandlerFunc(s.handlePostCreate)))))
+	s.Mux.Handle("POST /comment/create", s.requireAuth(s.requireVerified(s.requireWritable(http.HandlerFunc(s.handleCommentCreate)))))
+	s.Mux.Handle("POST /react", s.requireAuth(s.requireVerified(s.requireWritable(http.HandlerFunc(s.handleReact)))))
+
+	// moderación (ver moderation.go)
+	s.Mux.Handle("POST /post/{id}/lock", s.requireAuth(s.requireRole(auth.RoleModerator, http.HandlerFunc(s.handlePostLock))))
+	s.Mux.Handle("POST /post/{id}/pin", s.requireAuth(s.requireRole(auth.RoleModerator, http.HandlerFunc(s.handlePostPin))))
+	s.Mux.Handle("POST /report", s.requireAuth(s.requireVerified(s.requireWritable(http.HandlerFunc(s.handleReport)))))
+	s.Mux.Handle("GET /mod/queue", s.requireAuth(s.requireRole(auth.RoleModerator, http.HandlerFunc(s.handleModQueue))))
+	s.Mux.Handle("POST /mod/reports/{id}/{action}", s.requireAuth(s.requireRole(auth.RoleModerator, http.HandlerFunc(s.handleReportClose))))
+	s.Mux.Handle("GET /mod/users", s.requireAuth(s.requireRole(auth.RoleModerator, http.HandlerFunc(s.handleModUser))))
+	s.Mux.Handle("POST /mod/users/{id}/{action}", s.requireAuth(s.requireRole(auth.RoleModerator, http.HandlerFunc(s.handleUserSanction))))
+
+	// administración (ver audit.go y moderation.go)
+	s.Mux.Handle("GET /admin/audit", s.requireAuth(s.requireRole(auth.RoleAdmin, http.HandlerFunc(s.handleAdminAudit))))
+	s.Mux.Handle("GET /admin/audit/export", s.requireAuth(s.requireRole(auth.RoleAdmin, http.HandlerFunc(s.handleAdminAuditExport))))
+	s.Mux.Handle("POST /admin/users/{id}/unlock", s.requireAuth(s.requireRole(auth.RoleAdmin, http.HandlerFunc(s.handleUserUnlock))))
+
+	s.Mux.Handle("GET /settings", s.requireAuth(http.HandlerFunc(s.handleSettingsAccount)))
+	s.Mux.Handle("POST /settings/{action}", s.requireAuth(http.HandlerFunc(s.handleSettingsAccountAction)))
+	s.Mux.Handle("GET /settings/tokens", s.requireAuth(http.HandlerFunc(s.handleSettingsTokens)))
+	s.Mux.Handle("POST /settings/tokens", s.requireAuth(http.HandlerFunc(s.handleSettingsTokens)))
+	s.Mux.Handle("POST /settings/tokens/revoke", s.requireAuth(http.HandlerFunc(s.handleSettingsTokenRevoke)))
+	s.Mux.Handle("GET /settings/2fa", s.requireAuth(http.HandlerFunc(s.handleSettings2FA)))
+	s.Mux.Handle("POST /settings/2fa/{action}", s.requireAuth(http.HandlerFunc(s.handleSettings2FAAction)))
+	s.Mux.Handle("GET /settings/sessions", s.requireAuth(http.HandlerFunc(s.handleSettingsSessions)))
+	s.Mux.Handle("POST /settings/sessions/revoke", s.requireAuth(http.HandlerFunc(s.handleSettingsSessionRevoke)))
+	s.Mux.Handle("POST /settings/sessions/others", s.requireAuth(http.HandlerFunc(s.handleSettingsSessionsOthers)))
+
+	s.Mux.Handle("GET /debug/me", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if uid, ok := auth.UserIDFrom(r.Context()); ok {
 			w.Write([]byte(fmt.Sprintf("logged uid=%d", uid)))
 			return
 		}
 		w.Write([]byte("anon"))
-	})))
+	}))
+
+	// Los estáticos no necesitan sesión; el resto siempre lleva sesión + CSRF
+	root := http.NewServeMux()
+	fs := http.FileServer(http.Dir("web/static"))
+	root.Handle("/static/", http.StripPrefix("/static/", fs))
+	root.Handle(apiPrefix+"/", s.apiRoutes()) // JSON + Bearer, sin cookies
+	root.Handle("/", s.withSession(s.withCSRF(s.Mux)))
+	s.root = root
 
 	return s
 }
 
-func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) { s.Mux.ServeHTTP(w, r) }
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) { s.root.ServeHTTP(w, r) }
 
 type pageData struct {
 	Title      string
@@ -59,14 +120,59 @@ type pageData struct {
 	UserID     int64
 	Username   string
 	UserInitial string
+	IsMod       bool // moderador o admin: puede editar/borrar contenido ajeno
+	IsAdmin     bool // admin: ve el registro de auditoría
+	NeedsVerify bool // sesión con email sin verificar (muestra aviso + reenviar)
+	CSRFToken  string // para los formularios POST (ver withCSRF)
+	CurrentURL string // para volver a la misma página tras un POST
+	NextURL    string // página siguiente (posts más antiguos)
+	PrevURL    string // página anterior (posts más nuevos)
 	Categories []catVM
 	Posts      []postVM
+	Pinned     []postVM   // fijados, arriba de la primera página de la portada
+	Post       *postVM    // página /post/{id}
+	Comment    *commentVM // página /comment/{id}/edit
 	Filters    struct {
 		Category string
 		Mine     bool
 		Liked    bool
 	}
     FlashOK bool //  true = éxito, false = error
+
+	// /search
+	SearchQ  string // también rellena la caja de búsqueda de la cabecera
+	Searched bool
+	Hits     []searchHitVM
+
+	// /mod/queue
+	Reports []reportVM
+
+	// /mod/users
+	Target *modUserVM
+
+	Sanction *sanctionVM // página de cuenta baneada/suspendida/silenciada
+	Muted    *sanctionVM // aviso en la cabecera mientras dure el silencio
+
+	// /admin/audit
+	Audit        []auditEntryVM
+	AuditActions []string   // sugerencias para el filtro de acción
+	AuditFilter  url.Values // filtros tal como llegaron, para rellenar el formulario
+	ExportURL    string
+
+	// /settings
+	Account *accountVM
+
+	// /settings/tokens
+	Tokens    []apiTokenVM
+	AllScopes []string
+	NewToken  string // token recién creado (solo se muestra una vez)
+
+	// /settings/2fa
+	TwoFactor     *twoFactorVM
+	RecoveryCodes []string // recién generados (solo se muestran una vez)
+
+	// /settings/sessions
+	Sessions []sessionVM
 }
 
 type catVM struct {
@@ -74,20 +180,43 @@ type catVM struct {
 	Name string
 }
 type commentVM struct {
-	ID      int64
-	Author  string
-	Content string
-	Created string
+	ID         int64
+	ParentID   int64 // 0 = primer nivel
+	AuthorID   int64
+	Author     string
+	Content    string
+	Created    string
+	CreatedAt  time.Time
+	Edited     bool
+	Likes      int
+	Dislikes   int
+	MyReaction int          // reacción de quien mira: 1, -1 o 0
+	ReplyTo    string       // autor al que responde si se aplanó (ver buildThread)
+	Replies    []*commentVM // respuestas que se pintan debajo, en orden cronológico
+	Total      int          // respuestas en todo el subárbol
 }
 type postVM struct {
 	ID                     int64
+	AuthorID               int64
 	Title, Content, Author string
 	Created                string
+	CreatedAt              time.Time
+	Edited                 bool
+	Locked, Pinned         bool
 	Likes, Dislikes        int
+	MyReaction             int // reacción de quien mira: 1, -1 o 0
 	Cats                   []string
-	Comments               []commentVM // ⬅️ nuevo
+	Comments               []commentVM  // lista plana, en orden cronológico
+	Thread                 []*commentVM // los mismos, en árbol (ver buildThread)
+	CommentCount           int          // total, aunque Comments sea solo un extracto
 }
 
+// commentsPreview es cuántos comentarios (los últimos) se ven en la portada.
+const commentsPreview = 3
+
+// maxPinned es cuántos posts fijados caben encima de la portada.
+const maxPinned = 5
+
 // ------------------------------------------------------------------------------
 // ------------HandlerIndex Function---------------------------------------------
 func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
@@ -107,189 +236,39 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
 	// ---------------------------
 	// Cargar categorías
 	// ---------------------------
-	rows, err := s.DB.QueryContext(ctx, `SELECT id, name FROM categories ORDER BY name`)
+	cats, err := s.loadCategories(ctx)
 	if err != nil {
-		http.Error(w, "categories query: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-	var cats []catVM
-	for rows.Next() {
-		var c catVM
-		if err := rows.Scan(&c.ID, &c.Name); err != nil {
-			_ = rows.Close()
-			http.Error(w, "categories scan: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-		cats = append(cats, c)
-	}
-	if err := rows.Close(); err != nil {
-		http.Error(w, "categories close: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-	if err := rows.Err(); err != nil {
-		http.Error(w, "categories err: "+err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	// ---------------------------
-	// Query de posts + filtros (PostgreSQL)
+	// Posts + filtros (ver listPosts)
 	// ---------------------------
-	var (
-		args []any
-		sb   strings.Builder
-	)
-	// helper para numerar $1, $2, ...
-	nextArg := func() string { return fmt.Sprintf("$%d", len(args)+1) }
-
-	sb.WriteString(`
-SELECT
-  p.id, p.title, p.content, u.username,
-  COUNT(*) FILTER (WHERE r.value = 1)  AS likes,
-  COUNT(*) FILTER (WHERE r.value = -1) AS dislikes,
-  p.created_at
-FROM posts p
-JOIN users u ON u.id = p.user_id
-LEFT JOIN reactions r
-  ON r.target_type = 'post'
- AND r.target_id  = p.id
-`)
-
-	if qCat != "" || qMine || qLiked {
-		sb.WriteString("WHERE 1=1 ")
-	}
-	if qCat != "" {
-		sb.WriteString(`
-  AND EXISTS (
-        SELECT 1
-          FROM post_categories pc
-          JOIN categories c ON c.id = pc.category_id
-         WHERE pc.post_id = p.id
-           AND c.name = ` + nextArg() + `
-      )
-`)
-		args = append(args, qCat)
-	}
-	if qMine && uid != 0 {
-		sb.WriteString("  AND p.user_id = " + nextArg() + " ")
-		args = append(args, uid)
-	}
-	if qLiked && uid != 0 {
-		sb.WriteString(`
-  AND EXISTS (
-        SELECT 1
-          FROM reactions rx
-         WHERE rx.user_id     = ` + nextArg() + `
-           AND rx.target_type = 'post'
-           AND rx.target_id   = p.id
-           AND rx.value       = 1
-      )
-`)
-		args = append(args, uid)
-	}
-
-	// En Postgres deben agruparse TODOS los no agregados
-	sb.WriteString(`
-GROUP BY p.id, p.title, p.content, u.username, p.created_at
-ORDER BY p.created_at DESC
-LIMIT 100
-`)
-
-	rows2, err := s.DB.QueryContext(ctx, sb.String(), args...)
+	pg, err := parsePageReq(r.URL.Query())
 	if err != nil {
-		http.Error(w, "posts query: "+err.Error(), http.StatusInternalServerError)
+		http.Redirect(w, r, "/?err="+url.QueryEscape("Invalid page link"), http.StatusSeeOther)
 		return
 	}
+	// En la portada solo un extracto de comentarios; el hilo completo está en /post/{id}.
+	// Los fijados van aparte, encima de la primera página, y no se repiten abajo.
+	f := postFilter{Category: qCat, Mine: qMine, Liked: qLiked, Pinned: store.PinExclude}
+	page, err := s.listPosts(ctx, uid, f, pg, commentsPreview)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	posts := page.Posts
 
-	var posts []postVM
-	for rows2.Next() {
-		var p postVM
-		var created time.Time
-		if err := rows2.Scan(&p.ID, &p.Title, &p.Content, &p.Author, &p.Likes, &p.Dislikes, &created); err != nil {
-			_ = rows2.Close()
-			http.Error(w, "posts scan: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-		p.Created = created.Format("2006-01-02 15:04")
-
-		// Categorías del post
-		rc, err := s.DB.QueryContext(ctx, `
-SELECT c.name
-  FROM post_categories pc
-  JOIN categories c ON c.id = pc.category_id
- WHERE pc.post_id = $1
- ORDER BY c.name
-`, p.ID)
-		if err != nil {
-			_ = rows2.Close()
-			http.Error(w, "post categories query: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-		for rc.Next() {
-			var n string
-			if err := rc.Scan(&n); err != nil {
-				_ = rc.Close()
-				_ = rows2.Close()
-				http.Error(w, "post categories scan: "+err.Error(), http.StatusInternalServerError)
-				return
-			}
-			p.Cats = append(p.Cats, n)
-		}
-		if err := rc.Close(); err != nil {
-			_ = rows2.Close()
-			http.Error(w, "post categories close: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-		if err := rc.Err(); err != nil {
-			_ = rows2.Close()
-			http.Error(w, "post categories err: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-
-		// Comentarios del post
-		rcm, err := s.DB.QueryContext(ctx, `
-SELECT c.id, u.username, c.content, c.created_at
-  FROM comments c
-  JOIN users u ON u.id = c.user_id
- WHERE c.post_id = $1
- ORDER BY c.created_at ASC
-`, p.ID)
+	var pinned []postVM
+	if pg.After == nil && pg.Before == nil {
+		f.Pinned = store.PinOnly
+		pp, err := s.listPosts(ctx, uid, f, pageReq{Limit: maxPinned}, commentsPreview)
 		if err != nil {
-			_ = rows2.Close()
-			http.Error(w, "comments query: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-		for rcm.Next() {
-			var cm commentVM
-			var ctime time.Time
-			if err := rcm.Scan(&cm.ID, &cm.Author, &cm.Content, &ctime); err != nil {
-				_ = rcm.Close()
-				_ = rows2.Close()
-				http.Error(w, "comments scan: "+err.Error(), http.StatusInternalServerError)
-				return
-			}
-			cm.Created = ctime.Format("2006-01-02 15:04")
-			p.Comments = append(p.Comments, cm)
-		}
-		if err := rcm.Close(); err != nil {
-			_ = rows2.Close()
-			http.Error(w, "comments close: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-		if err := rcm.Err(); err != nil {
-			_ = rows2.Close()
-			http.Error(w, "comments err: "+err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-
-		posts = append(posts, p)
-	}
-	if err := rows2.Close(); err != nil {
-		http.Error(w, "posts close: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-	if err := rows2.Err(); err != nil {
-		http.Error(w, "posts err: "+err.Error(), http.StatusInternalServerError)
-		return
+		pinned = pp.Posts
 	}
 
 	// ---------------------------
@@ -300,6 +279,14 @@ SELECT c.id, u.username, c.content, c.created_at
 	data.UserID = uid
 	data.Categories = cats
 	data.Posts = posts
+	data.Pinned = pinned
+	data.CurrentURL = r.URL.RequestURI()
+	if page.Next != "" {
+		data.NextURL = pageURL("/", r.URL.Query(), "after", page.Next)
+	}
+	if page.Prev != "" {
+		data.PrevURL = pageURL("/", r.URL.Query(), "before", page.Prev)
+	}
 	data.Filters.Category = qCat
 	data.Filters.Mine = qMine
 	data.Filters.Liked = qLiked
@@ -308,6 +295,18 @@ SELECT c.id, u.username, c.content, c.created_at
 		data.Flash = "Post created successfully"
 		data.FlashOK = true
 	}
+	if r.URL.Query().Get("deleted") == "1" {
+		data.Flash = "Post deleted"
+		data.FlashOK = true
+	}
+	if r.URL.Query().Get("verified") == "1" {
+		data.Flash = "Email verified, thanks!"
+		data.FlashOK = true
+	}
+	if r.URL.Query().Get("verify_sent") == "1" {
+		data.Flash = "We sent you a new verification link"
+		data.FlashOK = true
+	}
 	if r.URL.Query().Get("err") != "" {
 		data.Flash = r.URL.Query().Get("err")
 		data.FlashOK = false
@@ -317,12 +316,86 @@ SELECT c.id, u.username, c.content, c.created_at
 	util.Render(w, "index.html", data)
 }
 
+// ---------------------------------------------------------------------------------
+// ------------HandlePostView Function---------------------------------------------
+func (s *Server) handlePostView(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		s.notFound(w, r)
+		return
+	}
+	uid, _ := auth.UserIDFrom(ctx)
+	p, err := s.getPost(ctx, uid, id)
+	if errors.Is(err, errNotFound) {
+		s.notFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var data pageData
+	data.Title = p.Title
+	data.Post = &p
+	data.CurrentURL = r.URL.RequestURI()
+	if e := r.URL.Query().Get("err"); e != "" {
+		data.Flash = e
+	}
+	if r.URL.Query().Get("reported") == "1" {
+		data.Flash = "Thanks, a moderator will take a look"
+		data.FlashOK = true
+	}
+	s.fillUserMeta(r.Context(), &data)
+	util.Render(w, "post.html", data)
+}
+
+// notFound pinta la página 404 con el layout normal.
+// handleNoRoute recibe lo que no casa con ninguna ruta: 405 si la ruta existe
+// con otro método (p. ej. GET /logout), 404 si no existe.
+func (s *Server) handleNoRoute(w http.ResponseWriter, r *http.Request) {
+	var allow []string
+	for _, m := range []string{http.MethodGet, http.MethodPost} {
+		probe := r.Clone(r.Context())
+		probe.Method = m
+		if _, pattern := s.Mux.Handler(probe); pattern != "/" {
+			allow = append(allow, m)
+		}
+	}
+	if len(allow) > 0 {
+		w.Header().Set("Allow", strings.Join(allow, ", "))
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.notFound(w, r)
+}
+
+func (s *Server) notFound(w http.ResponseWriter, r *http.Request) {
+	var data pageData
+	data.Title = "Not found"
+	s.fillUserMeta(r.Context(), &data)
+	util.RenderStatus(w, http.StatusNotFound, "not_found.html", data)
+}
+
+// safeNext devuelve next si es una ruta local ("/..."), o fallback si no.
+// Evita open redirects del tipo next=//evil.com.
+func safeNext(next, fallback string) string {
+	if strings.HasPrefix(next, "/") && !strings.HasPrefix(next, "//") && !strings.HasPrefix(next, "/\\") {
+		return next
+	}
+	return fallback
+}
+
 //---------------------------------------------------------------------------------
 //------------HandleRegistre Function-----------------------------------------------
 
 func (s *Server) handleRegister(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodGet {
 		util.Render(w, "auth_register.html", map[string]any{
+			"CSRFToken": csrfToken(r.Context()),
 			"Error":    r.URL.Query().Get("err"),
 			"Email":    r.URL.Query().Get("email"),
 			"Username": r.URL.Query().Get("username"),
@@ -339,7 +412,8 @@ func (s *Server) handleRegister(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := auth.Register(s.DB, email, username, password); err != nil {
+	uid, err := auth.Register(r.Context(), s.Store.Users, email, username, password)
+	if err != nil {
 		msg := "Internal+error"
 		if errors.Is(err, auth.ErrEmailTaken) {
 			msg = "Email+already+taken"
@@ -350,30 +424,166 @@ func (s *Server) handleRegister(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	http.Redirect(w, r, "/login?ok=1", http.StatusSeeOther)
+	if s.Cfg.EmailPolicy == app.EmailPolicyOff {
+		http.Redirect(w, r, "/login?ok=1", http.StatusSeeOther)
+		return
+	}
+	if err := s.sendVerification(r.Context(), uid); err != nil {
+		log.Printf("register: send verification uid=%d err: %v", uid, err)
+	}
+	http.Redirect(w, r, "/login?ok=1&verify=1", http.StatusSeeOther)
+}
+
+// ---------------------------------------------------------------------------------
+// ------------HandleVerify Function-----------------------------------------------
+func (s *Server) handleVerify(w http.ResponseWriter, r *http.Request) {
+	_, err := auth.VerifyEmail(r.Context(), s.Store.Users, s.Store.Tokens, r.URL.Query().Get("token"))
+	if err != nil {
+		if !errors.Is(err, auth.ErrInvalidToken) {
+			log.Printf("verify: err: %v", err)
+		}
+		util.Render(w, "auth_verify.html", map[string]any{
+			"CSRFToken": csrfToken(r.Context()),
+			"Error": "This verification link is invalid or has expired.",
+		})
+		return
+	}
+	if _, ok := auth.UserIDFrom(r.Context()); ok {
+		http.Redirect(w, r, "/?verified=1", http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, "/login?verified=1", http.StatusSeeOther)
+}
+
+// handleVerifyResend reenvía el enlace al usuario de la sesión o, sin sesión,
+// al email del formulario (sin revelar si existe).
+func (s *Server) handleVerifyResend(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	uid, logged := auth.UserIDFrom(ctx)
+	if !logged {
+		id, err := auth.UserIDByEmail(ctx, s.Store.Users, r.FormValue("email"))
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			log.Printf("verify resend: lookup err: %v", err)
+		}
+		uid = id
+	}
+	if uid != 0 {
+		if err := s.sendVerification(ctx, uid); err != nil {
+			log.Printf("verify resend uid=%d err: %v", uid, err)
+		}
+	}
+
+	if logged {
+		http.Redirect(w, r, "/?verify_sent=1", http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, "/login?verify=1", http.StatusSeeOther)
+}
+
+// sendVerification crea un token nuevo y manda el enlace por email.
+// No hace nada si el usuario ya está verificado.
+func (s *Server) sendVerification(ctx context.Context, uid int64) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	token, email, err := auth.CreateEmailVerification(ctx, s.Store.Users, s.Store.Tokens, uid, s.Cfg.VerifyTokenTTL)
+	if err != nil || token == "" {
+		return err
+	}
+	link := s.Cfg.BaseURL + "/verify?token=" + url.QueryEscape(token)
+	return s.Mailer.Send(ctx, mail.Message{
+		To:      email,
+		Subject: "Confirm your forum email address",
+		Body: "Welcome to the forum!\n\n" +
+			"Please confirm your email address by opening this link:\n" +
+			link + "\n\nIf you didn't create an account, just ignore this email.",
+	})
 }
 
 // ---------------------------------------------------------------------------------
 // ------------HandleForgot Function-----------------------------------------------
 func (s *Server) handleForgot(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodGet {
-		util.Render(w, "auth_forgot.html", nil)
+		util.Render(w, "auth_forgot.html", map[string]any{
+			"CSRFToken": csrfToken(r.Context()),
+		})
 		return
 	}
 	// POST: no revelar si el email existe (buena práctica)
-	_ = strings.TrimSpace(r.FormValue("email"))
-	// Aquí en el futuro: generar token, guardar y enviar email.
+	email := strings.TrimSpace(r.FormValue("email"))
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	token, err := auth.CreatePasswordReset(ctx, s.Store.Users, s.Store.Tokens, email, s.Cfg.ResetTokenTTL)
+	if err != nil {
+		log.Printf("forgot: create token err: %v", err)
+	}
+	if token != "" {
+		link := s.Cfg.BaseURL + "/reset?token=" + url.QueryEscape(token)
+		msg := mail.Message{
+			To:      strings.ToLower(email),
+			Subject: "Reset your forum password",
+			Body: "Someone asked to reset the password for this account.\n\n" +
+				"Open this link to choose a new one (valid for " + s.Cfg.ResetTokenTTL.String() + "):\n" +
+				link + "\n\nIf it wasn't you, just ignore this email.",
+		}
+		if err := s.Mailer.Send(ctx, msg); err != nil {
+			log.Printf("forgot: send mail err: %v", err)
+		}
+	}
 	http.Redirect(w, r, "/login?reset=1", http.StatusSeeOther)
 }
 
+// ---------------------------------------------------------------------------------
+// ------------HandleReset Function-----------------------------------------------
+func (s *Server) handleReset(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		util.Render(w, "auth_reset.html", map[string]any{
+			"CSRFToken": csrfToken(r.Context()),
+			"Token": r.URL.Query().Get("token"),
+			"Error": r.URL.Query().Get("err"),
+		})
+		return
+	}
+
+	token := r.FormValue("token")
+	password := r.FormValue("password")
+	back := "/reset?token=" + url.QueryEscape(token) + "&err="
+
+	if password != r.FormValue("password2") {
+		http.Redirect(w, r, back+"Passwords+do+not+match", http.StatusSeeOther)
+		return
+	}
+
+	err := auth.ResetPassword(r.Context(), s.Store.Tokens, token, password)
+	switch {
+	case err == nil:
+		http.Redirect(w, r, "/login?pwreset=1", http.StatusSeeOther)
+	case errors.Is(err, auth.ErrInvalidToken):
+		http.Redirect(w, r, back+url.QueryEscape("This reset link is invalid or has expired"), http.StatusSeeOther)
+	case errors.Is(err, auth.ErrWeakPassword):
+		http.Redirect(w, r, back+url.QueryEscape(err.Error()), http.StatusSeeOther)
+	default:
+		log.Printf("reset: err: %v", err)
+		http.Redirect(w, r, back+"Internal+error", http.StatusSeeOther)
+	}
+}
+
 //---------------------------------------------------------------------------------
 //------------HandleLogin Function-----------------------------------------------
 
 func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodGet {
 		util.Render(w, "auth_login.html", map[string]any{
+			"CSRFToken": csrfToken(r.Context()),
 			"OK":    r.URL.Query().Get("ok") == "1",
 			"Reset": r.URL.Query().Get("reset") == "1",
+			"PasswordReset": r.URL.Query().Get("pwreset") == "1",
+			"Verify":        r.URL.Query().Get("verify") == "1",
+			"Verified":      r.URL.Query().Get("verified") == "1",
+			"Unverified":    r.URL.Query().Get("unverified") == "1",
 			"Error": r.URL.Query().Get("err"),
 			"Email": r.URL.Query().Get("email"),
 		})
@@ -382,8 +592,40 @@ func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 
 	email := strings.TrimSpace(r.FormValue("email"))
 	password := r.FormValue("password")
-
-	sid, uid, err := auth.Login(s.DB, email, password, s.Cfg.SessionLifetime)
+	remember := r.FormValue("remember") != ""
+
+	sid, ses, err := auth.Login(r.Context(), s.Store.Users, s.Store.Sessions, email, password, auth.LoginOptions{
+		Lifetime:        s.Cfg.SessionLifetime,
+		MaxLifetime:     s.Cfg.SessionMaxAge,
+		Remember:        remember,
+		RequireVerified: s.Cfg.EmailPolicy == app.EmailPolicyLogin,
+		Attempts:        s.Store.LoginAttempts,
+		IP:              clientIP(r),
+		UserAgent:       r.UserAgent(),
+		TwoFactor:       s.Store.TwoFactor,
+		Tokens:          s.Store.Tokens,
+	})
+	var nsf *auth.NeedsSecondFactor
+	if errors.As(err, &nsf) {
+		s.renderSecondFactor(w, r, nsf.Challenge, remember, "", http.StatusOK)
+		return
+	}
+	var th *auth.Throttled
+	if errors.As(err, &th) {
+		log.Printf("login THROTTLED email=%s ip=%s until=%s", email, clientIP(r), th.Until.Format(time.RFC3339))
+		http.Redirect(w, r, "/login?err="+url.QueryEscape(th.Message(time.Now()))+"&email="+url.QueryEscape(email), http.StatusSeeOther)
+		return
+	}
+	if errors.Is(err, auth.ErrEmailNotVerified) {
+		http.Redirect(w, r, "/login?unverified=1&err=Please+confirm+your+email+address+first&email="+url.QueryEscape(email), http.StatusSeeOther)
+		return
+	}
+	var sn *auth.Sanction
+	if errors.As(err, &sn) {
+		log.Printf("login FAIL email=%s err=%v", email, err)
+		s.renderSanction(w, r, sn)
+		return
+	}
 	if err != nil {
 		// registra el fallo para saber por qué
 		log.Printf("login FAIL email=%s err=%v", email, err)
@@ -392,24 +634,34 @@ func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// solo aquí el login es OK
-	log.Printf("login OK email=%s uid=%d sid=%s", email, uid, sid)
+	log.Printf("login OK email=%s uid=%d", email, ses.UserID)
+
+	s.setSessionCookie(w, sid, ses.Persistent, ses.ExpiresAt)
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
 
-	http.SetCookie(w, &http.Cookie{
+// setSessionCookie entrega la sesión al navegador. Sin persistent ("remember
+// me" sin marcar) es una cookie de navegador: se pierde al cerrarlo, aunque
+// la sesión siga viva en la BD hasta expires.
+func (s *Server) setSessionCookie(w http.ResponseWriter, sid string, persistent bool, expires time.Time) {
+	c := &http.Cookie{
 		Name:     CookieName,
 		Value:    sid,
 		Path:     "/",
 		HttpOnly: true,
 		SameSite: http.SameSiteLaxMode,
-		Expires:  time.Now().Add(s.Cfg.SessionLifetime),
-	})
-	http.Redirect(w, r, "/", http.StatusSeeOther)
+	}
+	if persistent {
+		c.Expires = expires
+	}
+	http.SetCookie(w, c)
 }
 
 // ---------------------------------------------------------------------------------
 // ------------HandleLogout Function-----------------------------------------------
 func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
 	if c, err := r.Cookie(CookieName); err == nil {
-		_ = auth.Logout(s.DB, c.Value)
+		_ = auth.Logout(r.Context(), s.Store.Sessions, c.Value)
 		c.MaxAge = -1
 		http.SetCookie(w, c)
 	}
@@ -422,24 +674,9 @@ func (s *Server) handlePostNew(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
 	// 1) Cargar categorías (con manejo de errores)
-	rows, err := s.DB.QueryContext(ctx, `SELECT id, name FROM categories ORDER BY name`)
+	cats, err := s.loadCategories(ctx)
 	if err != nil {
-		http.Error(w, "categories query: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-	defer rows.Close()
-
-	var cats []catVM
-	for rows.Next() {
-		var c catVM
-		if err := rows.Scan(&c.ID, &c.Name); err != nil {
-			http.Error(w, "categories scan: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-		cats = append(cats, c)
-	}
-	if err := rows.Err(); err != nil {
-		http.Error(w, "categories err: "+err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -491,71 +728,14 @@ func (s *Server) handlePostCreate(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-	tx, err := s.DB.BeginTx(ctx, nil)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	// Normalizamos el conjunto de categorías (existentes + la nueva si aplica)
+	if newCat != "" {
+		cats = append(cats, newCat)
 	}
-	defer tx.Rollback()
-
-	// 1) Crear post y obtener id (PG: RETURNING)
-	var pid int64
-	if err := tx.QueryRowContext(ctx,
-		`INSERT INTO posts (user_id, title, content)
-         VALUES ($1,$2,$3)
-         RETURNING id`,
-		uid, title, content,
-	).Scan(&pid); err != nil {
-		http.Error(w, "db error: "+err.Error(), http.StatusInternalServerError)
+	if _, err := s.createPost(ctx, uid, title, content, cats); err != nil {
+		http.Redirect(w, r, "/post/new?err="+url.QueryEscape(err.Error()), http.StatusSeeOther)
 		return
 	}
-   // 2) Normalizamos el conjunto de categorías (existentes + la nueva si aplica)
-   if newCat != "" {
-	cats = append(cats, newCat)
-}
-	// 3) Asegurar categorías y vincular (PG: ON CONFLICT DO NOTHING)
-	for _, name := range cats {
-		name = strings.TrimSpace(name)
-		if name == "" {
-			continue
-		}
-
-		var cid int64
-		// Intentamos insertar; si ya existe, no devuelve fila (ErrNoRows).
-		err := tx.QueryRowContext(ctx, `
-			INSERT INTO categories (name)
-			VALUES ($1)
-			ON CONFLICT (name) DO NOTHING
-			RETURNING id
-		`, name).Scan(&cid)
-
-		if err == sql.ErrNoRows {
-			// Ya existía: recupera su id
-			if e2 := tx.QueryRowContext(ctx, `SELECT id FROM categories WHERE name=$1`, name).Scan(&cid); e2 != nil {
-				// si no podemos obtenerla, saltamos esta categoría
-				log.Printf("skip category %q: %v", name, e2)
-				continue
-			}
-		} else if err != nil {
-			// error real al intentar crear categoría
-			log.Printf("insert category %q err: %v", name, err)
-			continue
-		}
-
-		// Vincular post-categoría; PK (post_id,category_id) evita duplicados
-		if _, e3 := tx.ExecContext(ctx, `
-            INSERT INTO post_categories (post_id, category_id)
-            VALUES ($1,$2)
-            ON CONFLICT DO NOTHING
-        `, pid, cid); e3 != nil {
-            log.Printf("link post-category err: %v", e3)
-        }
-	}
-
-	if err := tx.Commit(); err != nil {
-        http.Redirect(w, r, "/post/new?err="+url.QueryEscape(err.Error()), http.StatusSeeOther)
-        return
-    }
 
 	log.Printf("create post uid=%d title=%q cats=%v", uid, title, cats)
 	http.Redirect(w, r, "/?ok=1", http.StatusSeeOther)
@@ -566,17 +746,26 @@ func (s *Server) handlePostCreate(w http.ResponseWriter, r *http.Request) {
 func (s *Server) handleCommentCreate(w http.ResponseWriter, r *http.Request) {
 	uid, _ := auth.UserIDFrom(r.Context())
 	pid, _ := strconv.ParseInt(r.FormValue("post_id"), 10, 64)
+	parent, _ := strconv.ParseInt(r.FormValue("parent_id"), 10, 64) // vacío = primer nivel
 	content := strings.TrimSpace(r.FormValue("content"))
-	if pid == 0 || content == "" {
+	if pid == 0 || content == "" || parent < 0 {
 		http.Error(w, "Bad request", http.StatusBadRequest)
 		return
 	}
-	_, err := s.DB.Exec(`INSERT INTO comments (post_id,user_id,content) VALUES ($1,$2,$3)`, pid, uid, content)
+	cid, err := s.createComment(r.Context(), uid, pid, parent, content)
 	if err != nil {
+		if errors.Is(err, errNotFound) {
+			s.notFound(w, r)
+			return
+		}
+		if errors.Is(err, errLocked) {
+			http.Redirect(w, r, fmt.Sprintf("/post/%d?err=%s", pid, url.QueryEscape("This thread is locked")), http.StatusSeeOther)
+			return
+		}
 		http.Error(w, err.Error(), 500)
 		return
 	}
-	http.Redirect(w, r, "/", http.StatusSeeOther)
+	http.Redirect(w, r, fmt.Sprintf("/post/%d#comment-%d", pid, cid), http.StatusSeeOther)
 }
 
 // ---------------------------------------------------------------------------------
@@ -585,31 +774,41 @@ func (s *Server) handleReact(w http.ResponseWriter, r *http.Request) {
 	uid, _ := auth.UserIDFrom(r.Context())
 	target := r.FormValue("target") // "post" or "comment"
 	id, _ := strconv.ParseInt(r.FormValue("id"), 10, 64)
-	val, _ := strconv.Atoi(r.FormValue("value")) // 1 or -1
-	if (target != "post" && target != "comment") || (val != 1 && val != -1) || id == 0 {
+	val, _ := strconv.Atoi(r.FormValue("value")) // 1 or -1; repetir la misma la quita
+	_, err := s.toggleReact(r.Context(), uid, target, id, val)
+	switch {
+	case errors.Is(err, errBadRequest):
 		http.Error(w, "Bad request", http.StatusBadRequest)
 		return
-	}
-	_, err := s.DB.Exec(`
-INSERT INTO reactions (user_id,target_type,target_id,value) VALUES ($1,$2,$3,$4)
-ON CONFLICT(user_id,target_type,target_id) DO UPDATE SET value=excluded.value
-`, uid, target, id, val)
-	if err != nil {
+	case errors.Is(err, errNotFound):
+		s.notFound(w, r)
+		return
+	case err != nil:
 		http.Error(w, err.Error(), 500)
 		return
 	}
-	http.Redirect(w, r, "/", http.StatusSeeOther)
+	fallback := "/"
+	if target == "post" {
+		fallback = fmt.Sprintf("/post/%d", id)
+	}
+	http.Redirect(w, r, safeNext(r.FormValue("next"), fallback), http.StatusSeeOther)
 }
 //--------------------------------------------------------------------------------------
 //--------------fillUserMeta Function helper-------------------------------------------
 
 func (s *Server) fillUserMeta(ctx context.Context, data *pageData) {
+    data.CSRFToken = csrfToken(ctx)
     if uid, ok := auth.UserIDFrom(ctx); ok && uid != 0 {
         data.UserID = uid
 
-        var name string
-        // Postgres
-        _ = s.DB.QueryRowContext(ctx, `SELECT username FROM users WHERE id = $1`, uid).Scan(&name)
+        u, _ := s.Store.Users.ByID(ctx, uid)
+        name := u.Username
+        data.NeedsVerify = s.Cfg.EmailPolicy != app.EmailPolicyOff && u.EmailVerifiedAt == nil
+        data.IsMod = auth.CanModerate(u.Role)
+        data.IsAdmin = auth.HasRole(u.Role, auth.RoleAdmin)
+        if sn := auth.Silenced(u, time.Now()); sn != nil && sn.Kind == auth.SanctionMute {
+            data.Muted = toSanctionVM(sn)
+        }
 
         if name != "" {
             data.Username = name
@@ -0,0 +1,1586 @@
+package httpx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"forum/internal/app"
+	"forum/internal/auth"
+	"forum/internal/mail"
+	"forum/internal/models"
+	"forum/internal/store"
+	"forum/internal/store/storetest"
+)
+
+// Los templates y estáticos se cargan con rutas relativas a la raíz del repo.
+func TestMain(m *testing.M) {
+	if err := os.Chdir("../.."); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+/* =========================
+   Helpers
+   ========================= */
+
+type testEnv struct {
+	t      *testing.T
+	srv    *httptest.Server
+	mail   *mail.Capture
+	server *Server // para ajustar Cfg antes de las requests
+}
+
+// forEachBackend ejecuta el test sobre cada implementación de store
+// (memstore, SQLite y, si está configurado, Postgres).
+func forEachBackend(t *testing.T, fn func(t *testing.T, st *store.Store)) {
+	for _, b := range storetest.Backends() {
+		t.Run(b.Name, func(t *testing.T) { fn(t, b.New(t)) })
+	}
+}
+
+// newTestEnv levanta el servidor completo sobre el store dado.
+func newTestEnv(t *testing.T, st *store.Store, policy app.EmailPolicy) *testEnv {
+	t.Helper()
+	capture := &mail.Capture{}
+	s := NewServer(st, app.Config{
+		SessionLifetime: time.Hour,
+		SessionRenew:    0.5,
+		SessionMaxAge:   24 * time.Hour,
+		BaseURL:         "http://forum.test",
+		ResetTokenTTL:   time.Hour,
+		VerifyTokenTTL:  time.Hour,
+		EmailPolicy:     policy,
+	})
+	s.Mailer = capture
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+	return &testEnv{t: t, srv: srv, mail: capture, server: s}
+}
+
+// client es un navegador mínimo: guarda cookies y no sigue redirecciones.
+type client struct {
+	env *testEnv
+	hc  *http.Client
+}
+
+func (e *testEnv) client() *client {
+	jar, _ := cookiejar.New(nil)
+	return &client{env: e, hc: &http.Client{
+		Jar:           jar,
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}}
+}
+
+func (c *client) do(req *http.Request) (*http.Response, string) {
+	c.env.t.Helper()
+	res, err := c.hc.Do(req)
+	if err != nil {
+		c.env.t.Fatal(err)
+	}
+	defer res.Body.Close()
+	b, _ := io.ReadAll(res.Body)
+	return res, string(b)
+}
+
+func (c *client) get(path string) (*http.Response, string) {
+	req, _ := http.NewRequest(http.MethodGet, c.env.srv.URL+path, nil)
+	return c.do(req)
+}
+
+var csrfField = regexp.MustCompile(`name="csrf_token" value="([^"]+)"`)
+
+// post envía un formulario con el token CSRF que el servidor puso en la portada.
+func (c *client) post(path string, form url.Values) (*http.Response, string) {
+	c.env.t.Helper()
+	_, page := c.get("/login")
+	m := csrfField.FindStringSubmatch(page)
+	if m == nil {
+		c.env.t.Fatal("no csrf token in page")
+	}
+	if form == nil {
+		form = url.Values{}
+	}
+	form.Set(CSRFFieldName, m[1])
+	req, _ := http.NewRequest(http.MethodPost, c.env.srv.URL+path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return c.do(req)
+}
+
+// api llama a /api/v1 con un cuerpo JSON (o ninguno) y, si token no es "",
+// con Authorization: Bearer.
+func (e *testEnv) api(method, path, token, body string) (*http.Response, string) {
+	e.t.Helper()
+	var rd io.Reader
+	if body != "" {
+		rd = strings.NewReader(body)
+	}
+	req, _ := http.NewRequest(method, e.srv.URL+apiPrefix+path, rd)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	defer res.Body.Close()
+	b, _ := io.ReadAll(res.Body)
+	return res, string(b)
+}
+
+// apiLogin devuelve un token de sesión de POST /auth/token.
+func (e *testEnv) apiLogin(email string) string {
+	e.t.Helper()
+	res, body := e.api(http.MethodPost, "/auth/token", "", `{"email": "`+email+`", "password": "secret123"}`)
+	var out struct {
+		Token string `json:"token"`
+	}
+	if err := json.Unmarshal([]byte(body), &out); err != nil || res.StatusCode != http.StatusOK || out.Token == "" {
+		e.t.Fatalf("api login status = %d: %s", res.StatusCode, body)
+	}
+	return out.Token
+}
+
+// apiErrorCode comprueba que body es exactamente el sobre
+// {"error": {"code", "message"}} y devuelve el code.
+func apiErrorCode(t *testing.T, body string) string {
+	t.Helper()
+	var env map[string]map[string]string
+	if err := json.Unmarshal([]byte(body), &env); err != nil || len(env) != 1 {
+		t.Fatalf("not an error envelope: %s", body)
+	}
+	e, ok := env["error"]
+	if !ok || len(e) != 2 || e["code"] == "" || e["message"] == "" {
+		t.Fatalf("not an error envelope: %s", body)
+	}
+	return e["code"]
+}
+
+var linkRe = regexp.MustCompile(`http://forum\.test(/\S+)`)
+
+// register crea la cuenta, sigue el enlace de verificación y entra.
+func (c *client) register(name string) {
+	c.env.t.Helper()
+	email := name + "@example.test"
+	c.post("/register", url.Values{"email": {email}, "username": {name}, "password": {"secret123"}})
+	if msg, ok := c.env.mail.Last(email); ok {
+		m := linkRe.FindStringSubmatch(msg.Body)
+		if m == nil {
+			c.env.t.Fatalf("no link in mail: %q", msg.Body)
+		}
+		c.get(m[1])
+	}
+	res, _ := c.post("/login", url.Values{"email": {email}, "password": {"secret123"}})
+	if loc := res.Header.Get("Location"); loc != "/" {
+		c.env.t.Fatalf("login redirect = %q", loc)
+	}
+}
+
+/* =========================
+   Tests
+   ========================= */
+
+func TestPostAndCommentFlow(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st *store.Store) {
+		env := newTestEnv(t, st, app.EmailPolicyPost)
+		alice := env.client()
+		alice.register("alice")
+
+		res, _ := alice.post("/post/create", url.Values{"title": {"Hello"}, "content": {"First post"}, "cats": {"Go"}})
+		if res.StatusCode != http.StatusSeeOther {
+			t.Fatalf("create post status = %d", res.StatusCode)
+		}
+		_, body := alice.get("/")
+		if !strings.Contains(body, "Hello") || !strings.Contains(body, "First post") {
+			t.Fatal("post missing from index")
+		}
+		m := regexp.MustCompile(`href="/post/(\d+)"`).FindStringSubmatch(body)
+		if m == nil {
+			t.Fatal("no permalink in index")
+		}
+		pid := m[1]
+
+		res, _ = alice.post("/comment/create", url.Values{"post_id": {pid}, "content": {"Nice one"}})
+		if loc := res.Header.Get("Location"); !strings.HasPrefix(loc, "/post/"+pid+"#comment-") {
+			t.Fatalf("comment redirect = %q", loc)
+		}
+		alice.post("/react", url.Values{"target": {"post"}, "id": {pid}, "value": {"1"}})
+
+		_, body = alice.get("/post/" + pid)
+		if !strings.Contains(body, "Nice one") {
+			t.Fatal("comment missing from post page")
+		}
+
+		// Respuesta anidada bajo el comentario
+		cid := strings.TrimPrefix(res.Header.Get("Location"), "/post/"+pid+"#comment-")
+		res, _ = alice.post("/comment/create", url.Values{"post_id": {pid}, "parent_id": {cid}, "content": {"Thanks!"}})
+		if res.StatusCode != http.StatusSeeOther {
+			t.Fatalf("reply status = %d", res.StatusCode)
+		}
+		_, body = alice.get("/post/" + pid)
+		if !regexp.MustCompile(`(?s)id="comment-` + cid + `".*class="replies" open>\s*<summary>1 reply</summary>.*Thanks!`).MatchString(body) {
+			t.Fatal("reply not nested under its comment")
+		}
+		if res, _ := alice.post("/comment/create", url.Values{"post_id": {pid}, "parent_id": {"999999"}, "content": {"x"}}); res.StatusCode != http.StatusNotFound {
+			t.Fatalf("reply to missing comment status = %d", res.StatusCode)
+		}
+
+		// Reacción a un comentario: se marca como propia y repetirla la quita
+		liked := regexp.MustCompile(`name="target" value="comment" />\s*<input type="hidden" name="id" value="` + cid + `" />\s*<input type="hidden" name="value" value="1" />\s*<button type="submit" class="active"[^>]*>👍 1<`)
+		alice.post("/react", url.Values{"target": {"comment"}, "id": {cid}, "value": {"1"}})
+		if _, body = alice.get("/post/" + pid); !liked.MatchString(body) {
+			t.Fatal("own comment like not shown")
+		}
+		alice.post("/react", url.Values{"target": {"comment"}, "id": {cid}, "value": {"1"}})
+		if _, body = alice.get("/post/" + pid); liked.MatchString(body) || !strings.Contains(body, "👍 0<") {
+			t.Fatal("re-clicking like did not remove it")
+		}
+
+		// Otro usuario no puede editar el post de alice
+		bob := env.client()
+		bob.register("bob")
+		res, _ = bob.post("/post/"+pid+"/edit", url.Values{"title": {"pwned"}, "content": {"x"}})
+		if res.StatusCode != http.StatusForbidden {
+			t.Fatalf("edit by other user status = %d", res.StatusCode)
+		}
+
+		// Anónimo: lectura sí, escritura no
+		anon := env.client()
+		if res, _ := anon.get("/post/" + pid); res.StatusCode != http.StatusOK {
+			t.Fatalf("anon view status = %d", res.StatusCode)
+		}
+		if res, _ := anon.post("/post/create", url.Values{"title": {"x"}, "content": {"y"}}); res.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("anon create status = %d", res.StatusCode)
+		}
+		if res, _ := anon.get("/post/999999"); res.StatusCode != http.StatusNotFound {
+			t.Fatalf("missing post status = %d", res.StatusCode)
+		}
+	})
+}
+
+// Editar y borrar: el autor o un moderador; los demás reciben 403. Borrar no
+// deja reacciones colgando (reactions.target_id no tiene clave foránea).
+func TestEditAndDelete(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st *store.Store) {
+		env := newTestEnv(t, st, app.EmailPolicyOff)
+		ctx := context.Background()
+		alice, bob, mod := env.client(), env.client(), env.client()
+		alice.register("alice")
+		bob.register("bob")
+		mod.register("mod")
+		if err := auth.SetUserRole(ctx, st.Users, "mod", auth.RoleModerator); err != nil {
+			t.Fatal(err)
+		}
+		bobU, err := st.Users.ByEmail(ctx, "bob@example.test")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		alice.post("/post/create", url.Values{"title": {"Draft"}, "content": {"Typo"}, "cats": {"Go"}})
+		_, body := alice.get("/")
+		pid := regexp.MustCompile(`href="/post/(\d+)"`).FindStringSubmatch(body)[1]
+		comment := func(c *client, content string) string {
+			res, _ := c.post("/comment/create", url.Values{"post_id": {pid}, "content": {content}})
+			return strings.TrimPrefix(res.Header.Get("Location"), "/post/"+pid+"#comment-")
+		}
+		aliceC := comment(alice, "Alice says")
+		bobC := comment(bob, "Bob says")
+		bob.post("/react", url.Values{"target": {"post"}, "id": {pid}, "value": {"1"}})
+		bob.post("/react", url.Values{"target": {"comment"}, "id": {aliceC}, "value": {"1"}})
+		bob.post("/react", url.Values{"target": {"comment"}, "id": {bobC}, "value": {"-1"}})
+		reactions := func(target, id string) map[int64]int {
+			n, _ := strconv.ParseInt(id, 10, 64)
+			mine, err := st.Reactions.Mine(ctx, bobU.ID, target, []int64{n})
+			if err != nil {
+				t.Fatal(err)
+			}
+			return mine
+		}
+		if len(reactions("post", pid)) != 1 || len(reactions("comment", aliceC)) != 1 || len(reactions("comment", bobC)) != 1 {
+			t.Fatal("reactions not stored")
+		}
+
+		// El autor edita: queda la marca "edited"
+		if res, _ := alice.get("/post/" + pid + "/edit"); res.StatusCode != http.StatusOK {
+			t.Fatalf("owner edit page status = %d", res.StatusCode)
+		}
+		if res, _ := alice.post("/post/"+pid+"/edit", url.Values{"title": {"Draft"}, "content": {" "}}); !strings.Contains(res.Header.Get("Location"), "err=") {
+			t.Fatal("empty edit accepted")
+		}
+		res, _ := alice.post("/post/"+pid+"/edit", url.Values{"title": {"Final"}, "content": {"Fixed"}})
+		if loc := res.Header.Get("Location"); loc != "/post/"+pid {
+			t.Fatalf("edit redirect = %q", loc)
+		}
+		if _, body = alice.get("/post/" + pid); !strings.Contains(body, "Final") || !strings.Contains(body, "<em>(edited)</em>") {
+			t.Fatal("edited post not shown as edited")
+		}
+
+		// Otro usuario no puede tocar lo ajeno
+		for _, path := range []string{"/post/" + pid + "/edit", "/comment/" + aliceC + "/edit"} {
+			if res, _ := bob.get(path); res.StatusCode != http.StatusForbidden {
+				t.Fatalf("GET %s by other user status = %d", path, res.StatusCode)
+			}
+		}
+		for path, form := range map[string]url.Values{
+			"/post/" + pid + "/edit":         {"title": {"pwned"}, "content": {"x"}},
+			"/post/" + pid + "/delete":       nil,
+			"/comment/" + aliceC + "/edit":   {"content": {"pwned"}},
+			"/comment/" + aliceC + "/delete": nil,
+		} {
+			if res, _ := bob.post(path, form); res.StatusCode != http.StatusForbidden {
+				t.Fatalf("POST %s by other user status = %d", path, res.StatusCode)
+			}
+		}
+		if res, _ := bob.post("/comment/999999/delete", nil); res.StatusCode != http.StatusNotFound {
+			t.Fatalf("delete missing comment status = %d", res.StatusCode)
+		}
+
+		// Un moderador edita y borra el comentario de bob
+		res, _ = mod.post("/comment/"+bobC+"/edit", url.Values{"content": {"Moderated"}})
+		if loc := res.Header.Get("Location"); loc != "/post/"+pid+"#comment-"+bobC {
+			t.Fatalf("mod edit comment redirect = %q", loc)
+		}
+		if _, body = alice.get("/post/" + pid); strings.Contains(body, "Bob says") || !strings.Contains(body, "Moderated") {
+			t.Fatal("moderator edit not applied")
+		}
+		if res, _ := mod.post("/comment/"+bobC+"/delete", nil); res.StatusCode != http.StatusSeeOther {
+			t.Fatalf("mod delete comment status = %d", res.StatusCode)
+		}
+		if _, body = alice.get("/post/" + pid); strings.Contains(body, "Moderated") {
+			t.Fatal("deleted comment still shown")
+		}
+		if m := reactions("comment", bobC); len(m) != 0 {
+			t.Fatalf("reactions left on deleted comment: %v", m)
+		}
+
+		// El autor borra el post: se van sus comentarios y todas las reacciones
+		if res, _ := alice.post("/post/"+pid+"/delete", nil); res.Header.Get("Location") != "/?deleted=1" {
+			t.Fatalf("delete post redirect = %q", res.Header.Get("Location"))
+		}
+		if res, _ := alice.get("/post/" + pid); res.StatusCode != http.StatusNotFound {
+			t.Fatalf("deleted post status = %d", res.StatusCode)
+		}
+		if m := reactions("post", pid); len(m) != 0 {
+			t.Fatalf("reactions left on deleted post: %v", m)
+		}
+		if m := reactions("comment", aliceC); len(m) != 0 {
+			t.Fatalf("reactions left on comment of deleted post: %v", m)
+		}
+	})
+}
+
+// /post/{id}: un solo post con sus categorías, comentarios y reacciones; 404
+// si no existe; reacciones y comentarios vuelven a donde estaba el usuario.
+func TestPostPermalink(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st *store.Store) {
+		env := newTestEnv(t, st, app.EmailPolicyOff)
+		alice, bob := env.client(), env.client()
+		alice.register("alice")
+		bob.register("bob")
+		alice.post("/post/create", url.Values{"title": {"Solo"}, "content": {"Only this one"}, "cats": {"Go"}})
+		alice.post("/post/create", url.Values{"title": {"Other"}, "content": {"Not here"}, "cats": {"General"}})
+
+		_, body := alice.get("/")
+		m := regexp.MustCompile(`<a href="/post/(\d+)">Solo</a>`).FindStringSubmatch(body)
+		if m == nil {
+			t.Fatal("no permalink for the post in index")
+		}
+		pid := m[1]
+
+		res, _ := bob.post("/comment/create", url.Values{"post_id": {pid}, "content": {"Great"}})
+		if loc := res.Header.Get("Location"); !strings.HasPrefix(loc, "/post/"+pid+"#comment-") {
+			t.Fatalf("comment redirect = %q", loc)
+		}
+		// La reacción vuelve a next (local) o, si no vale, al post
+		next := "/post/" + pid + "#post-" + pid
+		res, _ = bob.post("/react", url.Values{"target": {"post"}, "id": {pid}, "value": {"1"}, "next": {next}})
+		if loc := res.Header.Get("Location"); loc != next {
+			t.Fatalf("react redirect = %q", loc)
+		}
+		res, _ = alice.post("/react", url.Values{"target": {"post"}, "id": {pid}, "value": {"-1"}, "next": {"//evil.example"}})
+		if loc := res.Header.Get("Location"); loc != "/post/"+pid {
+			t.Fatalf("react with foreign next redirect = %q", loc)
+		}
+
+		res, body = env.client().get("/post/" + pid)
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("permalink status = %d", res.StatusCode)
+		}
+		for _, want := range []string{"Solo", "Only this one", `<span class="chip">Go</span>`, "Great", "👍 1<", "👎 1<"} {
+			if !strings.Contains(body, want) {
+				t.Fatalf("permalink page missing %q", want)
+			}
+		}
+		if strings.Contains(body, "Other") {
+			t.Fatal("permalink page shows another post")
+		}
+
+		for _, path := range []string{"/post/999999", "/post/0", "/post/abc"} {
+			if res, body := alice.get(path); res.StatusCode != http.StatusNotFound || !strings.Contains(body, "<html") {
+				t.Fatalf("GET %s status = %d", path, res.StatusCode)
+			}
+		}
+	})
+}
+
+// Las acciones solo existen por POST: un GET (un <img>, un enlace) recibe 405,
+// un HEAD se sirve como GET y cualquier otro método necesita el token CSRF.
+func TestUnsafeMethodsRejected(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st *store.Store) {
+		env := newTestEnv(t, st, app.EmailPolicyOff)
+		alice := env.client()
+		alice.register("alice")
+		alice.post("/post/create", url.Values{"title": {"Hello"}, "content": {"First post"}, "cats": {"Go"}})
+		_, body := alice.get("/")
+		pid := regexp.MustCompile(`href="/post/(\d+)"`).FindStringSubmatch(body)[1]
+
+		// GET /post/create cae en /post/{id} (id no numérico): 404
+		if res, _ := alice.get("/post/create?title=Forged&content=x&cats=Go"); res.StatusCode != http.StatusNotFound {
+			t.Fatalf("GET /post/create status = %d", res.StatusCode)
+		}
+		for _, path := range []string{
+			"/comment/create?post_id=" + pid + "&content=Forged",
+			"/react?target=post&id=" + pid + "&value=1",
+			"/logout",
+			"/post/" + pid + "/delete",
+		} {
+			if res, _ := alice.get(path); res.StatusCode != http.StatusMethodNotAllowed || res.Header.Get("Allow") != "POST" {
+				t.Fatalf("GET %s status = %d, Allow %q", path, res.StatusCode, res.Header.Get("Allow"))
+			}
+		}
+		req, _ := http.NewRequest(http.MethodOptions, env.srv.URL+"/post/create?title=Forged&content=x&cats=Go", nil)
+		if res, _ := alice.do(req); res.StatusCode != http.StatusForbidden {
+			t.Fatalf("OPTIONS /post/create status = %d", res.StatusCode)
+		}
+		if res, _ := alice.get("/no/such/page"); res.StatusCode != http.StatusNotFound {
+			t.Fatalf("unknown path status = %d", res.StatusCode)
+		}
+		if _, body := alice.get("/debug/me"); !strings.HasPrefix(body, "logged") {
+			t.Fatalf("GET /logout signed out: %q", body)
+		}
+		_, body = alice.get("/post/" + pid)
+		if strings.Contains(body, "Forged") || !strings.Contains(body, "👍 0<") {
+			t.Fatal("GET changed the post page")
+		}
+		if _, body := alice.get("/"); strings.Contains(body, "Forged") {
+			t.Fatal("GET created a post")
+		}
+
+		// HEAD en los formularios de cuenta
+		anon := env.client()
+		head := func(path string, form url.Values) {
+			req, _ := http.NewRequest(http.MethodHead, env.srv.URL+path+"?"+form.Encode(), nil)
+			if res, _ := anon.do(req); res.StatusCode != http.StatusOK {
+				t.Fatalf("HEAD %s status = %d", path, res.StatusCode)
+			}
+		}
+		head("/login", url.Values{"email": {"alice@example.test"}, "password": {"secret123"}})
+		if _, body := anon.get("/debug/me"); body != "anon" {
+			t.Fatalf("HEAD /login signed in: %q", body)
+		}
+		head("/register", url.Values{"email": {"mallory@example.test"}, "username": {"mallory"}, "password": {"secret123"}})
+		if _, err := st.Users.ByEmail(context.Background(), "mallory@example.test"); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("HEAD /register created an account: %v", err)
+		}
+		head("/forgot", url.Values{"email": {"alice@example.test"}})
+		if msg, ok := env.mail.Last("alice@example.test"); ok && strings.Contains(msg.Subject, "Reset") {
+			t.Fatal("HEAD /forgot sent a reset mail")
+		}
+	})
+}
+
+// Un POST sin token CSRF, o con uno que no es el suyo, recibe 403 tanto con
+// sesión (token de la fila de sessions) como sin ella (cookie csrf_token).
+func TestCSRFRequired(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st *store.Store) {
+		env := newTestEnv(t, st, app.EmailPolicyOff)
+		alice, bob, anon := env.client(), env.client(), env.client()
+		alice.register("alice")
+		bob.register("bob")
+
+		token := func(c *client) string {
+			_, page := c.get("/login")
+			m := csrfField.FindStringSubmatch(page)
+			if m == nil {
+				t.Fatal("no csrf token in page")
+			}
+			return m[1]
+		}
+		// send manda el formulario tal cual, con field y header como token
+		send := func(c *client, path string, form url.Values, field, header string) int {
+			if field != "" {
+				form.Set(CSRFFieldName, field)
+			}
+			req, _ := http.NewRequest(http.MethodPost, env.srv.URL+path, strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if header != "" {
+				req.Header.Set(CSRFHeaderName, header)
+			}
+			res, _ := c.do(req)
+			return res.StatusCode
+		}
+		forged := func() url.Values {
+			return url.Values{"title": {"Forged"}, "content": {"x"}, "cats": {"Go"}}
+		}
+
+		// Con sesión: falta, es inventado o es el de otra sesión
+		bobs := token(bob)
+		for name, tok := range map[string][2]string{
+			"missing":       {"", ""},
+			"wrong field":   {"deadbeef", ""},
+			"wrong header":  {"", "deadbeef"},
+			"other session": {bobs, ""},
+		} {
+			if code := send(alice, "/post/create", forged(), tok[0], tok[1]); code != http.StatusForbidden {
+				t.Fatalf("session, %s token: status = %d", name, code)
+			}
+		}
+		if _, body := alice.get("/"); strings.Contains(body, "Forged") {
+			t.Fatal("forged POST created a post")
+		}
+		if code := send(alice, "/post/create", forged(), "", token(alice)); code != http.StatusSeeOther {
+			t.Fatalf("session, header token: status = %d", code)
+		}
+
+		// Sin sesión: el token va en la cookie csrf_token
+		anons := token(anon)
+		login := func() url.Values { return url.Values{"email": {"alice@example.test"}, "password": {"secret123"}} }
+		for name, tok := range map[string][2]string{
+			"missing":      {"", ""},
+			"wrong field":  {"deadbeef", ""},
+			"wrong header": {"", "deadbeef"},
+		} {
+			if code := send(anon, "/login", login(), tok[0], tok[1]); code != http.StatusForbidden {
+				t.Fatalf("anon login, %s token: status = %d", name, code)
+			}
+			reg := url.Values{"email": {"mallory@example.test"}, "username": {"mallory"}, "password": {"secret123"}}
+			if code := send(anon, "/register", reg, tok[0], tok[1]); code != http.StatusForbidden {
+				t.Fatalf("anon register, %s token: status = %d", name, code)
+			}
+		}
+		if _, body := anon.get("/debug/me"); body != "anon" {
+			t.Fatalf("forged login signed in: %q", body)
+		}
+		if _, err := st.Users.ByEmail(context.Background(), "mallory@example.test"); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("forged register created an account: %v", err)
+		}
+		if code := send(anon, "/login", login(), anons, ""); code != http.StatusSeeOther {
+			t.Fatalf("anon login, good token: status = %d", code)
+		}
+	})
+}
+
+func TestUnverifiedUserCannotPost(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st *store.Store) {
+		env := newTestEnv(t, st, app.EmailPolicyPost)
+		c := env.client()
+		c.post("/register", url.Values{"email": {"carol@example.test"}, "username": {"carol"}, "password": {"secret123"}})
+		c.post("/login", url.Values{"email": {"carol@example.test"}, "password": {"secret123"}})
+
+		res, _ := c.post("/post/create", url.Values{"title": {"x"}, "content": {"y"}})
+		if loc := res.Header.Get("Location"); !strings.Contains(loc, "err=") {
+			t.Fatalf("unverified create redirect = %q", loc)
+		}
+	})
+}
+
+func TestPasswordReset(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st *store.Store) {
+		env := newTestEnv(t, st, app.EmailPolicyOff)
+		c := env.client()
+		c.register("dave")
+
+		// Un email desconocido recibe la misma respuesta y no manda nada
+		sent := len(env.mail.Messages())
+		res, _ := c.post("/forgot", url.Values{"email": {"nobody@example.test"}})
+		if loc := res.Header.Get("Location"); loc != "/login?reset=1" || len(env.mail.Messages()) != sent {
+			t.Fatalf("unknown email: redirect %q, %d mails", loc, len(env.mail.Messages())-sent)
+		}
+
+		resetToken := func() string {
+			c.post("/forgot", url.Values{"email": {"DAVE@example.test "}})
+			msg, ok := env.mail.Last("dave@example.test")
+			if !ok {
+				t.Fatal("no reset mail")
+			}
+			link := linkRe.FindStringSubmatch(msg.Body)[1]
+			token, _ := url.QueryUnescape(strings.TrimPrefix(link, "/reset?token="))
+			return token
+		}
+		reset := func(token, password, confirm string) string {
+			res, _ := c.post("/reset", url.Values{"token": {token}, "password": {password}, "password2": {confirm}})
+			return res.Header.Get("Location")
+		}
+		token := resetToken()
+
+		// Los errores vuelven al formulario y no gastan el token
+		if loc := reset(token, "newpass1", "newpass2"); !strings.Contains(loc, "err=") {
+			t.Fatalf("mismatched passwords redirect = %q", loc)
+		}
+		if loc := reset("bogus", "newpass1", "newpass1"); !strings.Contains(loc, "err=") {
+			t.Fatalf("bad token redirect = %q", loc)
+		}
+		if loc := reset(token, "newpass1", "newpass1"); loc != "/login?pwreset=1" {
+			t.Fatalf("reset redirect = %q", loc)
+		}
+		// La sesión anterior ya no sirve y la contraseña nueva sí
+		if _, body := c.get("/debug/me"); body != "anon" {
+			t.Fatalf("session survived reset: %q", body)
+		}
+		// Un solo uso
+		if loc := reset(token, "newpass2", "newpass2"); !strings.Contains(loc, "err=") {
+			t.Fatalf("reused token redirect = %q", loc)
+		}
+		res, _ = c.post("/login", url.Values{"email": {"dave@example.test"}, "password": {"secret123"}})
+		if loc := res.Header.Get("Location"); loc == "/" {
+			t.Fatal("old password still works")
+		}
+		res, _ = c.post("/login", url.Values{"email": {"dave@example.test"}, "password": {"newpass1"}})
+		if loc := res.Header.Get("Location"); loc != "/" {
+			t.Fatalf("login with new password redirect = %q", loc)
+		}
+
+		// Un token caducado no vale
+		env.server.Cfg.ResetTokenTTL = -time.Minute
+		if loc := reset(resetToken(), "newpass3", "newpass3"); !strings.Contains(loc, "err=") {
+			t.Fatalf("expired token redirect = %q", loc)
+		}
+	})
+}
+
+func TestAPIListPosts(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st *store.Store) {
+		env := newTestEnv(t, st, app.EmailPolicyOff)
+		c := env.client()
+		c.register("erin")
+		for _, title := range []string{"one", "two", "three"} {
+			c.post("/post/create", url.Values{"title": {title}, "content": {"body"}, "cats": {"General"}})
+		}
+
+		res, body := c.get(apiPrefix + "/posts?limit=2")
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("status = %d: %s", res.StatusCode, body)
+		}
+		var out struct {
+			Posts []struct {
+				Title string `json:"title"`
+			} `json:"posts"`
+			NextCursor string `json:"next_cursor"`
+		}
+		if err := json.Unmarshal([]byte(body), &out); err != nil {
+			t.Fatal(err)
+		}
+		if len(out.Posts) != 2 || out.Posts[0].Title != "three" || out.NextCursor == "" {
+			t.Fatalf("unexpected page: %s", body)
+		}
+	})
+}
+
+// Códigos de estado y sobre de error de la API versionada.
+func TestAPIWrites(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st *store.Store) {
+		env := newTestEnv(t, st, app.EmailPolicyOff)
+		env.client().register("erin")
+		tok := env.apiLogin("erin@example.test")
+
+		expectErr := func(res *http.Response, body string, status int, code string) {
+			t.Helper()
+			if res.StatusCode != status {
+				t.Fatalf("status = %d, want %d: %s", res.StatusCode, status, body)
+			}
+			if got := apiErrorCode(t, body); got != code {
+				t.Fatalf("error code = %q, want %q", got, code)
+			}
+		}
+		newPost := `{"title": "Hi", "content": "From the API", "categories": ["Go"]}`
+
+		// Sin token: 401
+		res, body := env.api(http.MethodPost, "/posts", "", newPost)
+		expectErr(res, body, http.StatusUnauthorized, "unauthorized")
+		res, body = env.api(http.MethodGet, "/me", "", "")
+		expectErr(res, body, http.StatusUnauthorized, "unauthorized")
+
+		// Validación: 422; JSON roto: 400
+		for _, in := range []string{
+			`{"title": "", "content": "x", "categories": ["Go"]}`,
+			`{"title": "x", "content": "  ", "categories": ["Go"]}`,
+		} {
+			res, body = env.api(http.MethodPost, "/posts", tok, in)
+			expectErr(res, body, http.StatusUnprocessableEntity, "validation")
+		}
+		res, body = env.api(http.MethodPost, "/posts", tok, `{"title":`)
+		expectErr(res, body, http.StatusBadRequest, "invalid_json")
+
+		// Crear post: 201 con el post
+		res, body = env.api(http.MethodPost, "/posts", tok, newPost)
+		if res.StatusCode != http.StatusCreated {
+			t.Fatalf("create post status = %d: %s", res.StatusCode, body)
+		}
+		var post struct {
+			ID       int64  `json:"id"`
+			Title    string `json:"title"`
+			Author   string `json:"author"`
+			Likes    int    `json:"likes"`
+			Comments []struct {
+				ID      int64  `json:"id"`
+				Content string `json:"content"`
+			} `json:"comments"`
+		}
+		if err := json.Unmarshal([]byte(body), &post); err != nil || post.ID == 0 || post.Title != "Hi" || post.Author != "erin" {
+			t.Fatalf("created post: %s", body)
+		}
+		pid := strconv.FormatInt(post.ID, 10)
+
+		// Comentario: 201; vacío: 422; en un post que no existe: 404
+		res, body = env.api(http.MethodPost, "/posts/"+pid+"/comments", tok, `{"content": "Nice"}`)
+		if res.StatusCode != http.StatusCreated {
+			t.Fatalf("create comment status = %d: %s", res.StatusCode, body)
+		}
+		res, body = env.api(http.MethodPost, "/posts/"+pid+"/comments", tok, `{"content": ""}`)
+		expectErr(res, body, http.StatusUnprocessableEntity, "validation")
+		res, body = env.api(http.MethodPost, "/posts/999999/comments", tok, `{"content": "Lost"}`)
+		expectErr(res, body, http.StatusNotFound, "not_found")
+
+		// Post inexistente o id inválido; ruta desconocida
+		res, body = env.api(http.MethodGet, "/posts/999999", "", "")
+		expectErr(res, body, http.StatusNotFound, "not_found")
+		res, body = env.api(http.MethodGet, "/posts/abc", "", "")
+		expectErr(res, body, http.StatusBadRequest, "invalid_id")
+		res, body = env.api(http.MethodGet, "/nope", "", "")
+		expectErr(res, body, http.StatusNotFound, "not_found")
+
+		// Reacciones: PUT cuenta, DELETE la quita (y es idempotente)
+		likes := func() int {
+			t.Helper()
+			_, body := env.api(http.MethodGet, "/posts/"+pid, tok, "")
+			var p struct {
+				Likes      int `json:"likes"`
+				MyReaction int `json:"my_reaction"`
+			}
+			if err := json.Unmarshal([]byte(body), &p); err != nil {
+				t.Fatal(err)
+			}
+			if p.Likes != 0 && p.MyReaction != 1 {
+				t.Fatalf("likes without my_reaction: %s", body)
+			}
+			return p.Likes
+		}
+		res, body = env.api(http.MethodPut, "/posts/"+pid+"/reaction", tok, `{"value": 2}`)
+		expectErr(res, body, http.StatusUnprocessableEntity, "validation")
+		res, body = env.api(http.MethodPut, "/posts/999999/reaction", tok, `{"value": 1}`)
+		expectErr(res, body, http.StatusNotFound, "not_found")
+		if res, _ := env.api(http.MethodPut, "/posts/"+pid+"/reaction", tok, `{"value": 1}`); res.StatusCode != http.StatusNoContent {
+			t.Fatalf("react status = %d", res.StatusCode)
+		}
+		if n := likes(); n != 1 {
+			t.Fatalf("likes after PUT = %d", n)
+		}
+		for i := 0; i < 2; i++ {
+			if res, _ := env.api(http.MethodDelete, "/posts/"+pid+"/reaction", tok, ""); res.StatusCode != http.StatusNoContent {
+				t.Fatalf("unreact status = %d", res.StatusCode)
+			}
+		}
+		if n := likes(); n != 0 {
+			t.Fatalf("likes after DELETE = %d", n)
+		}
+	})
+}
+
+var patRe = regexp.MustCompile(`value="(fpat_[^"]+)"`)
+
+// Tokens personales: scopes por ruta, último uso y revocación.
+func TestAPITokenScopes(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st *store.Store) {
+		env := newTestEnv(t, st, app.EmailPolicyOff)
+		c := env.client()
+		c.register("frank")
+		ctx := context.Background()
+		u, err := st.Users.ByEmail(ctx, "frank@example.test")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		create := func(name string, scopes ...string) string {
+			t.Helper()
+			_, body := c.post("/settings/tokens", url.Values{"name": {name}, "scopes": scopes})
+			m := patRe.FindStringSubmatch(body)
+			if m == nil {
+				t.Fatalf("no token shown for %s", name)
+			}
+			return m[1]
+		}
+		readOnly := create("reader", auth.ScopeRead)
+		poster := create("poster", auth.ScopeRead, auth.ScopePost)
+		expectErr := func(res *http.Response, body string, status int, code string) {
+			t.Helper()
+			if res.StatusCode != status || apiErrorCode(t, body) != code {
+				t.Fatalf("status = %d, want %d %s: %s", res.StatusCode, status, code, body)
+			}
+		}
+
+		tokens, err := st.APITokens.ListForUser(ctx, u.ID)
+		if err != nil || len(tokens) != 2 {
+			t.Fatalf("tokens = %v, %v", tokens, err)
+		}
+		for _, tk := range tokens {
+			if tk.LastUsedAt != nil {
+				t.Fatalf("token %q used before any request", tk.Name)
+			}
+		}
+
+		// read: puede leer, no escribir ni reaccionar
+		if res, body := env.api(http.MethodGet, "/me", readOnly, ""); res.StatusCode != http.StatusOK || !strings.Contains(body, `"frank"`) {
+			t.Fatalf("read-only /me status = %d: %s", res.StatusCode, body)
+		}
+		res, body := env.api(http.MethodPost, "/posts", readOnly, `{"title": "x", "content": "y", "categories": ["Go"]}`)
+		expectErr(res, body, http.StatusForbidden, "insufficient_scope")
+		res, body = env.api(http.MethodPost, "/posts", poster, `{"title": "x", "content": "y", "categories": ["Go"]}`)
+		if res.StatusCode != http.StatusCreated {
+			t.Fatalf("poster create status = %d: %s", res.StatusCode, body)
+		}
+		var post struct {
+			ID int64 `json:"id"`
+		}
+		_ = json.Unmarshal([]byte(body), &post)
+		reaction := "/posts/" + strconv.FormatInt(post.ID, 10) + "/reaction"
+		for _, tok := range []string{readOnly, poster} {
+			res, body = env.api(http.MethodPut, reaction, tok, `{"value": 1}`)
+			expectErr(res, body, http.StatusForbidden, "insufficient_scope")
+		}
+		res, body = env.api(http.MethodGet, "/admin/audit", poster, "")
+		expectErr(res, body, http.StatusForbidden, "insufficient_scope")
+
+		// Cada request apunta el último uso
+		tokens, _ = st.APITokens.ListForUser(ctx, u.ID)
+		for _, tk := range tokens {
+			if tk.LastUsedAt == nil || time.Since(*tk.LastUsedAt) > time.Minute {
+				t.Fatalf("token %q last_used_at = %v", tk.Name, tk.LastUsedAt)
+			}
+		}
+
+		// Token mal formado, desconocido o revocado: 401
+		for _, h := range []string{"Token " + readOnly, "Bearer ", "Bearer fpat_unknown"} {
+			req, _ := http.NewRequest(http.MethodGet, env.srv.URL+apiPrefix+"/me", nil)
+			req.Header.Set("Authorization", h)
+			res, _ := http.DefaultClient.Do(req)
+			b, _ := io.ReadAll(res.Body)
+			res.Body.Close()
+			expectErr(res, string(b), http.StatusUnauthorized, "invalid_token")
+		}
+		var readerID int64
+		for _, tk := range tokens {
+			if tk.Name == "reader" {
+				readerID = tk.ID
+			}
+		}
+		c.post("/settings/tokens/revoke", url.Values{"id": {strconv.FormatInt(readerID, 10)}})
+		res, body = env.api(http.MethodGet, "/me", readOnly, "")
+		expectErr(res, body, http.StatusUnauthorized, "invalid_token")
+		if res, _ := env.api(http.MethodGet, "/me", poster, ""); res.StatusCode != http.StatusOK {
+			t.Fatalf("other token after revoke status = %d", res.StatusCode)
+		}
+	})
+}
+
+func TestSearch(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st *store.Store) {
+		env := newTestEnv(t, st, app.EmailPolicyOff)
+		c := env.client()
+		c.register("frank")
+		c.post("/post/create", url.Values{"title": {"Gopher tips"}, "content": {"<b>channels</b> and goroutines"}, "cats": {"Go"}})
+		c.post("/post/create", url.Values{"title": {"Ops notes"}, "content": {"docker channels"}, "cats": {"DevOps"}})
+
+		_, body := c.get("/search?q=" + url.QueryEscape("channels cat:Go"))
+		if !strings.Contains(body, "Gopher tips") || strings.Contains(body, "Ops notes") {
+			t.Fatal("cat: filter not applied")
+		}
+		if !strings.Contains(body, "&lt;b&gt;<mark>channels</mark>&lt;/b&gt;") {
+			t.Fatalf("snippet not escaped/highlighted:\n%s", body)
+		}
+		if _, body = c.get("/search?q=" + url.QueryEscape("channels -docker author:frank")); strings.Contains(body, "Ops notes") || !strings.Contains(body, "Gopher tips") {
+			t.Fatal("exclusion or author: not applied")
+		}
+		if _, body = c.get("/search?q=" + url.QueryEscape("channels from:yesterday")); !strings.Contains(body, "from: expects a date") {
+			t.Fatal("bad date not reported")
+		}
+	})
+}
+
+func TestModeratorLockAndPin(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st *store.Store) {
+		env := newTestEnv(t, st, app.EmailPolicyOff)
+		alice, mod := env.client(), env.client()
+		alice.register("alice")
+		mod.register("mod")
+		if err := auth.SetUserRole(context.Background(), st.Users, "mod", auth.RoleModerator); err != nil {
+			t.Fatal(err)
+		}
+
+		alice.post("/post/create", url.Values{"title": {"Older"}, "content": {"first"}, "cats": {"Go"}})
+		res, _ := alice.post("/post/create", url.Values{"title": {"Newer"}, "content": {"second"}, "cats": {"Go"}})
+		if res.StatusCode != http.StatusSeeOther {
+			t.Fatalf("create post status = %d", res.StatusCode)
+		}
+		_, body := alice.get("/")
+		m := regexp.MustCompile(`href="/post/(\d+)">Older<`).FindStringSubmatch(body)
+		if m == nil {
+			t.Fatal("no permalink for Older")
+		}
+		pid := m[1]
+
+		// Un miembro no llega a las herramientas de moderación
+		if res, _ := alice.post("/post/"+pid+"/lock", url.Values{"locked": {"1"}}); res.StatusCode != http.StatusForbidden {
+			t.Fatalf("member lock status = %d", res.StatusCode)
+		}
+
+		if res, _ := mod.post("/post/"+pid+"/lock", url.Values{"locked": {"1"}}); res.StatusCode != http.StatusSeeOther {
+			t.Fatalf("mod lock status = %d", res.StatusCode)
+		}
+		res, _ = alice.post("/comment/create", url.Values{"post_id": {pid}, "content": {"too late"}})
+		if loc := res.Header.Get("Location"); !strings.Contains(loc, "err=") {
+			t.Fatalf("comment on locked thread redirect = %q", loc)
+		}
+		if _, body := alice.get("/post/" + pid); strings.Contains(body, "too late") || !strings.Contains(body, "This thread is locked") {
+			t.Fatal("locked thread accepted a comment or shows the form")
+		}
+		if res, _ := mod.post("/comment/create", url.Values{"post_id": {pid}, "content": {"mod note"}}); !strings.Contains(res.Header.Get("Location"), "#comment-") {
+			t.Fatal("moderator could not comment on a locked thread")
+		}
+
+		// Fijado: sale antes que el post más nuevo y no se repite abajo
+		mod.post("/post/"+pid+"/pin", url.Values{"pinned": {"1"}})
+		_, body = alice.get("/")
+		older, newer := strings.Index(body, ">Older<"), strings.Index(body, ">Newer<")
+		if older < 0 || newer < 0 || older > newer {
+			t.Fatal("pinned post not shown first")
+		}
+		if strings.Count(body, ">Older<") != 1 {
+			t.Fatal("pinned post repeated in the list")
+		}
+	})
+}
+
+func TestReportQueue(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st *store.Store) {
+		env := newTestEnv(t, st, app.EmailPolicyOff)
+		alice, bob, mod := env.client(), env.client(), env.client()
+		alice.register("alice")
+		bob.register("bob")
+		mod.register("mod")
+		if err := auth.SetUserRole(context.Background(), st.Users, "mod", auth.RoleModerator); err != nil {
+			t.Fatal(err)
+		}
+
+		alice.post("/post/create", url.Values{"title": {"Buy pills"}, "content": {"cheap pills here"}, "cats": {"General"}})
+		_, body := alice.get("/")
+		m := regexp.MustCompile(`href="/post/(\d+)">Buy pills<`).FindStringSubmatch(body)
+		if m == nil {
+			t.Fatal("no permalink in index")
+		}
+		pid := m[1]
+
+		res, _ := bob.post("/report", url.Values{"target": {"post"}, "id": {pid}, "reason": {"spam"}})
+		if loc := res.Header.Get("Location"); !strings.Contains(loc, "reported=1") {
+			t.Fatalf("report redirect = %q", loc)
+		}
+		res, _ = bob.post("/report", url.Values{"target": {"post"}, "id": {pid}, "reason": {"spam again"}})
+		if loc := res.Header.Get("Location"); !strings.Contains(loc, "err=") {
+			t.Fatalf("duplicate report redirect = %q", loc)
+		}
+
+		if res, _ := alice.get("/mod/queue"); res.StatusCode != http.StatusForbidden {
+			t.Fatalf("member queue status = %d", res.StatusCode)
+		}
+		_, body = mod.get("/mod/queue")
+		if !strings.Contains(body, "reported by bob") || !strings.Contains(body, "cheap pills here") {
+			t.Fatal("report or reported content missing from queue")
+		}
+		m = regexp.MustCompile(`action="/mod/reports/(\d+)/resolve"`).FindStringSubmatch(body)
+		if m == nil {
+			t.Fatal("no resolve action in queue")
+		}
+
+		// Resolver borrando el contenido: la cola queda vacía y el post ya no existe
+		res, _ = mod.post("/mod/reports/"+m[1]+"/resolve", url.Values{"delete": {"1"}})
+		if loc := res.Header.Get("Location"); loc != "/mod/queue?closed=resolved" {
+			t.Fatalf("resolve redirect = %q", loc)
+		}
+		if _, body = mod.get("/mod/queue"); strings.Contains(body, "reported by bob") {
+			t.Fatal("resolved report still in queue")
+		}
+		if res, _ := bob.get("/post/" + pid); res.StatusCode != http.StatusNotFound {
+			t.Fatalf("deleted post status = %d", res.StatusCode)
+		}
+	})
+}
+
+func TestSanctions(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st *store.Store) {
+		env := newTestEnv(t, st, app.EmailPolicyOff)
+		mod, bob, carol := env.client(), env.client(), env.client()
+		mod.register("mod")
+		bob.register("bob")
+		carol.register("carol")
+		if err := auth.SetUserRole(context.Background(), st.Users, "mod", auth.RoleModerator); err != nil {
+			t.Fatal(err)
+		}
+		userID := func(name string) string {
+			_, body := mod.get("/mod/users?u=" + name)
+			m := regexp.MustCompile(`action="/mod/users/(\d+)/suspend"`).FindStringSubmatch(body)
+			if m == nil {
+				t.Fatalf("no sanction forms for %s", name)
+			}
+			return m[1]
+		}
+
+		// Suspensión: la sesión abierta muere y se explica en vez de un 401
+		res, _ := mod.post("/mod/users/"+userID("bob")+"/suspend", url.Values{"days": {"3"}, "reason": {"flooding"}})
+		if loc := res.Header.Get("Location"); !strings.Contains(loc, "done=suspend") {
+			t.Fatalf("suspend redirect = %q", loc)
+		}
+		res, body := bob.get("/")
+		if res.StatusCode != http.StatusForbidden || !strings.Contains(body, "suspended") || !strings.Contains(body, "flooding") {
+			t.Fatalf("suspended user page = %d", res.StatusCode)
+		}
+		if res, _ := bob.post("/post/create", url.Values{"title": {"x"}, "content": {"y"}, "cats": {"Go"}}); res.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("create after suspension status = %d", res.StatusCode)
+		}
+		res, body = bob.post("/login", url.Values{"email": {"bob@example.test"}, "password": {"secret123"}})
+		if res.StatusCode != http.StatusForbidden || !strings.Contains(body, "suspended") {
+			t.Fatalf("suspended login status = %d", res.StatusCode)
+		}
+
+		// Silencio: puede navegar pero no escribir (ni denunciar)
+		mod.post("/post/create", url.Values{"title": {"Rules"}, "content": {"be nice"}, "cats": {"Go"}})
+		_, body = mod.get("/")
+		m := regexp.MustCompile(`href="/post/(\d+)">Rules<`).FindStringSubmatch(body)
+		if m == nil {
+			t.Fatal("no permalink in index")
+		}
+		mod.post("/mod/users/"+userID("carol")+"/mute", url.Values{"days": {"1"}, "reason": {"rude"}})
+		if res, body := carol.get("/"); res.StatusCode != http.StatusOK || !strings.Contains(body, "read-only") {
+			t.Fatalf("muted browse status = %d", res.StatusCode)
+		}
+		if res, _ := carol.post("/post/create", url.Values{"title": {"x"}, "content": {"y"}, "cats": {"Go"}}); res.StatusCode != http.StatusForbidden {
+			t.Fatalf("muted create status = %d", res.StatusCode)
+		}
+		if res, _ := carol.post("/report", url.Values{"target": {"post"}, "id": {m[1]}, "reason": {"meh"}}); res.StatusCode != http.StatusForbidden {
+			t.Fatalf("muted report status = %d", res.StatusCode)
+		}
+		if _, body = mod.get("/mod/queue"); strings.Contains(body, "reported by carol") {
+			t.Fatal("muted user's report reached the queue")
+		}
+
+		// Un moderador no puede sancionar a otro moderador (ni a sí mismo)
+		_, body = mod.get("/mod/users?u=mod")
+		if strings.Contains(body, "/suspend\"") {
+			t.Fatal("moderator offered sanctions on a moderator")
+		}
+	})
+}
+
+func TestAuditLog(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st *store.Store) {
+		env := newTestEnv(t, st, app.EmailPolicyOff)
+		admin, mod, bob := env.client(), env.client(), env.client()
+		admin.register("admin")
+		mod.register("mod")
+		bob.register("bob")
+		ctx := context.Background()
+		if err := auth.SetUserRole(ctx, st.Users, "admin", auth.RoleAdmin); err != nil {
+			t.Fatal(err)
+		}
+		if err := auth.SetUserRole(ctx, st.Users, "mod", auth.RoleModerator); err != nil {
+			t.Fatal(err)
+		}
+		_, body := mod.get("/mod/users?u=bob")
+		m := regexp.MustCompile(`action="/mod/users/(\d+)/mute"`).FindStringSubmatch(body)
+		if m == nil {
+			t.Fatal("no mute form for bob")
+		}
+		mod.post("/mod/users/"+m[1]+"/mute", url.Values{"days": {"2"}, "reason": {"spam"}})
+
+		// Solo admins
+		if res, _ := mod.get("/admin/audit"); res.StatusCode != http.StatusForbidden {
+			t.Fatalf("moderator audit status = %d", res.StatusCode)
+		}
+		res, body := admin.get("/admin/audit?action=user.")
+		if res.StatusCode != http.StatusOK || !strings.Contains(body, "<code>user.mute</code>") || !strings.Contains(body, "spam") {
+			t.Fatalf("audit page status = %d, missing mute entry", res.StatusCode)
+		}
+		if _, body = admin.get("/admin/audit?action=post."); strings.Contains(body, "<code>user.mute</code>") {
+			t.Fatal("action filter ignored")
+		}
+
+		// Borrar contenido propio también queda apuntado
+		admin.post("/post/create", url.Values{"title": {"Own post"}, "content": {"mine"}, "cats": {"Go"}})
+		_, body = admin.get("/")
+		pid := regexp.MustCompile(`href="/post/(\d+)"`).FindStringSubmatch(body)[1]
+		admin.post("/post/"+pid+"/delete", nil)
+		if _, body = admin.get("/admin/audit?action=post.delete"); !strings.Contains(body, "<code>post.delete</code>") || !strings.Contains(body, "Own post") {
+			t.Fatal("owner delete not audited")
+		}
+
+		res, body = admin.get("/admin/audit/export?actor=mod")
+		if !strings.Contains(res.Header.Get("Content-Disposition"), "attachment") {
+			t.Fatalf("export disposition = %q", res.Header.Get("Content-Disposition"))
+		}
+		var entries []struct {
+			Action string          `json:"action"`
+			Actor  string          `json:"actor"`
+			Before json.RawMessage `json:"before"`
+			After  struct {
+				MuteReason string `json:"mute_reason"`
+			} `json:"after"`
+		}
+		if err := json.Unmarshal([]byte(body), &entries); err != nil {
+			t.Fatalf("export: %v", err)
+		}
+		if len(entries) != 1 || entries[0].Action != auth.AuditUserMute || entries[0].Actor != "mod" || entries[0].After.MuteReason != "spam" {
+			t.Fatalf("export = %+v", entries)
+		}
+	})
+}
+
+func TestLoginRateLimit(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st *store.Store) {
+		env := newTestEnv(t, st, app.EmailPolicyOff)
+		admin, bob := env.client(), env.client()
+		admin.register("admin")
+		bob.register("bob")
+		ctx := context.Background()
+		if err := auth.SetUserRole(ctx, st.Users, "admin", auth.RoleAdmin); err != nil {
+			t.Fatal(err)
+		}
+		login := func(password string) string {
+			res, _ := env.client().post("/login", url.Values{"email": {"bob@example.test"}, "password": {password}})
+			return res.Header.Get("Location")
+		}
+
+		// Los primeros fallos pasan; después hay que esperar, aunque la contraseña sea buena
+		for i := 0; i < auth.AccountLimit.Free; i++ {
+			if loc := login("wrong"); !strings.Contains(loc, "Invalid") {
+				t.Fatalf("failure %d redirect = %q", i+1, loc)
+			}
+		}
+		if loc := login("secret123"); !strings.Contains(loc, "Too+many") {
+			t.Fatalf("throttled login redirect = %q", loc)
+		}
+		res, err := http.Post(env.srv.URL+"/api/v1/auth/token", "application/json",
+			strings.NewReader(`{"email": "bob@example.test", "password": "secret123"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusTooManyRequests || res.Header.Get("Retry-After") == "" {
+			t.Fatalf("api throttled status = %d, Retry-After %q", res.StatusCode, res.Header.Get("Retry-After"))
+		}
+
+		// Bloqueo: lo ven los moderadores y solo un admin lo levanta
+		for i := 0; i < auth.AccountLimit.Lockout; i++ {
+			if err := st.LoginAttempts.Fail(ctx, "account:bob@example.test", time.Now()); err != nil {
+				t.Fatal(err)
+			}
+		}
+		_, body := admin.get("/mod/users?u=bob")
+		m := regexp.MustCompile(`action="/admin/users/(\d+)/unlock"`).FindStringSubmatch(body)
+		if m == nil || !strings.Contains(body, "Sign-in locked") {
+			t.Fatal("no lock shown on /mod/users")
+		}
+		if res, _ := bob.post("/admin/users/"+m[1]+"/unlock", nil); res.StatusCode != http.StatusForbidden {
+			t.Fatalf("member unlock status = %d", res.StatusCode)
+		}
+		admin.post("/admin/users/"+m[1]+"/unlock", nil)
+		if loc := login("secret123"); loc != "/" {
+			t.Fatalf("login after unlock redirect = %q", loc)
+		}
+		if _, body = admin.get("/admin/audit?action=user.unlock"); !strings.Contains(body, "<code>user.unlock</code>") {
+			t.Fatal("unlock not audited")
+		}
+	})
+}
+
+func TestTwoFactor(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st *store.Store) {
+		env := newTestEnv(t, st, app.EmailPolicyOff)
+		alice := env.client()
+		alice.register("alice")
+		creds := url.Values{"email": {"alice@example.test"}, "password": {"secret123"}}
+
+		// Alta: secreto pendiente, se confirma con un código de la app
+		alice.post("/settings/2fa/setup", nil)
+		_, body := alice.get("/settings/2fa")
+		m := regexp.MustCompile(`value="([A-Z2-7]{32})" readonly`).FindStringSubmatch(body)
+		if m == nil {
+			t.Fatal("no TOTP key on /settings/2fa")
+		}
+		secret := m[1]
+		if res, _ := alice.post("/settings/2fa/enable", url.Values{"code": {"000000"}}); !strings.Contains(res.Header.Get("Location"), "err=") {
+			t.Fatal("wrong code enabled 2FA")
+		}
+		code, _ := auth.TOTPCode(secret, time.Now())
+		_, body = alice.post("/settings/2fa/enable", url.Values{"code": {code}})
+		recovery := regexp.MustCompile(`<code>([a-z2-9]{5}-[a-z2-9]{5})</code>`).FindAllStringSubmatch(body, -1)
+		if len(recovery) != 10 {
+			t.Fatalf("got %d recovery codes, want 10", len(recovery))
+		}
+
+		// La contraseña ya no basta: hace falta el segundo paso
+		challenge := func(c *client) string {
+			t.Helper()
+			res, body := c.post("/login", creds)
+			m := regexp.MustCompile(`name="challenge" value="([^"]+)"`).FindStringSubmatch(body)
+			if res.StatusCode != http.StatusOK || m == nil {
+				t.Fatalf("login with 2FA status = %d, no challenge", res.StatusCode)
+			}
+			if _, me := c.get("/debug/me"); me != "anon" {
+				t.Fatal("session created before the second factor")
+			}
+			return m[1]
+		}
+		phone := env.client()
+		ch := challenge(phone)
+		// El código del alta ya se usó en su paso de 30s
+		if res, _ := phone.post("/login/2fa", url.Values{"challenge": {ch}, "code": {code}}); res.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("replayed code status = %d", res.StatusCode)
+		}
+		next, _ := auth.TOTPCode(secret, time.Now().Add(30*time.Second))
+		if res, _ := phone.post("/login/2fa", url.Values{"challenge": {ch}, "code": {next}}); res.Header.Get("Location") != "/" {
+			t.Fatalf("valid code redirect = %q", res.Header.Get("Location"))
+		}
+		if _, me := phone.get("/debug/me"); !strings.HasPrefix(me, "logged") {
+			t.Fatal("no session after the second factor")
+		}
+
+		// Código de recuperación: sirve una vez
+		rc := strings.ToUpper(recovery[0][1])
+		laptop := env.client()
+		if res, _ := laptop.post("/login/2fa", url.Values{"challenge": {challenge(laptop)}, "code": {rc}}); res.Header.Get("Location") != "/" {
+			t.Fatalf("recovery code redirect = %q", res.Header.Get("Location"))
+		}
+		other := env.client()
+		if res, _ := other.post("/login/2fa", url.Values{"challenge": {challenge(other)}, "code": {rc}}); res.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("reused recovery code status = %d", res.StatusCode)
+		}
+
+		// Desactivar pide la contraseña
+		if res, _ := laptop.post("/settings/2fa/disable", url.Values{"password": {"nope"}}); !strings.Contains(res.Header.Get("Location"), "err=") {
+			t.Fatal("disabled 2FA with a wrong password")
+		}
+		laptop.post("/settings/2fa/disable", url.Values{"password": {"secret123"}})
+		if res, _ := env.client().post("/login", creds); res.Header.Get("Location") != "/" {
+			t.Fatalf("login after disabling 2FA redirect = %q", res.Header.Get("Location"))
+		}
+	})
+}
+
+func TestDevices(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st *store.Store) {
+		env := newTestEnv(t, st, app.EmailPolicyOff)
+		laptop, phone, tablet := env.client(), env.client(), env.client()
+		laptop.register("alice")
+		creds := url.Values{"email": {"alice@example.test"}, "password": {"secret123"}}
+		for _, c := range []*client{phone, tablet} {
+			if res, _ := c.post("/login", creds); res.Header.Get("Location") != "/" {
+				t.Fatalf("login redirect = %q", res.Header.Get("Location"))
+			}
+		}
+		me := func(c *client) string { _, body := c.get("/debug/me"); return body }
+
+		// Entrar en otro dispositivo ya no cierra los demás
+		for i, c := range []*client{laptop, phone, tablet} {
+			if got := me(c); !strings.HasPrefix(got, "logged") {
+				t.Fatalf("client %d = %q", i, got)
+			}
+		}
+		_, page := laptop.get("/settings/sessions")
+		if strings.Count(page, "Go client") != 3 || strings.Count(page, "This device") != 1 || !strings.Contains(page, "127.0.0.1") {
+			t.Fatalf("devices page = %q", page)
+		}
+		handles := regexp.MustCompile(`name="handle" value="([0-9a-f]+)"`).FindAllStringSubmatch(page, -1)
+		if len(handles) != 2 {
+			t.Fatalf("revoke forms = %d, want 2", len(handles))
+		}
+
+		// Revocar una sesión ajena a la request
+		laptop.post("/settings/sessions/revoke", url.Values{"handle": {handles[0][1]}})
+		loggedIn := 0
+		for _, c := range []*client{phone, tablet} {
+			if strings.HasPrefix(me(c), "logged") {
+				loggedIn++
+			}
+		}
+		if loggedIn != 1 || !strings.HasPrefix(me(laptop), "logged") {
+			t.Fatalf("after revoke: %d of phone/tablet logged in, laptop %q", loggedIn, me(laptop))
+		}
+
+		// Otro usuario no puede cerrar sesiones de alice
+		bob := env.client()
+		bob.register("bob")
+		if res, _ := bob.post("/settings/sessions/revoke", url.Values{"handle": {handles[1][1]}}); !strings.Contains(res.Header.Get("Location"), "err=") {
+			t.Fatalf("foreign revoke redirect = %q", res.Header.Get("Location"))
+		}
+
+		// "Sign out everywhere else"
+		laptop.post("/settings/sessions/others", nil)
+		if me(phone) != "anon" || me(tablet) != "anon" || !strings.HasPrefix(me(laptop), "logged") {
+			t.Fatalf("after sign out others: phone %q tablet %q laptop %q", me(phone), me(tablet), me(laptop))
+		}
+	})
+}
+
+func TestSessionRenewal(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st *store.Store) {
+		env := newTestEnv(t, st, app.EmailPolicyOff)
+		env.client().register("alice")
+		ctx := context.Background()
+		creds := url.Values{"email": {"alice@example.test"}, "password": {"secret123"}}
+		sessionCookie := func(res *http.Response) *http.Cookie {
+			for _, c := range res.Cookies() {
+				if c.Name == CookieName {
+					return c
+				}
+			}
+			return nil
+		}
+
+		// Sin "remember me" la cookie es de navegador; con él, persistente
+		res, _ := env.client().post("/login", creds)
+		if c := sessionCookie(res); c == nil || !c.Expires.IsZero() || c.MaxAge != 0 {
+			t.Fatalf("browser-session cookie = %+v", c)
+		}
+		keep := env.client()
+		res, _ = keep.post("/login", url.Values{"email": creds["email"], "password": creds["password"], "remember": {"1"}})
+		c := sessionCookie(res)
+		if c == nil || c.Expires.IsZero() {
+			t.Fatalf("remember-me cookie = %+v", c)
+		}
+		sid := c.Value
+
+		// Recién emitida no se toca; pasada la mitad de la vida se renueva
+		if res, _ := keep.get("/"); sessionCookie(res) != nil {
+			t.Fatal("fresh session re-issued its cookie")
+		}
+		if err := st.Sessions.Renew(ctx, auth.HashToken(sid), time.Now().Add(20*time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+		res, _ = keep.get("/")
+		if c := sessionCookie(res); c == nil || c.Value != sid || time.Until(c.Expires) < 50*time.Minute {
+			t.Fatalf("renewed cookie = %+v", c)
+		}
+		if ses, err := st.Sessions.Get(ctx, auth.HashToken(sid)); err != nil || time.Until(ses.ExpiresAt) < 50*time.Minute {
+			t.Fatalf("renewed session = %+v, %v", ses, err)
+		}
+
+		// La renovación no pasa del tope desde el login
+		u, err := st.Users.ByEmail(ctx, "alice@example.test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		now := time.Now()
+		old := models.Session{ID: auth.HashToken("old-" + sid), UserID: u.ID, CSRFToken: "csrf", Persistent: true, Role: u.Role,
+			LastSeenAt: now, ExpiresAt: now.Add(5 * time.Minute), CreatedAt: now.Add(-24*time.Hour + 10*time.Minute)}
+		if err := st.Sessions.Create(ctx, old); err != nil {
+			t.Fatal(err)
+		}
+		veteran := env.client()
+		base, _ := url.Parse(env.srv.URL)
+		veteran.hc.Jar.SetCookies(base, []*http.Cookie{{Name: CookieName, Value: "old-" + sid, Path: "/"}})
+		veteran.get("/")
+		if ses, err := st.Sessions.Get(ctx, old.ID); err != nil || ses.ExpiresAt.After(old.CreatedAt.Add(24*time.Hour)) {
+			t.Fatalf("capped session = %+v, %v", ses, err)
+		}
+
+		// Un cambio de rol rota el id: el viejo deja de valer
+		if err := auth.SetUserRole(ctx, st.Users, "alice", auth.RoleModerator); err != nil {
+			t.Fatal(err)
+		}
+		res, body := keep.get("/debug/me")
+		c = sessionCookie(res)
+		if c == nil || c.Value == sid || !strings.HasPrefix(body, "logged") {
+			t.Fatalf("after role change cookie = %+v, me = %q", c, body)
+		}
+		if _, err := st.Sessions.Get(ctx, auth.HashToken(sid)); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("old id after rotation err = %v", err)
+		}
+		if _, body := keep.get("/debug/me"); !strings.HasPrefix(body, "logged") {
+			t.Fatalf("rotated session me = %q", body)
+		}
+	})
+}
+
+// La caducidad que ve el cliente (cookie o expires_at de la API) es la de la
+// sesión guardada, con el tope de SessionMaxAge aplicado.
+func TestLoginExpiryFromSession(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st *store.Store) {
+		env := newTestEnv(t, st, app.EmailPolicyOff)
+		env.server.Cfg.SessionMaxAge = 30 * time.Minute // menos que SessionLifetime
+		env.client().register("alice")
+		ctx := context.Background()
+
+		res, _ := env.client().post("/login", url.Values{"email": {"alice@example.test"}, "password": {"secret123"}, "remember": {"1"}})
+		var cookie *http.Cookie
+		for _, c := range res.Cookies() {
+			if c.Name == CookieName {
+				cookie = c
+			}
+		}
+		if cookie == nil {
+			t.Fatal("no session cookie")
+		}
+		ses, err := st.Sessions.Get(ctx, auth.HashToken(cookie.Value))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if time.Until(ses.ExpiresAt) > 31*time.Minute || !cookie.Expires.Equal(ses.ExpiresAt.Truncate(time.Second)) {
+			t.Fatalf("cookie expires %s, session %s", cookie.Expires, ses.ExpiresAt)
+		}
+
+		res, err = http.Post(env.srv.URL+"/api/v1/auth/token", "application/json",
+			strings.NewReader(`{"email": "alice@example.test", "password": "secret123"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		var out struct {
+			Token     string    `json:"token"`
+			ExpiresAt time.Time `json:"expires_at"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&out); err != nil {
+			t.Fatal(err)
+		}
+		ses, err = st.Sessions.Get(ctx, auth.HashToken(out.Token))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d := out.ExpiresAt.Sub(ses.ExpiresAt); d > time.Millisecond || d < -time.Millisecond {
+			t.Fatalf("api expires_at %s, session %s", out.ExpiresAt, ses.ExpiresAt)
+		}
+	})
+}
+
+func TestSessionTokensHashed(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st *store.Store) {
+		var logs bytes.Buffer
+		log.SetOutput(&logs)
+		defer log.SetOutput(os.Stderr)
+
+		env := newTestEnv(t, st, app.EmailPolicyOff)
+		c := env.client()
+		c.register("alice")
+		c.get("/")
+		base, _ := url.Parse(env.srv.URL)
+		var token string
+		for _, k := range c.hc.Jar.Cookies(base) {
+			if k.Name == CookieName {
+				token = k.Value
+			}
+		}
+		if len(token) < 40 {
+			t.Fatalf("session token %q is too short", token)
+		}
+
+		// En la BD solo está el SHA-256 del token
+		ctx := context.Background()
+		if _, err := st.Sessions.Get(ctx, token); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("raw token lookup err = %v", err)
+		}
+		if _, err := st.Sessions.Get(ctx, auth.HashToken(token)); err != nil {
+			t.Fatalf("hashed token lookup err = %v", err)
+		}
+		// Ni en los logs
+		if strings.Contains(logs.String(), token) {
+			t.Fatal("session token written to the log")
+		}
+	})
+}
+
+func TestAccountSettings(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st *store.Store) {
+		env := newTestEnv(t, st, app.EmailPolicyOff)
+		laptop, phone := env.client(), env.client()
+		laptop.register("erin")
+		phone.post("/login", url.Values{"email": {"erin@example.test"}, "password": {"secret123"}})
+		me := func(c *client) string { _, body := c.get("/debug/me"); return body }
+		location := func(res *http.Response) string { return res.Header.Get("Location") }
+
+		// Contraseña: pide la actual, cierra las demás sesiones (no esta) y
+		// revoca los tokens personales
+		_, body := laptop.post("/settings/tokens", url.Values{"name": {"ci"}, "scopes": {auth.ScopeRead}})
+		pat := patRe.FindStringSubmatch(body)[1]
+		res, _ := laptop.post("/settings/password", url.Values{"current_password": {"wrong"}, "new_password": {"newpass1"}, "confirm": {"newpass1"}})
+		if !strings.Contains(location(res), "err=") {
+			t.Fatalf("wrong current password redirect = %q", location(res))
+		}
+		res, _ = laptop.post("/settings/password", url.Values{"current_password": {"secret123"}, "new_password": {"newpass1"}, "confirm": {"newpass1"}})
+		if !strings.Contains(location(res), "done=") {
+			t.Fatalf("change password redirect = %q", location(res))
+		}
+		if me(phone) != "anon" || !strings.HasPrefix(me(laptop), "logged") {
+			t.Fatalf("after password change: phone %q laptop %q", me(phone), me(laptop))
+		}
+		if res, _ := env.api(http.MethodGet, "/me", pat, ""); res.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("api token after password change status = %d", res.StatusCode)
+		}
+		if res, _ := phone.post("/login", url.Values{"email": {"erin@example.test"}, "password": {"newpass1"}}); location(res) != "/" {
+			t.Fatalf("login with new password redirect = %q", location(res))
+		}
+
+		// Email: no cambia hasta abrir el enlace mandado a la dirección nueva
+		res, _ = laptop.post("/settings/email", url.Values{"email": {"Erin.New@example.test"}, "password": {"newpass1"}})
+		if !strings.Contains(location(res), "done=") {
+			t.Fatalf("change email redirect = %q", location(res))
+		}
+		if _, page := laptop.get("/settings"); !strings.Contains(page, "erin@example.test") || !strings.Contains(page, "erin.new@example.test") {
+			t.Fatalf("settings with pending email = %q", page)
+		}
+		msg, ok := env.mail.Last("erin.new@example.test")
+		if !ok {
+			t.Fatal("no confirmation mail")
+		}
+		link := linkRe.FindStringSubmatch(msg.Body)[1]
+		if res, _ := env.client().get(link); location(res) != "/login?verified=1" {
+			t.Fatalf("confirm redirect = %q", location(res))
+		}
+		if _, ok := env.mail.Last("erin@example.test"); !ok {
+			t.Fatal("old address not notified")
+		}
+		if res, _ := env.client().get(link); !strings.Contains(location(res), "err=") {
+			t.Fatalf("reused link redirect = %q", location(res))
+		}
+		if res, _ := env.client().post("/login", url.Values{"email": {"erin.new@example.test"}, "password": {"newpass1"}}); location(res) != "/" {
+			t.Fatalf("login with new email redirect = %q", location(res))
+		}
+
+		// Nombre: el anterior queda reservado para erin
+		env.client().register("frank")
+		if res, _ := laptop.post("/settings/username", url.Values{"username": {"frank"}}); !strings.Contains(location(res), "err=") {
+			t.Fatalf("taken username redirect = %q", location(res))
+		}
+		if res, _ := laptop.post("/settings/username", url.Values{"username": {"erin2"}}); !strings.Contains(location(res), "done=") {
+			t.Fatalf("change username redirect = %q", location(res))
+		}
+		if _, page := laptop.get("/settings"); !strings.Contains(page, "<strong>erin2</strong>") || !strings.Contains(page, "Previously: erin.") {
+			t.Fatalf("settings after rename = %q", page)
+		}
+		res, _ = env.client().post("/register", url.Values{"email": {"mallory@example.test"}, "username": {"erin"}, "password": {"secret123"}})
+		if !strings.Contains(location(res), "err=Username+already+taken") {
+			t.Fatalf("registering an old username redirect = %q", location(res))
+		}
+	})
+}
@@ -1,10 +1,17 @@
 package httpx
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
 	"log"
 	"net/http"
+	"net/url"
 	"time"
 
+	"forum/internal/app"
 	"forum/internal/auth"
 )
 
@@ -15,13 +22,51 @@ func (s *Server) withSession(next http.Handler) http.Handler {
 		// Lee la cookie
 		if c, err := r.Cookie(CookieName); err == nil && c.Value != "" {
 			// Valida la sesión en BD
-			if uid, exp, err2 := auth.UserFromSession(s.DB, c.Value); err2 == nil && exp.After(time.Now()) {
-				// OK: inyecta uid en el contexto y loguea
-				r = r.WithContext(auth.WithUserID(r.Context(), uid))
-				log.Printf("session OK sid=%s uid=%d exp=%s", c.Value, uid, exp.Format(time.RFC3339))
+			if ses, err2 := auth.UserFromSession(r.Context(), s.Store.Sessions, c.Value); err2 == nil && ses.ExpiresAt.After(time.Now()) {
+				now := time.Now()
+				token := c.Value
+				reissue := false // la cookie cambia: token rotado o caducidad renovada
+				if u, err3 := s.Store.Users.ByID(r.Context(), ses.UserID); err3 == nil {
+					// Cuenta baneada o suspendida: mata todas sus sesiones y lo explica
+					if sn := auth.Barred(u, now); sn != nil {
+						log.Printf("session KILL session=%s uid=%d: %v", auth.SessionHandle(ses.ID), ses.UserID, sn)
+						_ = s.Store.Sessions.DeleteForUser(r.Context(), ses.UserID)
+						endSession(w)
+						s.renderSanction(w, r, sn)
+						return
+					}
+					// Cambió el rol desde que se emitió el token: token nuevo, para
+					// que uno filtrado o fijado antes no herede los privilegios
+					if ses.Role != u.Role {
+						if rotated, newToken, err := auth.RotateSession(r.Context(), s.Store.Sessions, ses, u.Role, now); err != nil {
+							log.Printf("session rotate uid=%d err=%v", ses.UserID, err)
+						} else {
+							ses, token, reissue = rotated, newToken, true
+						}
+					}
+				}
+				// Expiración deslizante: los usuarios activos no caducan a mitad
+				if renewed, ok, err := auth.RenewSession(r.Context(), s.Store.Sessions, ses, s.sessionPolicy(), now); err != nil {
+					log.Printf("session renew uid=%d err=%v", ses.UserID, err)
+				} else if ok {
+					ses, reissue = renewed, true
+				}
+				if reissue {
+					s.setSessionCookie(w, token, ses.Persistent, ses.ExpiresAt)
+				}
+				// Apunta la actividad para la página de dispositivos
+				if err := auth.TouchSession(r.Context(), s.Store.Sessions, ses, clientIP(r), now); err != nil {
+					log.Printf("session touch uid=%d err=%v", ses.UserID, err)
+				}
+				// OK: inyecta uid, sesión y token CSRF en el contexto y loguea
+				ctx := auth.WithUserID(r.Context(), ses.UserID)
+				ctx = context.WithValue(ctx, ctxKeySession{}, ses.ID)
+				ctx = context.WithValue(ctx, ctxKeyCSRF{}, ses.CSRFToken)
+				r = r.WithContext(ctx)
+				log.Printf("session OK session=%s uid=%d exp=%s", auth.SessionHandle(ses.ID), ses.UserID, ses.ExpiresAt.Format(time.RFC3339))
 			} else {
-				// Sesión inválida/expirada
-				log.Printf("session FAIL sid=%s err=%v", c.Value, err2)
+				// Sesión inválida/expirada (el token nunca va al log)
+				log.Printf("session FAIL err=%v", err2)
 			}
 		} else {
 			// No hay cookie de sesión (navegación anónima)
@@ -32,6 +77,88 @@ func (s *Server) withSession(next http.Handler) http.Handler {
 	})
 }
 
+type ctxKeySession struct{}
+
+// sessionID devuelve el id guardado (el hash del token) de la sesión de cookie
+// de la request ("" sin sesión).
+func sessionID(ctx context.Context) string {
+	v, _ := ctx.Value(ctxKeySession{}).(string)
+	return v
+}
+
+// ——— CSRF ———
+
+const (
+	CSRFCookieName = "csrf_token" // token para visitantes sin sesión
+	CSRFFieldName  = "csrf_token" // campo oculto de los formularios
+	CSRFHeaderName = "X-CSRF-Token"
+)
+
+type ctxKeyCSRF struct{}
+
+// csrfToken devuelve el token que deben llevar los formularios de esta request.
+func csrfToken(ctx context.Context) string {
+	v, _ := ctx.Value(ctxKeyCSRF{}).(string)
+	return v
+}
+
+// withCSRF aplica el patrón synchronizer token: con sesión se usa el token
+// guardado en la fila de sessions; sin sesión, uno aleatorio en una cookie.
+// Solo GET y HEAD pasan sin token; cualquier otro método sin el token
+// correcto recibe 403. HEAD sigue como GET (net/http ya descarta el cuerpo),
+// así que detrás solo llegan GET o peticiones con token válido, y las rutas
+// (ver NewServer) deciden qué métodos acepta cada una. Debe ir después de
+// withSession.
+func (s *Server) withCSRF(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			r = r.Clone(r.Context())
+			r.Method = http.MethodGet
+		}
+		token := csrfToken(r.Context())
+		if token == "" {
+			if c, err := r.Cookie(CSRFCookieName); err == nil && len(c.Value) >= 32 {
+				token = c.Value
+			} else if isSafeMethod(r.Method) {
+				token = randomToken()
+				http.SetCookie(w, &http.Cookie{
+					Name:     CSRFCookieName,
+					Value:    token,
+					Path:     "/",
+					HttpOnly: true,
+					SameSite: http.SameSiteLaxMode,
+				})
+			}
+			r = r.WithContext(context.WithValue(r.Context(), ctxKeyCSRF{}, token))
+		}
+
+		if !isSafeMethod(r.Method) {
+			sent := r.Header.Get(CSRFHeaderName)
+			if sent == "" {
+				sent = r.FormValue(CSRFFieldName)
+			}
+			if token == "" || subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+				log.Printf("csrf FAIL %s %s", r.Method, r.URL.Path)
+				http.Error(w, "Forbidden: invalid CSRF token", http.StatusForbidden)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func isSafeMethod(m string) bool {
+	return m == http.MethodGet || m == http.MethodHead
+}
+
+func randomToken() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err) // crypto/rand no falla en plataformas soportadas
+	}
+	return hex.EncodeToString(b)
+}
+
 func (s *Server) requireAuth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if _, ok := auth.UserIDFrom(r.Context()); !ok {
@@ -42,6 +169,68 @@ func (s *Server) requireAuth(next http.Handler) http.Handler {
 	})
 }
 
+// requireRole deja pasar solo a usuarios con al menos el rol min (ver
+// auth.HasRole). Va detrás de requireAuth.
+func (s *Server) requireRole(min string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		uid, ok := auth.UserIDFrom(r.Context())
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		role, err := auth.UserRole(r.Context(), s.Store.Users, uid)
+		if err != nil {
+			http.Error(w, "Internal error", http.StatusInternalServerError)
+			return
+		}
+		if !auth.HasRole(role, min) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireVerified bloquea las acciones de escritura si la política exige email verificado.
+func (s *Server) requireVerified(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.Cfg.EmailPolicy == app.EmailPolicyOff {
+			next.ServeHTTP(w, r)
+			return
+		}
+		uid, _ := auth.UserIDFrom(r.Context())
+		ok, err := auth.IsEmailVerified(r.Context(), s.Store.Users, uid)
+		if err != nil {
+			http.Error(w, "Internal error", http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			http.Redirect(w, r, "/?err="+url.QueryEscape("Please confirm your email address before posting"), http.StatusSeeOther)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireWritable bloquea las acciones de escritura de cuentas silenciadas
+// (ver auth.Mute) con la página que explica la sanción.
+func (s *Server) requireWritable(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		uid, _ := auth.UserIDFrom(r.Context())
+		err := auth.CheckWrite(r.Context(), s.Store.Users, uid)
+		var sn *auth.Sanction
+		if errors.As(err, &sn) {
+			s.renderSanction(w, r, sn)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Internal error", http.StatusInternalServerError)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 // ——— access log ———
 
 type statusRW struct {
@@ -0,0 +1,493 @@
+package httpx
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"forum/internal/auth"
+	"forum/internal/models"
+	"forum/internal/store"
+	"forum/internal/util"
+)
+
+// Herramientas de moderación. Salvo /report, las rutas van detrás de
+// requireRole(auth.RoleModerator); borrar contenido ajeno está en edit.go.
+
+const (
+	maxReportReason   = 500 // caracteres
+	modQueueSize      = 100 // denuncias por página en /mod/queue
+	maxSanctionDays   = 365 // suspensiones y silencios más largos: mejor un baneo
+	maxSanctionReason = 500
+)
+
+// sanctionAudit es la acción del registro para cada /mod/users/{id}/{action}.
+var sanctionAudit = map[string]string{
+	"ban":     auth.AuditUserBan,
+	"suspend": auth.AuditUserSuspend,
+	"unban":   auth.AuditUserUnban,
+	"mute":    auth.AuditUserMute,
+	"unmute":  auth.AuditUserUnmute,
+}
+
+type reportVM struct {
+	ID        int64
+	Target    string // "post" | "comment"
+	TargetID  int64
+	URL       string // enlace al contenido; vacío si ya se borró
+	PostTitle string
+	Content   string
+	Author    string
+	Reporter  string
+	Reason    string
+	Created   string
+}
+
+func toReportVM(r models.Report) reportVM {
+	vm := reportVM{
+		ID:        r.ID,
+		Target:    r.Target,
+		TargetID:  r.TargetID,
+		PostTitle: r.PostTitle,
+		Content:   r.Content,
+		Author:    r.Author,
+		Reporter:  r.Reporter,
+		Reason:    r.Reason,
+		Created:   r.CreatedAt.Format("2006-01-02 15:04"),
+	}
+	if r.PostID != 0 {
+		vm.URL = fmt.Sprintf("/post/%d", r.PostID)
+		if r.Target == "comment" {
+			vm.URL += fmt.Sprintf("#comment-%d", r.TargetID)
+		}
+	}
+	return vm
+}
+
+// ---------------------------------------------------------------------------------
+// ------------HandleReport Function-----------------------------------------------
+// POST /report con target (post|comment), id y reason. Cualquier usuario
+// verificado puede denunciar; la denuncia queda en /mod/queue.
+func (s *Server) handleReport(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	uid, _ := auth.UserIDFrom(ctx)
+	target := r.FormValue("target")
+	id, _ := strconv.ParseInt(r.FormValue("id"), 10, 64)
+	reason := strings.TrimSpace(r.FormValue("reason"))
+
+	// Post al que volver (y ancla del comentario)
+	var pid int64
+	anchor := fmt.Sprintf("#post-%d", id)
+	switch target {
+	case "post":
+		pid = id
+	case "comment":
+		_, p, err := s.commentOwner(ctx, id)
+		if errors.Is(err, errNotFound) {
+			s.notFound(w, r)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		pid, anchor = p, fmt.Sprintf("#comment-%d", id)
+	default:
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	if reason == "" || len([]rune(reason)) > maxReportReason {
+		msg := fmt.Sprintf("Please say what's wrong (up to %d characters)", maxReportReason)
+		http.Redirect(w, r, fmt.Sprintf("/post/%d?err=%s%s", pid, url.QueryEscape(msg), anchor), http.StatusSeeOther)
+		return
+	}
+
+	_, err := s.Store.Reports.Create(ctx, models.Report{
+		Target:     target,
+		TargetID:   id,
+		ReporterID: uid,
+		Reason:     reason,
+		CreatedAt:  time.Now(),
+	})
+	if errors.Is(err, store.ErrNotFound) {
+		s.notFound(w, r)
+		return
+	}
+	if errors.Is(err, store.ErrAlreadyReported) {
+		http.Redirect(w, r, fmt.Sprintf("/post/%d?err=%s%s", pid, url.QueryEscape("You already reported this"), anchor), http.StatusSeeOther)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("report %s id=%d by uid=%d", target, id, uid)
+	http.Redirect(w, r, fmt.Sprintf("/post/%d?reported=1%s", pid, anchor), http.StatusSeeOther)
+}
+
+// ---------------------------------------------------------------------------------
+// ------------HandleModQueue Function---------------------------------------------
+// GET /mod/queue: denuncias abiertas, la más antigua primero, con el
+// contenido denunciado a la vista.
+func (s *Server) handleModQueue(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	reports, err := s.Store.Reports.Open(ctx, modQueueSize)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var data pageData
+	data.Title = "Moderation queue"
+	for _, rep := range reports {
+		data.Reports = append(data.Reports, toReportVM(rep))
+	}
+	switch r.URL.Query().Get("closed") {
+	case store.ReportResolved:
+		data.Flash, data.FlashOK = "Report resolved", true
+	case store.ReportDismissed:
+		data.Flash, data.FlashOK = "Report dismissed", true
+	}
+	s.fillUserMeta(ctx, &data)
+	util.Render(w, "mod_queue.html", data)
+}
+
+// ---------------------------------------------------------------------------------
+// ------------HandleReportClose Function------------------------------------------
+// POST /mod/reports/{id}/resolve o /dismiss. Al resolver, delete=1 borra
+// además el contenido denunciado.
+func (s *Server) handleReportClose(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	uid, _ := auth.UserIDFrom(ctx)
+	id, _ := strconv.ParseInt(r.PathValue("id"), 10, 64)
+
+	var status string
+	switch r.PathValue("action") {
+	case "resolve":
+		status = store.ReportResolved
+	case "dismiss":
+		status = store.ReportDismissed
+	default:
+		s.notFound(w, r)
+		return
+	}
+
+	rep, err := s.Store.Reports.Get(ctx, id)
+	if errors.Is(err, store.ErrNotFound) {
+		s.notFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	deleted := false
+	if status == store.ReportResolved && r.FormValue("delete") == "1" {
+		// Foto del contenido para el registro; si ya no existe no hay nada que borrar
+		var snap map[string]any
+		action := auth.AuditCommentDelete
+		if rep.Target == "post" {
+			action = auth.AuditPostDelete
+			var p models.Post
+			if p, err = s.Store.Posts.Get(ctx, rep.TargetID); err == nil {
+				snap = auditPost(p)
+				err = s.deletePost(ctx, rep.TargetID)
+			}
+		} else {
+			var c models.Comment
+			if c, err = s.Store.Comments.Get(ctx, rep.TargetID); err == nil {
+				snap = auditComment(c)
+				err = s.deleteComment(ctx, rep.TargetID)
+			}
+		}
+		if err != nil && !errors.Is(err, store.ErrNotFound) && !errors.Is(err, errNotFound) {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err == nil {
+			deleted = true
+			log.Printf("delete %s id=%d owner=%d by uid=%d (report %d)", rep.Target, rep.TargetID, rep.AuthorID, uid, id)
+			s.audit(r, action, rep.Target, rep.TargetID, snap, nil)
+		}
+	}
+
+	// ErrNotFound aquí: otro moderador la cerró antes; no es un error para quien mira
+	err = s.Store.Reports.Close(ctx, id, status, uid, time.Now())
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err == nil {
+		log.Printf("report id=%d %s by uid=%d", id, status, uid)
+		s.audit(r, auth.AuditReportClose, "report", id,
+			map[string]any{"status": store.ReportOpen},
+			map[string]any{"status": status, "deleted": deleted})
+	}
+	http.Redirect(w, r, "/mod/queue?closed="+status, http.StatusSeeOther)
+}
+
+// ---------------------------------------------------------------------------------
+// ------------HandlePostLock Function---------------------------------------------
+// POST /post/{id}/lock con locked=1 cierra el hilo a comentarios nuevos;
+// locked=0 lo reabre.
+func (s *Server) handlePostLock(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, _ := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	locked := r.FormValue("locked") == "1"
+
+	p, err := s.Store.Posts.Get(ctx, id)
+	if errors.Is(err, store.ErrNotFound) {
+		s.notFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	err = s.setPostLocked(ctx, id, locked)
+	if errors.Is(err, errNotFound) {
+		s.notFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.audit(r, auth.AuditPostLock, "post", id,
+		map[string]any{"locked": p.LockedAt != nil}, map[string]any{"locked": locked})
+	uid, _ := auth.UserIDFrom(ctx)
+	log.Printf("lock post id=%d locked=%v by uid=%d", id, locked, uid)
+	http.Redirect(w, r, safeNext(r.FormValue("next"), fmt.Sprintf("/post/%d", id)), http.StatusSeeOther)
+}
+
+// ---------------------------------------------------------------------------------
+// ------------HandlePostPin Function----------------------------------------------
+// POST /post/{id}/pin con pinned=1 fija el post arriba de la portada;
+// pinned=0 lo suelta.
+func (s *Server) handlePostPin(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, _ := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	pinned := r.FormValue("pinned") == "1"
+
+	p, err := s.Store.Posts.Get(ctx, id)
+	if errors.Is(err, store.ErrNotFound) {
+		s.notFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	err = s.setPostPinned(ctx, id, pinned)
+	if errors.Is(err, errNotFound) {
+		s.notFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.audit(r, auth.AuditPostPin, "post", id,
+		map[string]any{"pinned": p.PinnedAt != nil}, map[string]any{"pinned": pinned})
+	uid, _ := auth.UserIDFrom(ctx)
+	log.Printf("pin post id=%d pinned=%v by uid=%d", id, pinned, uid)
+	http.Redirect(w, r, safeNext(r.FormValue("next"), fmt.Sprintf("/post/%d", id)), http.StatusSeeOther)
+}
+
+type modUserVM struct {
+	ID          int64
+	Username    string
+	Role        string
+	Joined      string
+	Barred      *sanctionVM // baneo o suspensión vigente
+	Muted       *sanctionVM
+	CanSanction bool         // quien mira tiene un rol mayor (ver auth.Outranks)
+	LoginLock   *loginLockVM // pausa o bloqueo por logins fallidos
+}
+
+type loginLockVM struct {
+	Locked bool // bloqueo; si no, solo una pausa corta
+	Until  string
+}
+
+// ---------------------------------------------------------------------------------
+// ------------HandleModUser Function----------------------------------------------
+// GET /mod/users?u=nombre: estado de la cuenta y formularios para banear,
+// suspender o silenciar.
+func (s *Server) handleModUser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var data pageData
+	data.Title = "Manage user"
+	name := strings.TrimSpace(r.URL.Query().Get("u"))
+
+	if name != "" {
+		u, err := s.Store.Users.ByUsername(ctx, name)
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			data.Flash = fmt.Sprintf("No user named %q", name)
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		default:
+			uid, _ := auth.UserIDFrom(ctx)
+			role, err := auth.UserRole(ctx, s.Store.Users, uid)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			now := time.Now()
+			vm := &modUserVM{
+				ID:          u.ID,
+				Username:    u.Username,
+				Role:        u.Role,
+				Joined:      u.CreatedAt.Format("2006-01-02"),
+				CanSanction: auth.Outranks(role, u.Role),
+			}
+			if sn := auth.Barred(u, now); sn != nil {
+				vm.Barred = toSanctionVM(sn)
+			}
+			if u.MutedUntil != nil && u.MutedUntil.After(now) {
+				vm.Muted = toSanctionVM(&auth.Sanction{Kind: auth.SanctionMute, Reason: u.MuteReason, Until: u.MutedUntil})
+			}
+			th, err := auth.AccountThrottle(ctx, s.Store.LoginAttempts, u.Email, now)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if th != nil {
+				vm.LoginLock = &loginLockVM{Locked: th.Locked, Until: th.Until.Local().Format("2006-01-02 15:04:05")}
+			}
+			data.Target = vm
+		}
+	}
+	if done := r.URL.Query().Get("done"); done != "" {
+		data.Flash, data.FlashOK = "Done: "+done, true
+	}
+	if e := r.URL.Query().Get("err"); e != "" {
+		data.Flash, data.FlashOK = e, false
+	}
+	s.fillUserMeta(ctx, &data)
+	util.Render(w, "mod_user.html", data)
+}
+
+// ---------------------------------------------------------------------------------
+// ------------HandleUserSanction Function-----------------------------------------
+// POST /mod/users/{id}/{ban|suspend|unban|mute|unmute} con reason y, para
+// suspend y mute, days. Solo sobre usuarios de rol menor que el de quien actúa.
+func (s *Server) handleUserSanction(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	uid, _ := auth.UserIDFrom(ctx)
+	id, _ := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	action := r.PathValue("action")
+
+	target, err := s.Store.Users.ByID(ctx, id)
+	if errors.Is(err, store.ErrNotFound) {
+		s.notFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	role, err := auth.UserRole(ctx, s.Store.Users, uid)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !auth.Outranks(role, target.Role) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	back := "/mod/users?u=" + url.QueryEscape(target.Username)
+	fail := func(msg string) {
+		http.Redirect(w, r, back+"&err="+url.QueryEscape(msg), http.StatusSeeOther)
+	}
+	reason := strings.TrimSpace(r.FormValue("reason"))
+	days, _ := strconv.Atoi(r.FormValue("days"))
+	until := time.Now().AddDate(0, 0, days)
+
+	switch action {
+	case "ban", "suspend", "mute":
+		if reason == "" || len([]rune(reason)) > maxSanctionReason {
+			fail(fmt.Sprintf("A reason is required (up to %d characters)", maxSanctionReason))
+			return
+		}
+		if action != "ban" && (days < 1 || days > maxSanctionDays) {
+			fail(fmt.Sprintf("Days must be between 1 and %d", maxSanctionDays))
+			return
+		}
+	}
+
+	switch action {
+	case "ban":
+		err = auth.Ban(ctx, s.Store.Users, id, reason)
+	case "suspend":
+		err = auth.Suspend(ctx, s.Store.Users, id, until, reason)
+	case "unban":
+		err = auth.Unban(ctx, s.Store.Users, id)
+	case "mute":
+		err = auth.Mute(ctx, s.Store.Users, id, until, reason)
+	case "unmute":
+		err = auth.Unmute(ctx, s.Store.Users, id)
+	default:
+		s.notFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("%s user id=%d days=%d by uid=%d reason=%q", action, id, days, uid, reason)
+	audited := sanctionAudit[action]
+	after, err := s.Store.Users.ByID(ctx, id)
+	if err != nil {
+		log.Printf("audit %s id=%d: %v", audited, id, err)
+	} else {
+		s.audit(r, audited, "user", id, auth.UserSanctions(target), auth.UserSanctions(after))
+	}
+	http.Redirect(w, r, back+"&done="+action, http.StatusSeeOther)
+}
+
+// ---------------------------------------------------------------------------------
+// ------------HandleUserUnlock Function-------------------------------------------
+// POST /admin/users/{id}/unlock: olvida los logins fallidos de la cuenta para
+// que pueda volver a entrar ya. Los límites por IP no se tocan.
+func (s *Server) handleUserUnlock(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	uid, _ := auth.UserIDFrom(ctx)
+	id, _ := strconv.ParseInt(r.PathValue("id"), 10, 64)
+
+	target, err := s.Store.Users.ByID(ctx, id)
+	if errors.Is(err, store.ErrNotFound) {
+		s.notFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	th, err := auth.AccountThrottle(ctx, s.Store.LoginAttempts, target.Email, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := auth.UnlockAccount(ctx, s.Store.LoginAttempts, target.Email); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("unlock login user id=%d by uid=%d", id, uid)
+	var before any
+	if th != nil {
+		before = map[string]any{"locked": th.Locked, "until": th.Until.UTC()}
+	}
+	s.audit(r, auth.AuditUserUnlock, "user", id, before, nil)
+	http.Redirect(w, r, "/mod/users?u="+url.QueryEscape(target.Username)+"&done=unlock", http.StatusSeeOther)
+}
@@ -0,0 +1,102 @@
+package httpx
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Paginación por keyset: el cursor es (created_at, id) del último post visto,
+// así que la página N cuesta lo mismo que la primera (sin OFFSET).
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+var errBadCursor = errors.New("invalid cursor")
+
+type pageCursor struct {
+	CreatedAt time.Time
+	ID        int64
+}
+
+// String codifica el cursor de forma opaca para URLs.
+func (c pageCursor) String() string {
+	raw := strconv.FormatInt(c.CreatedAt.UnixNano(), 10) + "." + strconv.FormatInt(c.ID, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func parseCursor(s string) (*pageCursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errBadCursor
+	}
+	ts, id, ok := strings.Cut(string(b), ".")
+	if !ok {
+		return nil, errBadCursor
+	}
+	nanos, err1 := strconv.ParseInt(ts, 10, 64)
+	pid, err2 := strconv.ParseInt(id, 10, 64)
+	if err1 != nil || err2 != nil || pid <= 0 {
+		return nil, errBadCursor
+	}
+	return &pageCursor{CreatedAt: time.Unix(0, nanos).UTC(), ID: pid}, nil
+}
+
+// pageReq: como mucho uno de After (posts más antiguos) o Before (más nuevos).
+type pageReq struct {
+	After  *pageCursor
+	Before *pageCursor
+	Limit  int
+}
+
+// parsePageReq lee ?after=, ?before= y ?limit= de la query.
+func parsePageReq(q url.Values) (pageReq, error) {
+	var p pageReq
+	var err error
+	if p.After, err = parseCursor(q.Get("after")); err != nil {
+		return p, err
+	}
+	if p.Before, err = parseCursor(q.Get("before")); err != nil {
+		return p, err
+	}
+	if p.After != nil && p.Before != nil {
+		return p, errBadCursor
+	}
+	p.Limit = defaultPageSize
+	if l := q.Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 {
+			return p, errors.New("invalid limit")
+		}
+		p.Limit = min(n, maxPageSize)
+	}
+	return p, nil
+}
+
+// postPage es una página de resultados y los cursores para moverse.
+type postPage struct {
+	Posts []postVM
+	Next  string // cursor "after" para la página siguiente (más antigua); "" si no hay
+	Prev  string // cursor "before" para la anterior (más nueva); "" si no hay
+}
+
+// pageURL construye la URL de otra página conservando el resto de la query.
+func pageURL(path string, q url.Values, key, cursor string) string {
+	v := url.Values{}
+	for k, vals := range q {
+		if k == "after" || k == "before" || k == "ok" || k == "err" || k == "deleted" {
+			continue
+		}
+		v[k] = vals
+	}
+	v.Set(key, cursor)
+	return path + "?" + v.Encode()
+}
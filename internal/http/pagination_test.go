@@ -0,0 +1,56 @@
+package httpx
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	c := pageCursor{CreatedAt: time.Date(2024, 5, 1, 10, 30, 0, 123456000, time.UTC), ID: 42}
+	got, err := parseCursor(c.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.CreatedAt.Equal(c.CreatedAt) || got.ID != c.ID {
+		t.Fatalf("got %+v, want %+v", got, c)
+	}
+}
+
+func TestParsePageReq(t *testing.T) {
+	c := pageCursor{CreatedAt: time.Now(), ID: 7}.String()
+
+	cases := []struct {
+		query   string
+		wantErr bool
+		limit   int
+	}{
+		{"", false, defaultPageSize},
+		{"after=" + c, false, defaultPageSize},
+		{"before=" + c + "&limit=5", false, 5},
+		{"limit=1000", false, maxPageSize},
+		{"after=" + c + "&before=" + c, true, 0},
+		{"after=not-a-cursor", true, 0},
+		{"limit=-1", true, 0},
+	}
+	for _, tc := range cases {
+		q, _ := url.ParseQuery(tc.query)
+		pg, err := parsePageReq(q)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%q: err = %v, wantErr %v", tc.query, err, tc.wantErr)
+			continue
+		}
+		if err == nil && pg.Limit != tc.limit {
+			t.Errorf("%q: limit = %d, want %d", tc.query, pg.Limit, tc.limit)
+		}
+	}
+}
+
+func TestPageURLKeepsFilters(t *testing.T) {
+	q, _ := url.ParseQuery("cat=Go&mine=1&after=old&ok=1")
+	got := pageURL("/", q, "after", "NEW")
+	want := "/?after=NEW&cat=Go&mine=1"
+	if got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,407 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"forum/internal/auth"
+	"forum/internal/models"
+	"forum/internal/store"
+)
+
+// Consultas compartidas por las páginas HTML y la API JSON. El SQL vive en
+// internal/store; aquí solo se pasa de modelos a view models.
+
+var (
+	errNotFound   = errors.New("not found")
+	errBadRequest = errors.New("bad request")
+	errLocked     = errors.New("thread locked")
+)
+
+// postFilter son los filtros de la portada (?cat=, ?mine, ?liked).
+type postFilter struct {
+	Category string
+	Mine     bool
+	Liked    bool
+	Pinned   store.PinFilter
+}
+
+// storeErr traduce store.ErrNotFound al error que entienden los handlers.
+func storeErr(err error) error {
+	if errors.Is(err, store.ErrNotFound) {
+		return errNotFound
+	}
+	return err
+}
+
+func toPostVM(p models.Post) postVM {
+	return postVM{
+		ID:        p.ID,
+		AuthorID:  p.UserID,
+		Title:     p.Title,
+		Content:   p.Content,
+		Author:    p.Author,
+		Created:   p.CreatedAt.Format("2006-01-02 15:04"),
+		CreatedAt: p.CreatedAt,
+		Edited:    p.UpdatedAt != nil,
+		Locked:    p.LockedAt != nil,
+		Pinned:    p.PinnedAt != nil,
+		Likes:     p.Likes,
+		Dislikes:  p.Dislikes,
+	}
+}
+
+func toCommentVM(c models.Comment) commentVM {
+	var parent int64
+	if c.ParentID != nil {
+		parent = *c.ParentID
+	}
+	return commentVM{
+		ID:        c.ID,
+		ParentID:  parent,
+		AuthorID:  c.UserID,
+		Author:    c.Author,
+		Content:   c.Content,
+		Created:   c.CreatedAt.Format("2006-01-02 15:04"),
+		CreatedAt: c.CreatedAt,
+		Edited:    c.UpdatedAt != nil,
+		Likes:     c.Likes,
+		Dislikes:  c.Dislikes,
+	}
+}
+
+// ---------------------------------------------------------------------------------
+// ------------loadCategories-------------------------------------------------------
+func (s *Server) loadCategories(ctx context.Context) ([]catVM, error) {
+	cs, err := s.Store.Categories.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cats := make([]catVM, 0, len(cs))
+	for _, c := range cs {
+		cats = append(cats, catVM{ID: c.ID, Name: c.Name})
+	}
+	return cats, nil
+}
+
+// ---------------------------------------------------------------------------------
+// ------------listPosts------------------------------------------------------------
+// listPosts devuelve una página de posts (con categorías y comentarios) que
+// cumplen el filtro, del más nuevo al más antiguo. Son siempre 3 consultas
+// (posts, categorías, comentarios) sea cual sea el tamaño de la página, más
+// 2 con las reacciones propias si hay usuario.
+// uid es el usuario que mira la página (0 = anónimo); mine/liked lo necesitan.
+// commentLimit: ver loadPostsDetails.
+func (s *Server) listPosts(ctx context.Context, uid int64, f postFilter, pg pageReq, commentLimit int) (postPage, error) {
+	if pg.Limit <= 0 {
+		pg.Limit = defaultPageSize
+	}
+	q := store.PostQuery{Category: f.Category, Pinned: f.Pinned, Limit: pg.Limit}
+	if f.Mine && uid != 0 {
+		q.AuthorID = uid
+	}
+	if f.Liked && uid != 0 {
+		q.LikedBy = uid
+	}
+	if c := pg.After; c != nil {
+		q.After = &store.Cursor{CreatedAt: c.CreatedAt, ID: c.ID}
+	}
+	if c := pg.Before; c != nil {
+		q.Before = &store.Cursor{CreatedAt: c.CreatedAt, ID: c.ID}
+	}
+
+	ps, more, err := s.Store.Posts.List(ctx, q)
+	if err != nil {
+		return postPage{}, err
+	}
+	posts := make([]postVM, 0, len(ps))
+	for _, p := range ps {
+		posts = append(posts, toPostVM(p))
+	}
+	if err := s.loadPostsDetails(ctx, uid, posts, commentLimit); err != nil {
+		return postPage{}, err
+	}
+
+	page := postPage{Posts: posts}
+	if len(posts) > 0 {
+		first := pageCursor{CreatedAt: posts[0].CreatedAt, ID: posts[0].ID}
+		last := pageCursor{CreatedAt: posts[len(posts)-1].CreatedAt, ID: posts[len(posts)-1].ID}
+		// Hacia delante hay más si sobró una fila, o si veníamos hacia atrás.
+		if more || pg.Before != nil {
+			page.Next = last.String()
+		}
+		// Hacia atrás hay más si veníamos hacia delante, o si sobró una fila yendo hacia atrás.
+		if pg.After != nil || (pg.Before != nil && more) {
+			page.Prev = first.String()
+		}
+	}
+	return page, nil
+}
+
+// ---------------------------------------------------------------------------------
+// ------------getPost--------------------------------------------------------------
+// getPost carga un post con sus categorías y comentarios (y las reacciones de
+// uid si no es 0); errNotFound si no existe.
+func (s *Server) getPost(ctx context.Context, uid, id int64) (postVM, error) {
+	p, err := s.Store.Posts.Get(ctx, id)
+	if err != nil {
+		return postVM{}, storeErr(err)
+	}
+	one := []postVM{toPostVM(p)}
+	if err := s.loadPostsDetails(ctx, uid, one, allComments); err != nil {
+		return postVM{}, err
+	}
+	return one[0], nil
+}
+
+// Valores de commentLimit para loadPostsDetails.
+const (
+	allComments = -1 // hilo completo (página del post)
+	noComments  = 0  // solo categorías (listado de la API)
+)
+
+// loadPostsDetails rellena categorías y comentarios de todos los posts con
+// una consulta por tipo, sin importar cuántos posts haya. commentLimit > 0
+// carga solo los últimos N comentarios de cada post (CommentCount sigue
+// siendo el total). Con uid != 0 marca también sus reacciones.
+func (s *Server) loadPostsDetails(ctx context.Context, uid int64, posts []postVM, commentLimit int) error {
+	if len(posts) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(posts))
+	byID := make(map[int64]*postVM, len(posts))
+	for i := range posts {
+		ids[i] = posts[i].ID
+		byID[posts[i].ID] = &posts[i]
+	}
+
+	cats, err := s.Store.Categories.ForPosts(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for pid, cs := range cats {
+		if p := byID[pid]; p != nil {
+			for _, c := range cs {
+				p.Cats = append(p.Cats, c.Name)
+			}
+		}
+	}
+
+	if uid != 0 {
+		mine, err := s.Store.Reactions.Mine(ctx, uid, "post", ids)
+		if err != nil {
+			return err
+		}
+		for i := range posts {
+			posts[i].MyReaction = mine[posts[i].ID]
+		}
+	}
+
+	if commentLimit == noComments {
+		return nil
+	}
+	comments, counts, err := s.Store.Comments.ForPosts(ctx, ids, commentLimit)
+	if err != nil {
+		return err
+	}
+	for _, c := range comments {
+		if p := byID[c.PostID]; p != nil {
+			p.Comments = append(p.Comments, toCommentVM(c))
+		}
+	}
+	for pid, n := range counts {
+		if p := byID[pid]; p != nil {
+			p.CommentCount = n
+		}
+	}
+	if uid != 0 && len(comments) > 0 {
+		cids := make([]int64, len(comments))
+		for i, c := range comments {
+			cids[i] = c.ID
+		}
+		mine, err := s.Store.Reactions.Mine(ctx, uid, "comment", cids)
+		if err != nil {
+			return err
+		}
+		for i := range posts {
+			for j := range posts[i].Comments {
+				c := &posts[i].Comments[j]
+				c.MyReaction = mine[c.ID]
+			}
+		}
+	}
+	for i := range posts {
+		posts[i].Thread = buildThread(posts[i].Comments)
+	}
+	return nil
+}
+
+// maxCommentDepth es hasta dónde se anidan las respuestas en pantalla. Las
+// más profundas se muestran al mismo nivel que su padre, con "@autor".
+const maxCommentDepth = 5
+
+// buildThread monta el árbol de respuestas a partir de la lista plana (en
+// orden cronológico, los padres antes que los hijos) y cuenta cuántas
+// respuestas cuelgan de cada comentario.
+func buildThread(flat []commentVM) []*commentVM {
+	var roots []*commentVM
+	byID := make(map[int64]*commentVM, len(flat))
+	host := make(map[*commentVM]*commentVM, len(flat)) // nodo bajo el que se pinta cada uno
+	level := make(map[*commentVM]int, len(flat))
+	for i := range flat {
+		node := &commentVM{}
+		*node = flat[i]
+		byID[node.ID] = node
+
+		parent := byID[node.ParentID]
+		if parent == nil {
+			roots = append(roots, node)
+			continue
+		}
+		h, l := parent, level[parent]+1
+		if level[parent] >= maxCommentDepth {
+			// Demasiado hondo: se pinta junto a su padre, indicando a quién responde.
+			h, l = host[parent], level[parent]
+			node.ReplyTo = parent.Author
+		}
+		host[node], level[node] = h, l
+		h.Replies = append(h.Replies, node)
+		for a := h; a != nil; a = host[a] {
+			a.Total++
+		}
+	}
+	return roots
+}
+
+// ---------------------------------------------------------------------------------
+// ------------createPost-----------------------------------------------------------
+// createPost crea el post y lo vincula a las categorías (creándolas si hace falta).
+func (s *Server) createPost(ctx context.Context, uid int64, title, content string, cats []string) (int64, error) {
+	return s.Store.Posts.Create(ctx, models.Post{
+		UserID:    uid,
+		Title:     title,
+		Content:   content,
+		CreatedAt: time.Now(),
+	}, cats)
+}
+
+// ---------------------------------------------------------------------------------
+// ------------createComment--------------------------------------------------------
+// createComment añade un comentario (o una respuesta si parent != 0);
+// errNotFound si el post no existe o el padre no es de ese post, errLocked
+// si el hilo está cerrado (salvo para moderadores).
+func (s *Server) createComment(ctx context.Context, uid, pid, parent int64, content string) (int64, error) {
+	p, err := s.Store.Posts.Get(ctx, pid)
+	if err != nil {
+		return 0, storeErr(err)
+	}
+	if p.LockedAt != nil {
+		role, err := auth.UserRole(ctx, s.Store.Users, uid)
+		if err != nil {
+			return 0, err
+		}
+		if !auth.CanModerate(role) {
+			return 0, errLocked
+		}
+	}
+	var parentID *int64
+	if parent != 0 {
+		parentID = &parent
+	}
+	cid, err := s.Store.Comments.Create(ctx, models.Comment{
+		PostID:    pid,
+		ParentID:  parentID,
+		UserID:    uid,
+		Content:   content,
+		CreatedAt: time.Now(),
+	})
+	return cid, storeErr(err)
+}
+
+// ---------------------------------------------------------------------------------
+// ------------react----------------------------------------------------------------
+// react guarda (o cambia) la reacción del usuario sobre un post o comentario.
+func (s *Server) react(ctx context.Context, uid int64, target string, id int64, val int) error {
+	if !validReaction(target, id, val) {
+		return errBadRequest
+	}
+	return storeErr(s.Store.Reactions.Set(ctx, uid, target, id, val))
+}
+
+// toggleReact es react, pero repetir la misma reacción la quita. Devuelve
+// la reacción que queda (0 = ninguna).
+func (s *Server) toggleReact(ctx context.Context, uid int64, target string, id int64, val int) (int, error) {
+	if !validReaction(target, id, val) {
+		return 0, errBadRequest
+	}
+	v, err := s.Store.Reactions.Toggle(ctx, uid, target, id, val)
+	return v, storeErr(err)
+}
+
+// unreact quita la reacción del usuario, si la había.
+func (s *Server) unreact(ctx context.Context, uid int64, target string, id int64) error {
+	if !validReaction(target, id, 1) {
+		return errBadRequest
+	}
+	return s.Store.Reactions.Clear(ctx, uid, target, id)
+}
+
+func validReaction(target string, id int64, val int) bool {
+	return (target == "post" || target == "comment") && (val == 1 || val == -1) && id > 0
+}
+
+// ---------------------------------------------------------------------------------
+// ------------edit / delete--------------------------------------------------------
+
+// postOwner devuelve el autor del post; errNotFound si no existe.
+func (s *Server) postOwner(ctx context.Context, id int64) (int64, error) {
+	p, err := s.Store.Posts.Get(ctx, id)
+	if err != nil {
+		return 0, storeErr(err)
+	}
+	return p.UserID, nil
+}
+
+// commentOwner devuelve el autor del comentario y el post al que pertenece.
+func (s *Server) commentOwner(ctx context.Context, id int64) (owner, postID int64, err error) {
+	c, err := s.Store.Comments.Get(ctx, id)
+	if err != nil {
+		return 0, 0, storeErr(err)
+	}
+	return c.UserID, c.PostID, nil
+}
+
+func (s *Server) updatePost(ctx context.Context, id int64, title, content string) error {
+	return storeErr(s.Store.Posts.Update(ctx, id, title, content, time.Now()))
+}
+
+func (s *Server) updateComment(ctx context.Context, id int64, content string) error {
+	return storeErr(s.Store.Comments.Update(ctx, id, content, time.Now()))
+}
+
+func (s *Server) setPostLocked(ctx context.Context, id int64, locked bool) error {
+	var at *time.Time
+	if locked {
+		now := time.Now()
+		at = &now
+	}
+	return storeErr(s.Store.Posts.SetLocked(ctx, id, at))
+}
+
+func (s *Server) setPostPinned(ctx context.Context, id int64, pinned bool) error {
+	var at *time.Time
+	if pinned {
+		now := time.Now()
+		at = &now
+	}
+	return storeErr(s.Store.Posts.SetPinned(ctx, id, at))
+}
+
+func (s *Server) deletePost(ctx context.Context, id int64) error {
+	return s.Store.Posts.Delete(ctx, id)
+}
+
+func (s *Server) deleteComment(ctx context.Context, id int64) error {
+	return s.Store.Comments.Delete(ctx, id)
+}
@@ -0,0 +1,181 @@
+package httpx
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"forum/internal/app"
+	"forum/internal/db"
+	"forum/internal/models"
+	"forum/internal/store"
+	"forum/internal/store/sqlstore"
+
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// Los tests con base de datos necesitan un Postgres de usar y tirar:
+//   FORUM_TEST_DATABASE_URL=postgres://... go test ./internal/http
+// Sin la variable se saltan.
+
+/* =========================
+   Driver que cuenta queries
+   ========================= */
+
+var queryCount atomic.Int64
+
+type countingDriver struct{ driver.Driver }
+
+func (d countingDriver) Open(name string) (driver.Conn, error) {
+	c, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return countingConn{c.(*stdlib.Conn)}, nil
+}
+
+type countingConn struct{ *stdlib.Conn }
+
+func (c countingConn) QueryContext(ctx context.Context, q string, args []driver.NamedValue) (driver.Rows, error) {
+	queryCount.Add(1)
+	return c.Conn.QueryContext(ctx, q, args)
+}
+
+func (c countingConn) ExecContext(ctx context.Context, q string, args []driver.NamedValue) (driver.Result, error) {
+	queryCount.Add(1)
+	return c.Conn.ExecContext(ctx, q, args)
+}
+
+var registerOnce sync.Once
+
+func openCountingDB(t testing.TB) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv("FORUM_TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("FORUM_TEST_DATABASE_URL not set")
+	}
+	registerOnce.Do(func() { sql.Register("pgx-counting", countingDriver{stdlib.GetDefaultDriver()}) })
+
+	d, err := sql.Open("pgx-counting", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Close() })
+	if _, err := db.MigrateUp(context.Background(), d); err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+// seedPosts crea n posts en una categoría propia del test, cada uno con
+// 5 comentarios y 2 reacciones, y devuelve el nombre de la categoría.
+func seedPosts(t testing.TB, s *Server, n int) string {
+	t.Helper()
+	ctx := context.Background()
+	tag := fmt.Sprintf("nplus1-%s-%d", t.Name(), os.Getpid())
+
+	email := tag + "@test.local"
+	uid, err := s.Store.Users.Create(ctx, models.User{Email: email, Username: email, PasswordHash: "x", CreatedAt: time.Now()})
+	if errors.Is(err, store.ErrEmailTaken) {
+		var u models.User
+		u, err = s.Store.Users.ByEmail(ctx, email)
+		uid = u.ID
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < n; i++ {
+		pid, err := s.createPost(ctx, uid, fmt.Sprintf("post %d", i), "body", []string{tag, "General"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for j := 0; j < 5; j++ {
+			if _, err := s.createComment(ctx, uid, pid, 0, fmt.Sprintf("comment %d", j)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := s.react(ctx, uid, "post", pid, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return tag
+}
+
+func TestBuildThread(t *testing.T) {
+	// Cadena de 8 respuestas: 1 ← 2 ← 3 ← ... ← 8, más un segundo hilo (9).
+	var flat []commentVM
+	for id := int64(1); id <= 8; id++ {
+		flat = append(flat, commentVM{ID: id, ParentID: id - 1, Author: fmt.Sprint("u", id)})
+	}
+	flat = append(flat, commentVM{ID: 9})
+
+	roots := buildThread(flat)
+	if len(roots) != 2 || roots[0].ID != 1 || roots[1].ID != 9 {
+		t.Fatalf("roots = %+v", roots)
+	}
+	if roots[0].Total != 7 {
+		t.Fatalf("root total = %d, want 7", roots[0].Total)
+	}
+	// Se anida hasta maxCommentDepth; las más hondas van junto a su padre.
+	host := roots[0]
+	for depth := 0; depth < maxCommentDepth-1; depth++ {
+		if len(host.Replies) != 1 {
+			t.Fatalf("depth %d: %d replies", depth, len(host.Replies))
+		}
+		host = host.Replies[0]
+	}
+	r := host.Replies
+	if len(r) != 3 || r[0].ID != 6 || r[0].ReplyTo != "" || r[1].ReplyTo != "u6" || r[2].ReplyTo != "u7" || len(r[0].Replies) != 0 {
+		t.Fatalf("replies at max depth = %+v", r)
+	}
+}
+
+func TestListPostsConstantQueries(t *testing.T) {
+	s := &Server{Store: sqlstore.New(openCountingDB(t)), Cfg: app.Config{}}
+	ctx := context.Background()
+
+	for _, n := range []int{1, 10, 50} {
+		t.Run(fmt.Sprint(n), func(t *testing.T) {
+			tag := seedPosts(t, s, n)
+
+			queryCount.Store(0)
+			page, err := s.listPosts(ctx, 0, postFilter{Category: tag}, pageReq{Limit: maxPageSize}, commentsPreview)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(page.Posts) != n {
+				t.Fatalf("posts = %d, want %d", len(page.Posts), n)
+			}
+			// posts + categorías + comentarios
+			if got := queryCount.Load(); got != 3 {
+				t.Fatalf("queries = %d for %d posts, want 3", got, n)
+			}
+			p := page.Posts[0]
+			if len(p.Comments) != commentsPreview || p.CommentCount != 5 || len(p.Cats) != 2 || p.Likes != 1 {
+				t.Fatalf("unexpected post details: %+v", p)
+			}
+		})
+	}
+}
+
+func BenchmarkListPosts(b *testing.B) {
+	s := &Server{Store: sqlstore.New(openCountingDB(b)), Cfg: app.Config{}}
+	ctx := context.Background()
+	tag := seedPosts(b, s, 100)
+
+	b.ResetTimer()
+	queryCount.Store(0)
+	for i := 0; i < b.N; i++ {
+		if _, err := s.listPosts(ctx, 0, postFilter{Category: tag}, pageReq{Limit: 100}, commentsPreview); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(queryCount.Load())/float64(b.N), "queries/op")
+}
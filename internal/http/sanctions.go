@@ -0,0 +1,37 @@
+package httpx
+
+import (
+	"net/http"
+
+	"forum/internal/auth"
+	"forum/internal/util"
+)
+
+type sanctionVM struct {
+	Kind   string // ver auth.Sanction*
+	Reason string
+	Until  string // vacío = permanente
+}
+
+func toSanctionVM(sn *auth.Sanction) *sanctionVM {
+	vm := &sanctionVM{Kind: sn.Kind, Reason: sn.Reason}
+	if sn.Until != nil {
+		vm.Until = sn.Until.Local().Format("2006-01-02 15:04")
+	}
+	return vm
+}
+
+// renderSanction explica con un 403 por qué la cuenta no puede entrar o
+// escribir, en vez de un 401 sin más.
+func (s *Server) renderSanction(w http.ResponseWriter, r *http.Request, sn *auth.Sanction) {
+	var data pageData
+	data.Title = "Account " + sn.Kind
+	data.Sanction = toSanctionVM(sn)
+	s.fillUserMeta(r.Context(), &data)
+	util.RenderStatus(w, http.StatusForbidden, "sanction.html", data)
+}
+
+// endSession borra la cookie de sesión del navegador.
+func endSession(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{Name: CookieName, Value: "", Path: "/", MaxAge: -1, HttpOnly: true})
+}
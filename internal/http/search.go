@@ -0,0 +1,164 @@
+package httpx
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"forum/internal/store"
+	"forum/internal/util"
+)
+
+// searchPageSize es cuántos resultados se ven por página en /search.
+const searchPageSize = 20
+
+type searchHitVM struct {
+	Kind    string // "post" | "comment"
+	URL     string
+	Title   string
+	Author  string
+	Snippet template.HTML // escapado, con <mark> en las coincidencias
+	Created string
+}
+
+// ---------------------------------------------------------------------------------
+// ------------HandleSearch Function-----------------------------------------------
+// GET /search?q=...&page=N. Además de palabras, "frases" y -exclusiones, q
+// admite author:nombre, cat:categoría, from:AAAA-MM-DD y to:AAAA-MM-DD.
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	var data pageData
+	data.Title = "Search"
+	data.SearchQ = strings.TrimSpace(r.URL.Query().Get("q"))
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+
+	if data.SearchQ != "" {
+		q, err := parseSearch(data.SearchQ, time.Local)
+		if err != nil {
+			data.Flash = err.Error()
+		} else {
+			q.Limit, q.Offset = searchPageSize, (page-1)*searchPageSize
+			hits, more, err := s.Store.Search.Search(ctx, q)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			data.Searched = true
+			for _, h := range hits {
+				data.Hits = append(data.Hits, toSearchHitVM(h))
+			}
+			if page > 1 {
+				data.PrevURL = searchURL(data.SearchQ, page-1)
+			}
+			if more {
+				data.NextURL = searchURL(data.SearchQ, page+1)
+			}
+		}
+	}
+
+	s.fillUserMeta(r.Context(), &data)
+	util.Render(w, "search.html", data)
+}
+
+func searchURL(q string, page int) string {
+	v := url.Values{"q": {q}}
+	if page > 1 {
+		v.Set("page", strconv.Itoa(page))
+	}
+	return "/search?" + v.Encode()
+}
+
+func toSearchHitVM(h store.SearchHit) searchHitVM {
+	vm := searchHitVM{
+		Kind:    h.Kind,
+		URL:     fmt.Sprintf("/post/%d", h.PostID),
+		Title:   h.Title,
+		Author:  h.Author,
+		Snippet: highlight(h.Snippet),
+		Created: h.CreatedAt.Format("2006-01-02 15:04"),
+	}
+	if h.Kind == "comment" {
+		vm.URL += fmt.Sprintf("#comment-%d", h.CommentID)
+	}
+	return vm
+}
+
+// highlight escapa el extracto y cambia las marcas de store por <mark>.
+func highlight(snippet string) template.HTML {
+	out := html.EscapeString(snippet)
+	out = strings.ReplaceAll(out, store.HighlightStart, "<mark>")
+	out = strings.ReplaceAll(out, store.HighlightEnd, "</mark>")
+	return template.HTML(out)
+}
+
+// parseSearch separa los operadores del texto libre. Las fechas se leen en
+// loc; to: incluye el día entero.
+func parseSearch(raw string, loc *time.Location) (store.SearchQuery, error) {
+	var q store.SearchQuery
+	var text []string
+	for _, tok := range searchTokens(raw) {
+		key, val, ok := strings.Cut(tok, ":")
+		if !ok || val == "" || strings.HasPrefix(tok, `"`) || strings.HasPrefix(tok, "-") {
+			text = append(text, tok)
+			continue
+		}
+		val = strings.Trim(val, `"`)
+		switch strings.ToLower(key) {
+		case "author", "user":
+			q.Author = val
+		case "cat", "category":
+			q.Category = val
+		case "from", "to":
+			day, err := time.ParseInLocation("2006-01-02", val, loc)
+			if err != nil {
+				return q, fmt.Errorf("%s: expects a date like 2024-01-31", key)
+			}
+			if key == "from" {
+				q.From = day
+			} else {
+				q.To = day.AddDate(0, 0, 1)
+			}
+		default:
+			text = append(text, tok)
+		}
+	}
+	q.Text = strings.Join(text, " ")
+	return q, nil
+}
+
+// searchTokens parte por espacios sin romper lo que va entre comillas
+// ("una frase", cat:"Web Dev").
+func searchTokens(raw string) []string {
+	var out []string
+	var cur strings.Builder
+	quoted := false
+	for _, r := range raw {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			cur.WriteRune(r)
+		case !quoted && (r == ' ' || r == '\t'):
+			if cur.Len() > 0 {
+				out = append(out, cur.String())
+				cur.Reset()
+			}
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	if cur.Len() > 0 {
+		out = append(out, cur.String())
+	}
+	return out
+}
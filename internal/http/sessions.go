@@ -0,0 +1,168 @@
+package httpx
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"forum/internal/auth"
+	"forum/internal/util"
+)
+
+type sessionVM struct {
+	Handle   string // ver auth.SessionHandle
+	Device   string // resumen legible del User-Agent
+	UA       string
+	IP       string
+	LastSeen string
+	Created  string
+	Current  bool // la sesión de esta request
+}
+
+// sessionPolicy es la renovación y el tope de las sesiones según la config.
+func (s *Server) sessionPolicy() auth.SessionPolicy {
+	return auth.SessionPolicy{
+		Lifetime:    s.Cfg.SessionLifetime,
+		RenewAfter:  s.Cfg.SessionRenew,
+		MaxLifetime: s.Cfg.SessionMaxAge,
+	}
+}
+
+// rotateSession da un token nuevo a la sesión de esta request y reenvía la
+// cookie; para cambios de privilegios hechos por el propio usuario.
+func (s *Server) rotateSession(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	ses, err := s.Store.Sessions.Get(ctx, sessionID(ctx))
+	if err != nil {
+		return err
+	}
+	ses, token, err := auth.RotateSession(ctx, s.Store.Sessions, ses, ses.Role, time.Now())
+	if err != nil {
+		return err
+	}
+	s.setSessionCookie(w, token, ses.Persistent, ses.ExpiresAt)
+	return nil
+}
+
+// deviceName resume un User-Agent como "Firefox on Windows". No pretende ser
+// exacto: solo ayuda a reconocer cada sesión en la lista.
+func deviceName(ua string) string {
+	if ua == "" {
+		return "Unknown device"
+	}
+	browser := "Unknown browser"
+	for _, b := range []struct{ token, name string }{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+		{"curl/", "curl"},
+		{"Go-http-client/", "Go client"},
+	} {
+		if strings.Contains(ua, b.token) {
+			browser = b.name
+			break
+		}
+	}
+	system := ""
+	for _, o := range []struct{ token, name string }{
+		{"Android", "Android"},
+		{"iPhone", "iOS"},
+		{"iPad", "iPadOS"},
+		{"Windows", "Windows"},
+		{"Mac OS X", "macOS"},
+		{"CrOS", "ChromeOS"},
+		{"Linux", "Linux"},
+	} {
+		if strings.Contains(ua, o.token) {
+			system = o.name
+			break
+		}
+	}
+	if system == "" {
+		return browser
+	}
+	return browser + " on " + system
+}
+
+// ---------------------------------------------------------------------------------
+// ------------HandleSettingsSessions Function-------------------------------------
+// GET /settings/sessions: sesiones abiertas ("tus dispositivos").
+func (s *Server) handleSettingsSessions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	uid, _ := auth.UserIDFrom(ctx)
+	current := sessionID(ctx)
+
+	var data pageData
+	data.Title = "Your devices"
+	if d := r.URL.Query().Get("done"); d != "" {
+		data.Flash, data.FlashOK = d, true
+	} else if e := r.URL.Query().Get("err"); e != "" {
+		data.Flash = e
+	}
+
+	list, err := auth.ListSessions(ctx, s.Store.Sessions, uid)
+	if err != nil {
+		http.Error(w, "sessions: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for _, ses := range list {
+		data.Sessions = append(data.Sessions, sessionVM{
+			Handle:   auth.SessionHandle(ses.ID),
+			Device:   deviceName(ses.UserAgent),
+			UA:       ses.UserAgent,
+			IP:       ses.IP,
+			LastSeen: ses.LastSeenAt.Format("2006-01-02 15:04"),
+			Created:  ses.CreatedAt.Format("2006-01-02 15:04"),
+			Current:  ses.ID == current,
+		})
+	}
+
+	s.fillUserMeta(ctx, &data)
+	util.Render(w, "settings_sessions.html", data)
+}
+
+// ---------------------------------------------------------------------------------
+// ------------HandleSettingsSessionRevoke Function--------------------------------
+// POST /settings/sessions/revoke (handle): cierra otra sesión del usuario.
+func (s *Server) handleSettingsSessionRevoke(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	uid, _ := auth.UserIDFrom(ctx)
+	handle := r.FormValue("handle")
+
+	// La propia sesión se cierra con "Log out", que además borra la cookie
+	if handle == auth.SessionHandle(sessionID(ctx)) {
+		http.Redirect(w, r, "/settings/sessions?err="+url.QueryEscape("Use Log out to end this session"), http.StatusSeeOther)
+		return
+	}
+	err := auth.RevokeSession(ctx, s.Store.Sessions, uid, handle)
+	if errors.Is(err, auth.ErrNoSession) {
+		http.Redirect(w, r, "/settings/sessions?err="+url.QueryEscape("Session not found"), http.StatusSeeOther)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("session revoked uid=%d handle=%s", uid, handle)
+	http.Redirect(w, r, "/settings/sessions?done="+url.QueryEscape("Session signed out"), http.StatusSeeOther)
+}
+
+// ---------------------------------------------------------------------------------
+// ------------HandleSettingsSessionsOthers Function-------------------------------
+// POST /settings/sessions/others: cierra todas las sesiones menos esta.
+func (s *Server) handleSettingsSessionsOthers(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	uid, _ := auth.UserIDFrom(ctx)
+
+	if err := auth.SignOutOthers(ctx, s.Store.Sessions, uid, sessionID(ctx)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("sessions: signed out everywhere else uid=%d", uid)
+	http.Redirect(w, r, "/settings/sessions?done="+url.QueryEscape("Signed out everywhere else"), http.StatusSeeOther)
+}
@@ -0,0 +1,85 @@
+package httpx
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"forum/internal/auth"
+	"forum/internal/util"
+)
+
+type apiTokenVM struct {
+	ID       int64
+	Name     string
+	Scopes   []string
+	LastUsed string
+	Created  string
+}
+
+// ---------------------------------------------------------------------------------
+// ------------HandleSettingsTokens Function---------------------------------------
+// GET lista los tokens personales; POST crea uno y lo muestra una sola vez.
+func (s *Server) handleSettingsTokens(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	uid, _ := auth.UserIDFrom(ctx)
+
+	var data pageData
+	data.Title = "API tokens"
+	data.AllScopes = auth.AllScopes
+
+	if r.Method == http.MethodPost {
+		_ = r.ParseForm()
+		raw, err := auth.CreateAPIToken(ctx, s.Store.APITokens, uid, r.FormValue("name"), r.Form["scopes"])
+		if err != nil {
+			data.Flash = err.Error()
+		} else {
+			data.NewToken = raw
+			data.Flash = "Token created. Copy it now: it won't be shown again."
+			data.FlashOK = true
+			log.Printf("api token created uid=%d name=%q", uid, r.FormValue("name"))
+		}
+	} else if r.URL.Query().Get("revoked") == "1" {
+		data.Flash = "Token revoked"
+		data.FlashOK = true
+	} else if e := r.URL.Query().Get("err"); e != "" {
+		data.Flash = e
+	}
+
+	tokens, err := auth.ListAPITokens(ctx, s.Store.APITokens, uid)
+	if err != nil {
+		http.Error(w, "api tokens: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for _, t := range tokens {
+		vm := apiTokenVM{ID: t.ID, Name: t.Name, Scopes: t.Scopes, LastUsed: "never", Created: t.CreatedAt.Format("2006-01-02 15:04")}
+		if t.LastUsedAt != nil {
+			vm.LastUsed = t.LastUsedAt.Format("2006-01-02 15:04")
+		}
+		data.Tokens = append(data.Tokens, vm)
+	}
+
+	s.fillUserMeta(ctx, &data)
+	util.Render(w, "settings_tokens.html", data)
+}
+
+// ---------------------------------------------------------------------------------
+// ------------HandleSettingsTokenRevoke Function----------------------------------
+func (s *Server) handleSettingsTokenRevoke(w http.ResponseWriter, r *http.Request) {
+	uid, _ := auth.UserIDFrom(r.Context())
+	id, _ := strconv.ParseInt(r.FormValue("id"), 10, 64)
+
+	err := auth.RevokeAPIToken(r.Context(), s.Store.APITokens, uid, id)
+	if errors.Is(err, auth.ErrNoAPIToken) {
+		http.Redirect(w, r, "/settings/tokens?err="+url.QueryEscape("Token not found"), http.StatusSeeOther)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("api token revoked uid=%d id=%d", uid, id)
+	http.Redirect(w, r, "/settings/tokens?revoked=1", http.StatusSeeOther)
+}
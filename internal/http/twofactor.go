@@ -0,0 +1,174 @@
+package httpx
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"forum/internal/auth"
+	"forum/internal/util"
+)
+
+type twoFactorVM struct {
+	Enabled   bool
+	Since     string
+	CodesLeft int
+	Pending   *auth.Enrollment // alta empezada: secreto y URI para la app
+}
+
+// renderSecondFactor es el segundo paso del login: pide el código. remember
+// viaja con el reto hasta que se crea la sesión.
+func (s *Server) renderSecondFactor(w http.ResponseWriter, r *http.Request, challenge string, remember bool, msg string, status int) {
+	util.RenderStatus(w, status, "auth_2fa.html", map[string]any{
+		"Title":     "Two-factor authentication",
+		"CSRFToken": csrfToken(r.Context()),
+		"Challenge": challenge,
+		"Remember":  remember,
+		"Error":     msg,
+	})
+}
+
+// ---------------------------------------------------------------------------------
+// ------------HandleLoginSecondFactor Function------------------------------------
+// POST /login/2fa con challenge (de handleLogin) y code: TOTP o código de
+// recuperación. Solo aquí se crea la sesión de una cuenta con 2FA.
+func (s *Server) handleLoginSecondFactor(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	challenge := r.FormValue("challenge")
+	remember := r.FormValue("remember") != ""
+	sid, ses, err := auth.LoginSecondFactor(r.Context(), s.Store.Users, s.Store.Sessions, s.Store.Tokens, s.Store.TwoFactor,
+		challenge, r.FormValue("code"), auth.LoginOptions{
+			Lifetime:    s.Cfg.SessionLifetime,
+			MaxLifetime: s.Cfg.SessionMaxAge,
+			Remember:    remember,
+			Attempts:    s.Store.LoginAttempts,
+			IP:          clientIP(r),
+			UserAgent:   r.UserAgent(),
+		})
+	var th *auth.Throttled
+	var sn *auth.Sanction
+	switch {
+	case errors.As(err, &th):
+		s.renderSecondFactor(w, r, challenge, remember, th.Message(time.Now()), http.StatusTooManyRequests)
+		return
+	case errors.Is(err, auth.ErrInvalidCode):
+		s.renderSecondFactor(w, r, challenge, remember, "That code didn't work. Try the current one from your app, or a recovery code.", http.StatusUnauthorized)
+		return
+	case errors.Is(err, auth.ErrInvalidToken), errors.Is(err, auth.ErrTwoFactorOff):
+		http.Redirect(w, r, "/login?err="+url.QueryEscape("Your sign-in expired, please start again"), http.StatusSeeOther)
+		return
+	case errors.As(err, &sn):
+		s.renderSanction(w, r, sn)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("login OK (2fa) uid=%d", ses.UserID)
+	s.setSessionCookie(w, sid, ses.Persistent, ses.ExpiresAt)
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// ---------------------------------------------------------------------------------
+// ------------HandleSettings2FA Function------------------------------------------
+// GET /settings/2fa: estado del segundo factor y formularios para activarlo,
+// desactivarlo o regenerar los códigos.
+func (s *Server) handleSettings2FA(w http.ResponseWriter, r *http.Request) {
+	var flash string
+	ok := false
+	if d := r.URL.Query().Get("done"); d != "" {
+		flash, ok = d, true
+	} else if e := r.URL.Query().Get("err"); e != "" {
+		flash = e
+	}
+	s.render2FA(w, r, flash, ok, nil)
+}
+
+func (s *Server) render2FA(w http.ResponseWriter, r *http.Request, flash string, ok bool, codes []string) {
+	ctx := r.Context()
+	uid, _ := auth.UserIDFrom(ctx)
+
+	tf, enabled, err := auth.TwoFactorEnabled(ctx, s.Store.TwoFactor, uid)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	vm := &twoFactorVM{Enabled: enabled}
+	if enabled {
+		vm.Since = tf.EnabledAt.Format("2006-01-02")
+		vm.CodesLeft = tf.CodesLeft
+	} else if vm.Pending, err = auth.PendingTOTP(ctx, s.Store.Users, s.Store.TwoFactor, uid); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var data pageData
+	data.Title = "Two-factor authentication"
+	data.TwoFactor = vm
+	data.RecoveryCodes = codes
+	data.Flash, data.FlashOK = flash, ok
+	s.fillUserMeta(ctx, &data)
+	util.Render(w, "settings_2fa.html", data)
+}
+
+// ---------------------------------------------------------------------------------
+// ------------HandleSettings2FAAction Function------------------------------------
+// POST /settings/2fa/{setup|enable|codes|disable}. codes y disable piden la
+// contraseña actual; enable y codes enseñan los códigos de recuperación una vez.
+func (s *Server) handleSettings2FAAction(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	uid, _ := auth.UserIDFrom(ctx)
+	fail := func(msg string) {
+		http.Redirect(w, r, "/settings/2fa?err="+url.QueryEscape(msg), http.StatusSeeOther)
+	}
+
+	var codes []string
+	var err error
+	switch action := r.PathValue("action"); action {
+	case "setup":
+		_, err = auth.BeginTOTP(ctx, s.Store.Users, s.Store.TwoFactor, uid)
+	case "enable":
+		codes, err = auth.EnableTOTP(ctx, s.Store.TwoFactor, uid, r.FormValue("code"))
+	case "codes":
+		codes, err = auth.RegenerateRecoveryCodes(ctx, s.Store.Users, s.Store.TwoFactor, uid, r.FormValue("password"))
+	case "disable":
+		err = auth.DisableTOTP(ctx, s.Store.Users, s.Store.TwoFactor, uid, r.FormValue("password"))
+	default:
+		s.notFound(w, r)
+		return
+	}
+	switch {
+	case errors.Is(err, auth.ErrInvalidCode):
+		fail("That code didn't work. Check the time on your phone and try the current one.")
+		return
+	case errors.Is(err, auth.ErrWrongPassword), errors.Is(err, auth.ErrTwoFactorEnabled), errors.Is(err, auth.ErrTwoFactorOff):
+		fail(err.Error())
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("2fa %s uid=%d", r.PathValue("action"), uid)
+	// Activar o quitar el segundo factor cambia lo que vale esta sesión
+	if a := r.PathValue("action"); a == "enable" || a == "disable" {
+		if err := s.rotateSession(w, r); err != nil {
+			log.Printf("2fa %s: rotate session uid=%d err=%v", a, uid, err)
+		}
+	}
+
+	switch r.PathValue("action") {
+	case "enable":
+		s.render2FA(w, r, "Two-factor authentication is on. Save your recovery codes now: they won't be shown again.", true, codes)
+	case "codes":
+		s.render2FA(w, r, "New recovery codes. The old ones no longer work.", true, codes)
+	case "disable":
+		http.Redirect(w, r, "/settings/2fa?done="+url.QueryEscape("Two-factor authentication is off"), http.StatusSeeOther)
+	default:
+		http.Redirect(w, r, "/settings/2fa", http.StatusSeeOther)
+	}
+}
@@ -0,0 +1,101 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// Message es un correo de texto plano.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer envía correos. Las implementaciones deben ser seguras para uso concurrente.
+type Mailer interface {
+	Send(ctx context.Context, m Message) error
+}
+
+// FromConfig devuelve un FileMailer si hay directorio configurado y un LogMailer si no.
+func FromConfig(dir string) Mailer {
+	if dir != "" {
+		return FileMailer{Dir: dir}
+	}
+	return LogMailer{}
+}
+
+/* =========================
+   LogMailer (desarrollo)
+   ========================= */
+
+// LogMailer escribe los correos en el log en lugar de enviarlos.
+type LogMailer struct{}
+
+func (LogMailer) Send(_ context.Context, m Message) error {
+	log.Printf("mail to=%s subject=%q\n%s", m.To, m.Subject, m.Body)
+	return nil
+}
+
+/* =========================
+   FileMailer (desarrollo / tests)
+   ========================= */
+
+// FileMailer guarda cada correo como un fichero .eml dentro de Dir.
+type FileMailer struct {
+	Dir string
+}
+
+var unsafeName = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+func (f FileMailer) Send(_ context.Context, m Message) error {
+	if err := os.MkdirAll(f.Dir, 0o755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%d_%s.eml", time.Now().UnixNano(), unsafeName.ReplaceAllString(m.To, "_"))
+	body := fmt.Sprintf("To: %s\r\nSubject: %s\r\nDate: %s\r\n\r\n%s\r\n",
+		m.To, m.Subject, time.Now().Format(time.RFC1123Z), m.Body)
+	return os.WriteFile(filepath.Join(f.Dir, name), []byte(body), 0o644)
+}
+
+/* =========================
+   Capture (tests)
+   ========================= */
+
+// Capture guarda los correos en memoria; pensado para tests.
+type Capture struct {
+	mu   sync.Mutex
+	msgs []Message
+}
+
+func (c *Capture) Send(_ context.Context, m Message) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.msgs = append(c.msgs, m)
+	return nil
+}
+
+// Messages devuelve una copia de los correos enviados hasta ahora.
+func (c *Capture) Messages() []Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Message(nil), c.msgs...)
+}
+
+// Last devuelve el último correo enviado a esa dirección.
+func (c *Capture) Last(to string) (Message, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := len(c.msgs) - 1; i >= 0; i-- {
+		if c.msgs[i].To == to {
+			return c.msgs[i], true
+		}
+	}
+	return Message{}, false
+}
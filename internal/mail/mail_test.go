@@ -0,0 +1,43 @@
+package mail
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCaptureLast(t *testing.T) {
+	var c Capture
+	ctx := context.Background()
+	_ = c.Send(ctx, Message{To: "a@x.io", Subject: "one"})
+	_ = c.Send(ctx, Message{To: "b@x.io", Subject: "two"})
+	_ = c.Send(ctx, Message{To: "a@x.io", Subject: "three"})
+
+	if n := len(c.Messages()); n != 3 {
+		t.Fatalf("messages = %d, want 3", n)
+	}
+	m, ok := c.Last("a@x.io")
+	if !ok || m.Subject != "three" {
+		t.Fatalf("Last(a) = %+v, %v", m, ok)
+	}
+	if _, ok := c.Last("nobody@x.io"); ok {
+		t.Fatal("Last(nobody) should be empty")
+	}
+}
+
+func TestFileMailerWritesEML(t *testing.T) {
+	dir := t.TempDir()
+	f := FileMailer{Dir: dir}
+	if err := f.Send(context.Background(), Message{To: "a@x.io", Subject: "hi", Body: "link"}); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("entries = %v, err = %v", entries, err)
+	}
+	b, _ := os.ReadFile(dir + "/" + entries[0].Name())
+	if !strings.Contains(string(b), "Subject: hi") || !strings.Contains(string(b), "link") {
+		t.Fatalf("unexpected file:\n%s", b)
+	}
+}
@@ -3,20 +3,61 @@ package models
 import "time"
 
 type User struct {
-	ID           int64
-	Email        string
-	Username     string
-	PasswordHash string
-	CreatedAt    time.Time
+	ID              int64
+	Email           string
+	Username        string
+	PasswordHash    string
+	Role            string     // member | moderator | admin
+	EmailVerifiedAt *time.Time // nil = sin verificar
+	CreatedAt       time.Time
+
+	// Sanciones (ver auth.Barred / auth.Silenced)
+	BannedAt       *time.Time // baneo permanente
+	SuspendedUntil *time.Time // sin acceso hasta esa hora
+	BanReason      string     // motivo del baneo o la suspensión
+	MutedUntil     *time.Time // solo lectura hasta esa hora
+	MuteReason     string
 }
 
-type Session struct {
-	ID        string
+// TwoFactor es el TOTP de un usuario (ver auth/totp.go).
+type TwoFactor struct {
 	UserID    int64
-	ExpiresAt time.Time
+	Secret    string     // base32
+	EnabledAt *time.Time // nil = alta sin confirmar
+	LastStep  int64      // último paso de 30s aceptado
+	CodesLeft int        // códigos de recuperación sin usar
 	CreatedAt time.Time
 }
 
+// UsernameChange es un nombre que tuvo el usuario (ver UserStore.SetUsername).
+type UsernameChange struct {
+	Username  string
+	ChangedAt time.Time
+}
+
+type Session struct {
+	ID         string
+	UserID     int64
+	CSRFToken  string
+	UserAgent  string
+	IP         string
+	Persistent bool   // "remember me": la cookie sobrevive al navegador
+	Role       string // rol del usuario cuando se emitió el id
+	LastSeenAt time.Time
+	ExpiresAt  time.Time
+	CreatedAt  time.Time // hora del login; se conserva al rotar
+}
+
+// APIToken es un token personal; el valor en claro nunca se guarda.
+type APIToken struct {
+	ID         int64
+	UserID     int64
+	Name       string
+	Scopes     []string
+	LastUsedAt *time.Time
+	CreatedAt  time.Time
+}
+
 type Category struct {
 	ID   int64
 	Name string
@@ -28,6 +69,9 @@ type Post struct {
 	Title     string
 	Content   string
 	CreatedAt time.Time
+	UpdatedAt *time.Time // nil = nunca editado
+	LockedAt  *time.Time // cerrado a comentarios nuevos
+	PinnedAt  *time.Time // fijado arriba de la portada
 	Cats      []Category
 	Likes     int
 	Dislikes  int
@@ -37,10 +81,50 @@ type Post struct {
 type Comment struct {
 	ID        int64
 	PostID    int64
+	ParentID  *int64 // nil = comentario de primer nivel
 	UserID    int64
 	Content   string
 	CreatedAt time.Time
+	UpdatedAt *time.Time
 	Author    string
 	Likes     int
 	Dislikes  int
+	Depth     int // 0 = primer nivel; solo lo rellena CommentStore.ForPosts
+}
+
+// Report es una denuncia sobre un post o un comentario. Los campos del final
+// describen el contenido denunciado tal como está ahora; PostID == 0 si ya
+// se borró.
+type Report struct {
+	ID         int64
+	Target     string // post | comment
+	TargetID   int64
+	ReporterID int64
+	Reporter   string
+	Reason     string
+	Status     string // open | resolved | dismissed
+	CreatedAt  time.Time
+	HandledBy  *int64 // moderador que la cerró
+	Handler    string
+	HandledAt  *time.Time
+
+	PostID    int64
+	PostTitle string
+	Content   string
+	AuthorID  int64
+	Author    string
+}
+
+// AuditEntry es una fila de audit_log: quién hizo qué sobre qué.
+type AuditEntry struct {
+	ID         int64
+	ActorID    *int64 // nil = línea de comandos
+	Actor      string // nombre actual del actor ("" si ya no existe)
+	Action     string // post.delete, user.ban, ...
+	TargetType string // post | comment | user | report
+	TargetID   int64
+	Before     string // JSON; "" = sin estado anterior
+	After      string // JSON; "" = borrado / sin estado nuevo
+	IP         string
+	CreatedAt  time.Time
 }
@@ -0,0 +1,64 @@
+package memstore
+
+import (
+	"context"
+	"strings"
+
+	"forum/internal/models"
+	"forum/internal/store"
+)
+
+type auditStore db
+
+func (s *auditStore) Append(_ context.Context, e models.AuditEntry) (int64, error) {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	e.ID = d.nextID()
+	e.Actor = ""
+	if e.ActorID != nil {
+		id := *e.ActorID
+		e.ActorID = &id
+	}
+	d.audit = append(d.audit, e)
+	return e.ID, nil
+}
+
+func (s *auditStore) List(_ context.Context, q store.AuditQuery) ([]models.AuditEntry, bool, error) {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if q.Limit <= 0 {
+		q.Limit = 50
+	}
+	var out []models.AuditEntry
+	// d.audit está en orden de inserción (ids crecientes): se recorre al revés
+	for i := len(d.audit) - 1; i >= 0 && len(out) <= q.Limit; i-- {
+		e := d.audit[i]
+		if e.ActorID != nil {
+			e.Actor = d.username(*e.ActorID)
+		}
+		prefix, isPrefix := strings.CutSuffix(q.Action, ".")
+		switch {
+		case q.Actor != "" && e.Actor != q.Actor,
+			isPrefix && !strings.HasPrefix(e.Action, prefix+"."),
+			!isPrefix && q.Action != "" && e.Action != q.Action,
+			q.TargetType != "" && e.TargetType != q.TargetType,
+			q.TargetID != 0 && e.TargetID != q.TargetID,
+			!q.From.IsZero() && e.CreatedAt.Before(q.From),
+			!q.To.IsZero() && !e.CreatedAt.Before(q.To),
+			q.BeforeID != 0 && e.ID >= q.BeforeID:
+			continue
+		}
+		if e.ActorID != nil {
+			id := *e.ActorID
+			e.ActorID = &id
+		}
+		out = append(out, e)
+	}
+	more := len(out) > q.Limit
+	if more {
+		out = out[:q.Limit]
+	}
+	return out, more, nil
+}
@@ -0,0 +1,89 @@
+package memstore
+
+import (
+	"context"
+	"sort"
+
+	"forum/internal/models"
+	"forum/internal/store"
+)
+
+type categoryStore db
+
+func (s *categoryStore) List(_ context.Context) ([]models.Category, error) {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var out []models.Category
+	for _, c := range d.categories {
+		out = append(out, *c)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+func (s *categoryStore) ForPosts(_ context.Context, postIDs []int64) (map[int64][]models.Category, error) {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	out := make(map[int64][]models.Category, len(postIDs))
+	for _, pid := range postIDs {
+		for _, cid := range d.postCats[pid] {
+			out[pid] = append(out[pid], *d.categories[cid])
+		}
+		cs := out[pid]
+		sort.Slice(cs, func(i, j int) bool { return cs[i].Name < cs[j].Name })
+	}
+	return out, nil
+}
+
+type reactionStore db
+
+func (s *reactionStore) Set(_ context.Context, uid int64, target string, id int64, value int) error {
+	_, err := s.write(uid, target, id, value, false)
+	return err
+}
+
+func (s *reactionStore) Toggle(_ context.Context, uid int64, target string, id int64, value int) (int, error) {
+	return s.write(uid, target, id, value, true)
+}
+
+func (s *reactionStore) write(uid int64, target string, id int64, value int, toggle bool) (int, error) {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	switch {
+	case target == "post" && d.posts[id] != nil:
+	case target == "comment" && d.comments[id] != nil:
+	default:
+		return 0, store.ErrNotFound
+	}
+	k := reactionKey{uid, target, id}
+	if v, ok := d.reactions[k]; toggle && ok && v == value {
+		delete(d.reactions, k)
+		return 0, nil
+	}
+	d.reactions[k] = value
+	return value, nil
+}
+
+func (s *reactionStore) Clear(_ context.Context, uid int64, target string, id int64) error {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.reactions, reactionKey{uid, target, id})
+	return nil
+}
+
+func (s *reactionStore) Mine(_ context.Context, uid int64, target string, ids []int64) (map[int64]int, error) {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	out := make(map[int64]int, len(ids))
+	for _, id := range ids {
+		if v, ok := d.reactions[reactionKey{uid, target, id}]; ok {
+			out[id] = v
+		}
+	}
+	return out, nil
+}
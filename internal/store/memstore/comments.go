@@ -0,0 +1,150 @@
+package memstore
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"forum/internal/models"
+	"forum/internal/store"
+)
+
+type commentStore db
+
+// comment devuelve una copia con autor y reacciones; llamar con mu tomado.
+func (d *db) comment(c *models.Comment) models.Comment {
+	out := *c
+	out.UpdatedAt = copyTime(c.UpdatedAt)
+	if c.ParentID != nil {
+		parent := *c.ParentID
+		out.ParentID = &parent
+	}
+	out.Author = d.username(c.UserID)
+	out.Likes, out.Dislikes = d.counts("comment", c.ID)
+	return out
+}
+
+func (s *commentStore) ForPosts(_ context.Context, postIDs []int64, latest int) ([]models.Comment, map[int64]int, error) {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	byPost := map[int64][]models.Comment{}
+	for _, c := range d.comments {
+		byPost[c.PostID] = append(byPost[c.PostID], d.comment(c))
+	}
+
+	var out []models.Comment
+	counts := make(map[int64]int, len(postIDs))
+	for _, pid := range postIDs {
+		cs := byPost[pid]
+		if len(cs) == 0 {
+			continue
+		}
+		sortComments(cs)
+		counts[pid] = len(cs)
+
+		children := map[int64][]int{} // padre -> índices en cs
+		var roots []int
+		for i, c := range cs {
+			if c.ParentID == nil {
+				roots = append(roots, i)
+			} else {
+				children[*c.ParentID] = append(children[*c.ParentID], i)
+			}
+		}
+		if latest > 0 && len(roots) > latest {
+			roots = roots[len(roots)-latest:]
+		}
+
+		var thread []models.Comment
+		var walk func(i, depth int)
+		walk = func(i, depth int) {
+			c := cs[i]
+			c.Depth = depth
+			thread = append(thread, c)
+			for _, j := range children[c.ID] {
+				walk(j, depth+1)
+			}
+		}
+		for _, i := range roots {
+			walk(i, 0)
+		}
+		sortComments(thread)
+		out = append(out, thread...)
+	}
+	return out, counts, nil
+}
+
+func sortComments(cs []models.Comment) {
+	sort.Slice(cs, func(i, j int) bool {
+		if !cs[i].CreatedAt.Equal(cs[j].CreatedAt) {
+			return cs[i].CreatedAt.Before(cs[j].CreatedAt)
+		}
+		return cs[i].ID < cs[j].ID
+	})
+}
+
+func (s *commentStore) Get(_ context.Context, id int64) (models.Comment, error) {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	c := d.comments[id]
+	if c == nil {
+		return models.Comment{}, store.ErrNotFound
+	}
+	return d.comment(c), nil
+}
+
+func (s *commentStore) Create(_ context.Context, c models.Comment) (int64, error) {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.posts[c.PostID] == nil {
+		return 0, store.ErrNotFound
+	}
+	if c.ParentID != nil {
+		p := d.comments[*c.ParentID]
+		if p == nil || p.PostID != c.PostID {
+			return 0, store.ErrNotFound
+		}
+		parent := *c.ParentID
+		c.ParentID = &parent
+	}
+	c.ID = d.nextID()
+	c.Depth = 0
+	c.UpdatedAt = nil
+	d.comments[c.ID] = &c
+	return c.ID, nil
+}
+
+func (s *commentStore) Update(_ context.Context, id int64, content string, at time.Time) error {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	c := d.comments[id]
+	if c == nil {
+		return store.ErrNotFound
+	}
+	c.Content, c.UpdatedAt = content, &at
+	return nil
+}
+
+func (s *commentStore) Delete(_ context.Context, id int64) error {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.deleteComment(id)
+	return nil
+}
+
+// deleteComment borra el comentario, su subárbol y sus reacciones; llamar con mu tomado.
+func (d *db) deleteComment(id int64) {
+	for _, c := range d.comments {
+		if c.ParentID != nil && *c.ParentID == id {
+			d.deleteComment(c.ID)
+		}
+	}
+	d.deleteReactions("comment", id)
+	delete(d.comments, id)
+}
@@ -0,0 +1,48 @@
+package memstore
+
+import (
+	"context"
+	"time"
+
+	"forum/internal/store"
+)
+
+type emailChangeStore db
+
+func (s *emailChangeStore) Create(_ context.Context, uid int64, newEmail, hash string, expires, _ time.Time) error {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.emailChg = append(d.emailChg, &emailChange{UserID: uid, NewEmail: newEmail, Hash: hash, Expires: expires})
+	return nil
+}
+
+func (s *emailChangeStore) Pending(_ context.Context, uid int64, now time.Time) (string, error) {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i := len(d.emailChg) - 1; i >= 0; i-- {
+		if c := d.emailChg[i]; c.UserID == uid && !c.Used && c.Expires.After(now) {
+			return c.NewEmail, nil
+		}
+	}
+	return "", store.ErrNotFound
+}
+
+func (s *emailChangeStore) Consume(_ context.Context, hash string, now time.Time) (int64, string, error) {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, c := range d.emailChg {
+		if c.Hash != hash || c.Used || !c.Expires.After(now) {
+			continue
+		}
+		for _, o := range d.emailChg {
+			if o.UserID == c.UserID {
+				o.Used = true
+			}
+		}
+		return c.UserID, c.NewEmail, nil
+	}
+	return 0, "", store.ErrNotFound
+}
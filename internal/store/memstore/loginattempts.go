@@ -0,0 +1,58 @@
+package memstore
+
+import (
+	"context"
+	"time"
+)
+
+type loginAttemptStore db
+
+func (s *loginAttemptStore) Fail(_ context.Context, key string, at time.Time) error {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.loginFails[key] = append(d.loginFails[key], at)
+	return nil
+}
+
+func (s *loginAttemptStore) Recent(_ context.Context, key string, since time.Time, limit int) ([]time.Time, error) {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var out []time.Time
+	fails := d.loginFails[key]
+	for i := len(fails) - 1; i >= 0 && len(out) < limit; i-- {
+		if !fails[i].Before(since) {
+			out = append(out, fails[i])
+		}
+	}
+	return out, nil
+}
+
+func (s *loginAttemptStore) Clear(_ context.Context, key string) error {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.loginFails, key)
+	return nil
+}
+
+func (s *loginAttemptStore) Prune(_ context.Context, before time.Time) error {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for k, fails := range d.loginFails {
+		kept := fails[:0]
+		for _, t := range fails {
+			if !t.Before(before) {
+				kept = append(kept, t)
+			}
+		}
+		if len(kept) == 0 {
+			delete(d.loginFails, k)
+		} else {
+			d.loginFails[k] = kept
+		}
+	}
+	return nil
+}
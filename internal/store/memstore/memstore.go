@@ -0,0 +1,172 @@
+// Package memstore implementa los repositorios de store en memoria. Pensado
+// para tests de handlers: no necesita base de datos y es seguro para uso
+// concurrente.
+package memstore
+
+import (
+	"sync"
+	"time"
+
+	"forum/internal/models"
+	"forum/internal/store"
+)
+
+// db es el estado compartido por todos los repositorios de un mismo New().
+type db struct {
+	mu sync.Mutex
+
+	seq        int64
+	users      map[int64]*models.User
+	sessions   map[string]models.Session
+	tokens     map[store.TokenKind]map[string]*oneTimeToken
+	apiTokens  map[string]*apiToken // por hash
+	posts      map[int64]*models.Post
+	postCats   map[int64][]int64 // post -> categorías
+	comments   map[int64]*models.Comment
+	categories map[int64]*models.Category
+	reactions  map[reactionKey]int
+	reports    map[int64]*models.Report
+	audit      []models.AuditEntry    // solo se añade
+	loginFails map[string][]time.Time // por clave, en orden de llegada
+	totp       map[int64]*totpState
+	emailChg   []*emailChange // en orden de creación
+	pastNames  []pastName     // en orden de cambio
+}
+
+type pastName struct {
+	UserID int64
+	models.UsernameChange
+}
+
+type emailChange struct {
+	UserID   int64
+	NewEmail string
+	Hash     string
+	Expires  time.Time
+	Used     bool
+}
+
+type oneTimeToken struct {
+	UserID  int64
+	Expires time.Time
+	Used    bool
+}
+
+type apiToken struct {
+	models.APIToken
+	Hash string
+}
+
+type reactionKey struct {
+	UserID int64
+	Target string
+	ID     int64
+}
+
+// New devuelve un Store vacío salvo por las categorías de ejemplo del schema.
+func New() *store.Store {
+	d := &db{
+		users:      map[int64]*models.User{},
+		sessions:   map[string]models.Session{},
+		tokens:     map[store.TokenKind]map[string]*oneTimeToken{},
+		apiTokens:  map[string]*apiToken{},
+		posts:      map[int64]*models.Post{},
+		postCats:   map[int64][]int64{},
+		comments:   map[int64]*models.Comment{},
+		categories: map[int64]*models.Category{},
+		reactions:  map[reactionKey]int{},
+		reports:    map[int64]*models.Report{},
+		loginFails: map[string][]time.Time{},
+		totp:       map[int64]*totpState{},
+	}
+	for _, n := range []string{"General", "Go", "DevOps", "Databases"} {
+		d.categoryID(n)
+	}
+	return &store.Store{
+		Users:      (*userStore)(d),
+		Sessions:   (*sessionStore)(d),
+		Tokens:     (*tokenStore)(d),
+		APITokens:  (*apiTokenStore)(d),
+		Posts:      (*postStore)(d),
+		Comments:   (*commentStore)(d),
+		Categories: (*categoryStore)(d),
+		Reactions:  (*reactionStore)(d),
+		Search:     (*searchStore)(d),
+		Reports:    (*reportStore)(d),
+		Audit:      (*auditStore)(d),
+
+		LoginAttempts: (*loginAttemptStore)(d),
+		TwoFactor:     (*twoFactorStore)(d),
+		EmailChanges:  (*emailChangeStore)(d),
+	}
+}
+
+// nextID imita un BIGSERIAL (único para todas las tablas, no importa).
+func (d *db) nextID() int64 {
+	d.seq++
+	return d.seq
+}
+
+// categoryID devuelve la categoría con ese nombre, creándola si no existe.
+// Llamar con mu tomado.
+func (d *db) categoryID(name string) int64 {
+	for _, c := range d.categories {
+		if c.Name == name {
+			return c.ID
+		}
+	}
+	id := d.nextID()
+	d.categories[id] = &models.Category{ID: id, Name: name}
+	return id
+}
+
+// heldByOther dice si username fue de un usuario distinto de uid; llamar con
+// mu tomado.
+func (d *db) heldByOther(username string, uid int64) bool {
+	for _, p := range d.pastNames {
+		if p.Username == username && p.UserID != uid {
+			return true
+		}
+	}
+	return false
+}
+
+// username resuelve el autor; llamar con mu tomado.
+func (d *db) username(uid int64) string {
+	if u := d.users[uid]; u != nil {
+		return u.Username
+	}
+	return ""
+}
+
+// counts devuelve likes/dislikes del objetivo; llamar con mu tomado.
+func (d *db) counts(target string, id int64) (likes, dislikes int) {
+	for k, v := range d.reactions {
+		if k.Target != target || k.ID != id {
+			continue
+		}
+		if v == 1 {
+			likes++
+		} else {
+			dislikes++
+		}
+	}
+	return likes, dislikes
+}
+
+// deleteReactions borra las reacciones de un objetivo; llamar con mu tomado.
+func (d *db) deleteReactions(target string, id int64) {
+	for k := range d.reactions {
+		if k.Target == target && k.ID == id {
+			delete(d.reactions, k)
+		}
+	}
+}
+
+func copyTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	v := *t
+	return &v
+}
@@ -0,0 +1,11 @@
+package memstore_test
+
+import (
+	"testing"
+
+	"forum/internal/store/storetest"
+)
+
+func TestMemStore(t *testing.T) {
+	storetest.Run(t, storetest.Memory)
+}
@@ -0,0 +1,171 @@
+package memstore
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"forum/internal/models"
+	"forum/internal/store"
+)
+
+type postStore db
+
+// post devuelve una copia del post con autor y reacciones; llamar con mu tomado.
+func (d *db) post(p *models.Post) models.Post {
+	out := *p
+	out.UpdatedAt = copyTime(p.UpdatedAt)
+	out.LockedAt = copyTime(p.LockedAt)
+	out.PinnedAt = copyTime(p.PinnedAt)
+	out.Author = d.username(p.UserID)
+	out.Likes, out.Dislikes = d.counts("post", p.ID)
+	out.Cats = nil
+	return out
+}
+
+// newer indica si a va antes que b en el orden del listado (más nuevo primero).
+func newer(a, b models.Post) bool {
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.After(b.CreatedAt)
+	}
+	return a.ID > b.ID
+}
+
+func (s *postStore) List(_ context.Context, q store.PostQuery) ([]models.Post, bool, error) {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var all []models.Post
+	for _, p := range d.posts {
+		if q.Category != "" && !d.hasCategory(p.ID, q.Category) {
+			continue
+		}
+		if q.AuthorID != 0 && p.UserID != q.AuthorID {
+			continue
+		}
+		if q.LikedBy != 0 && d.reactions[reactionKey{q.LikedBy, "post", p.ID}] != 1 {
+			continue
+		}
+		if (q.Pinned == store.PinOnly && p.PinnedAt == nil) || (q.Pinned == store.PinExclude && p.PinnedAt != nil) {
+			continue
+		}
+		cur := models.Post{ID: p.ID, CreatedAt: p.CreatedAt}
+		if c := q.After; c != nil && !newer(models.Post{ID: c.ID, CreatedAt: c.CreatedAt}, cur) {
+			continue
+		}
+		if c := q.Before; c != nil && !newer(cur, models.Post{ID: c.ID, CreatedAt: c.CreatedAt}) {
+			continue
+		}
+		all = append(all, d.post(p))
+	}
+
+	sort.Slice(all, func(i, j int) bool { return newer(all[i], all[j]) })
+	if q.Before != nil {
+		// hacia atrás: los más cercanos al cursor son los últimos
+		slices.Reverse(all)
+	}
+	more := len(all) > q.Limit
+	if more {
+		all = all[:q.Limit]
+	}
+	if q.Before != nil {
+		slices.Reverse(all)
+	}
+	return all, more, nil
+}
+
+// hasCategory; llamar con mu tomado.
+func (d *db) hasCategory(pid int64, name string) bool {
+	for _, cid := range d.postCats[pid] {
+		if c := d.categories[cid]; c != nil && c.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *postStore) Get(_ context.Context, id int64) (models.Post, error) {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	p := d.posts[id]
+	if p == nil {
+		return models.Post{}, store.ErrNotFound
+	}
+	return d.post(p), nil
+}
+
+func (s *postStore) Create(_ context.Context, p models.Post, categories []string) (int64, error) {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	p.ID = d.nextID()
+	p.UpdatedAt, p.LockedAt, p.PinnedAt = nil, nil, nil
+	d.posts[p.ID] = &p
+	for _, name := range categories {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		cid := d.categoryID(name)
+		if !slices.Contains(d.postCats[p.ID], cid) {
+			d.postCats[p.ID] = append(d.postCats[p.ID], cid)
+		}
+	}
+	return p.ID, nil
+}
+
+func (s *postStore) Update(_ context.Context, id int64, title, content string, at time.Time) error {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	p := d.posts[id]
+	if p == nil {
+		return store.ErrNotFound
+	}
+	p.Title, p.Content, p.UpdatedAt = title, content, &at
+	return nil
+}
+
+func (s *postStore) SetLocked(_ context.Context, id int64, at *time.Time) error {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	p := d.posts[id]
+	if p == nil {
+		return store.ErrNotFound
+	}
+	p.LockedAt = copyTime(at)
+	return nil
+}
+
+func (s *postStore) SetPinned(_ context.Context, id int64, at *time.Time) error {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	p := d.posts[id]
+	if p == nil {
+		return store.ErrNotFound
+	}
+	p.PinnedAt = copyTime(at)
+	return nil
+}
+
+func (s *postStore) Delete(_ context.Context, id int64) error {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for cid, c := range d.comments {
+		if c.PostID == id {
+			d.deleteReactions("comment", cid)
+			delete(d.comments, cid)
+		}
+	}
+	d.deleteReactions("post", id)
+	delete(d.postCats, id)
+	delete(d.posts, id)
+	return nil
+}
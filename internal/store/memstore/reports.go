@@ -0,0 +1,106 @@
+package memstore
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"forum/internal/models"
+	"forum/internal/store"
+)
+
+type reportStore db
+
+func (s *reportStore) Create(_ context.Context, r models.Report) (int64, error) {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	switch {
+	case r.Target == "post" && d.posts[r.TargetID] != nil:
+	case r.Target == "comment" && d.comments[r.TargetID] != nil:
+	default:
+		return 0, store.ErrNotFound
+	}
+	for _, o := range d.reports {
+		if o.Status == store.ReportOpen && o.Target == r.Target && o.TargetID == r.TargetID && o.ReporterID == r.ReporterID {
+			return 0, store.ErrAlreadyReported
+		}
+	}
+	r.ID = d.nextID()
+	r.Status = store.ReportOpen
+	r.HandledBy, r.HandledAt = nil, nil
+	d.reports[r.ID] = &r
+	return r.ID, nil
+}
+
+// report copia la denuncia con los nombres y el contenido actual; llamar con mu tomado.
+func (d *db) report(r *models.Report) models.Report {
+	out := *r
+	out.Reporter = d.username(r.ReporterID)
+	out.HandledAt = copyTime(r.HandledAt)
+	if r.HandledBy != nil {
+		by := *r.HandledBy
+		out.HandledBy = &by
+		out.Handler = d.username(by)
+	}
+	switch {
+	case r.Target == "post" && d.posts[r.TargetID] != nil:
+		p := d.posts[r.TargetID]
+		out.PostID, out.PostTitle, out.Content, out.AuthorID = p.ID, p.Title, p.Content, p.UserID
+	case r.Target == "comment" && d.comments[r.TargetID] != nil:
+		c := d.comments[r.TargetID]
+		out.PostID, out.Content, out.AuthorID = c.PostID, c.Content, c.UserID
+		if p := d.posts[c.PostID]; p != nil {
+			out.PostTitle = p.Title
+		}
+	}
+	if out.PostID != 0 {
+		out.Author = d.username(out.AuthorID)
+	}
+	return out
+}
+
+func (s *reportStore) Get(_ context.Context, id int64) (models.Report, error) {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	r := d.reports[id]
+	if r == nil {
+		return models.Report{}, store.ErrNotFound
+	}
+	return d.report(r), nil
+}
+
+func (s *reportStore) Open(_ context.Context, limit int) ([]models.Report, error) {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var out []models.Report
+	for _, r := range d.reports {
+		if r.Status == store.ReportOpen {
+			out = append(out, d.report(r))
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].CreatedAt.Equal(out[j].CreatedAt) {
+			return out[i].CreatedAt.Before(out[j].CreatedAt)
+		}
+		return out[i].ID < out[j].ID
+	})
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+func (s *reportStore) Close(_ context.Context, id int64, status string, by int64, at time.Time) error {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	r := d.reports[id]
+	if r == nil || r.Status != store.ReportOpen {
+		return store.ErrNotFound
+	}
+	r.Status, r.HandledBy, r.HandledAt = status, &by, &at
+	return nil
+}
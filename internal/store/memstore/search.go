@@ -0,0 +1,165 @@
+package memstore
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"forum/internal/store"
+)
+
+type searchStore db
+
+// Search es una versión ingenua de la de sqlstore: coincidencia de
+// subcadenas sin distinguir mayúsculas, sin raíces ni stopwords. Sirve para
+// los tests de handlers, no para comparar rankings.
+func (s *searchStore) Search(_ context.Context, q store.SearchQuery) ([]store.SearchHit, bool, error) {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if q.Limit <= 0 {
+		q.Limit = 20
+	}
+	include, exclude := store.Terms(q.Text)
+
+	type scored struct {
+		hit  store.SearchHit
+		rank int
+	}
+	var all []scored
+	consider := func(kind string, pid, cid, uid int64, title, body string, at time.Time) {
+		p := d.posts[pid]
+		if p == nil {
+			return
+		}
+		if q.Author != "" && d.username(uid) != q.Author {
+			return
+		}
+		if q.Category != "" && !d.hasCategory(pid, q.Category) {
+			return
+		}
+		if (!q.From.IsZero() && at.Before(q.From)) || (!q.To.IsZero() && !at.Before(q.To)) {
+			return
+		}
+		text := strings.ToLower(title + "\n" + body)
+		rank := 0
+		for _, t := range include {
+			t = strings.ToLower(t)
+			if !strings.Contains(text, t) {
+				return
+			}
+			rank += 4*strings.Count(strings.ToLower(title), t) + strings.Count(strings.ToLower(body), t)
+		}
+		for _, t := range exclude {
+			if strings.Contains(text, strings.ToLower(t)) {
+				return
+			}
+		}
+		all = append(all, scored{store.SearchHit{
+			Kind:      kind,
+			PostID:    pid,
+			CommentID: cid,
+			Title:     p.Title,
+			Author:    d.username(uid),
+			Snippet:   snippet(body, include),
+			CreatedAt: at,
+		}, rank})
+	}
+	for _, p := range d.posts {
+		consider("post", p.ID, 0, p.UserID, p.Title, p.Content, p.CreatedAt)
+	}
+	for _, c := range d.comments {
+		consider("comment", c.PostID, c.ID, c.UserID, "", c.Content, c.CreatedAt)
+	}
+
+	sort.Slice(all, func(i, j int) bool {
+		a, b := all[i], all[j]
+		if a.rank != b.rank {
+			return a.rank > b.rank
+		}
+		if !a.hit.CreatedAt.Equal(b.hit.CreatedAt) {
+			return a.hit.CreatedAt.After(b.hit.CreatedAt)
+		}
+		if a.hit.PostID != b.hit.PostID {
+			return a.hit.PostID > b.hit.PostID
+		}
+		return a.hit.CommentID > b.hit.CommentID
+	})
+
+	if q.Offset >= len(all) {
+		return nil, false, nil
+	}
+	all = all[q.Offset:]
+	more := len(all) > q.Limit
+	if more {
+		all = all[:q.Limit]
+	}
+	hits := make([]store.SearchHit, len(all))
+	for i, a := range all {
+		hits[i] = a.hit
+	}
+	return hits, more, nil
+}
+
+// snippet recorta el texto alrededor de la primera coincidencia y marca todas
+// las que quedan dentro. Sin términos, devuelve el principio (como sqlstore).
+func snippet(body string, terms []string) string {
+	const width = 200
+	r := []rune(body)
+	lower := []rune(strings.ToLower(body))
+	if len(lower) != len(r) {
+		lower = r // ToLower cambió longitudes: se compara tal cual
+	}
+	start := 0
+	for _, t := range terms {
+		if i := runeIndex(lower, []rune(strings.ToLower(t))); i >= 0 {
+			start = max(0, i-width/4)
+			break
+		}
+	}
+	end := min(len(r), start+width)
+
+	var sb strings.Builder
+	if start > 0 {
+		sb.WriteString("…")
+	}
+	for i := start; i < end; {
+		n := 0
+		for _, t := range terms {
+			tr := []rune(strings.ToLower(t))
+			if i+len(tr) <= end && len(tr) > 0 && runeIndex(lower[i:i+len(tr)], tr) == 0 {
+				n = len(tr)
+				break
+			}
+		}
+		if n == 0 {
+			sb.WriteRune(r[i])
+			i++
+			continue
+		}
+		sb.WriteString(store.HighlightStart + string(r[i:i+n]) + store.HighlightEnd)
+		i += n
+	}
+	if end < len(r) {
+		sb.WriteString("…")
+	}
+	return sb.String()
+}
+
+func runeIndex(s, sub []rune) int {
+	for i := 0; i+len(sub) <= len(s); i++ {
+		match := true
+		for j := range sub {
+			if s[i+j] != sub[j] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return i
+		}
+	}
+	return -1
+}
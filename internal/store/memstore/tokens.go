@@ -0,0 +1,131 @@
+package memstore
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"forum/internal/models"
+	"forum/internal/store"
+)
+
+type tokenStore db
+
+func (s *tokenStore) Create(_ context.Context, kind store.TokenKind, uid int64, hash string, expires, _ time.Time) error {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.tokens[kind] == nil {
+		d.tokens[kind] = map[string]*oneTimeToken{}
+	}
+	d.tokens[kind][hash] = &oneTimeToken{UserID: uid, Expires: expires}
+	return nil
+}
+
+func (s *tokenStore) Consume(_ context.Context, kind store.TokenKind, hash string, now time.Time) (int64, error) {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.consumeToken(kind, hash, now)
+}
+
+func (s *tokenStore) ConsumeAndReset(_ context.Context, hash, passwordHash string, now time.Time) (int64, error) {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	t := d.tokens[store.TokenPasswordReset][hash]
+	if t == nil || d.users[t.UserID] == nil {
+		return 0, store.ErrNotFound
+	}
+	uid, err := d.consumeToken(store.TokenPasswordReset, hash, now)
+	if err != nil {
+		return 0, err
+	}
+	_ = d.changePassword(uid, passwordHash, "") // el usuario existe: comprobado arriba
+	return uid, nil
+}
+
+// consumeToken: llamar con mu tomado.
+func (d *db) consumeToken(kind store.TokenKind, hash string, now time.Time) (int64, error) {
+	t := d.tokens[kind][hash]
+	if t == nil || t.Used || !t.Expires.After(now) {
+		return 0, store.ErrNotFound
+	}
+	for _, o := range d.tokens[kind] {
+		if o.UserID == t.UserID {
+			o.Used = true
+		}
+	}
+	return t.UserID, nil
+}
+
+func (s *tokenStore) Lookup(_ context.Context, kind store.TokenKind, hash string, now time.Time) (int64, error) {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	t := d.tokens[kind][hash]
+	if t == nil || t.Used || !t.Expires.After(now) {
+		return 0, store.ErrNotFound
+	}
+	return t.UserID, nil
+}
+
+type apiTokenStore db
+
+func (s *apiTokenStore) Create(_ context.Context, t models.APIToken, hash string) (int64, error) {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	t.ID = d.nextID()
+	t.Scopes = append([]string(nil), t.Scopes...)
+	d.apiTokens[hash] = &apiToken{APIToken: t, Hash: hash}
+	return t.ID, nil
+}
+
+func (s *apiTokenStore) ListForUser(_ context.Context, uid int64) ([]models.APIToken, error) {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var out []models.APIToken
+	for _, t := range d.apiTokens {
+		if t.UserID == uid {
+			c := t.APIToken
+			c.LastUsedAt = copyTime(t.LastUsedAt)
+			out = append(out, c)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].CreatedAt.Equal(out[j].CreatedAt) {
+			return out[i].CreatedAt.After(out[j].CreatedAt)
+		}
+		return out[i].ID > out[j].ID
+	})
+	return out, nil
+}
+
+func (s *apiTokenStore) Delete(_ context.Context, uid, id int64) error {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for h, t := range d.apiTokens {
+		if t.ID == id && t.UserID == uid {
+			delete(d.apiTokens, h)
+			return nil
+		}
+	}
+	return store.ErrNotFound
+}
+
+func (s *apiTokenStore) Use(_ context.Context, hash string, now time.Time) (models.APIToken, error) {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	t := d.apiTokens[hash]
+	if t == nil {
+		return models.APIToken{}, store.ErrNotFound
+	}
+	t.LastUsedAt = &now
+	c := t.APIToken
+	c.LastUsedAt = copyTime(t.LastUsedAt)
+	return c, nil
+}
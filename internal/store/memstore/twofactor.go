@@ -0,0 +1,118 @@
+package memstore
+
+import (
+	"context"
+	"time"
+
+	"forum/internal/models"
+	"forum/internal/store"
+)
+
+type totpState struct {
+	models.TwoFactor
+	Codes map[string]bool // hash -> usado
+}
+
+type twoFactorStore db
+
+func (s *twoFactorStore) Get(_ context.Context, uid int64) (models.TwoFactor, error) {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	st := d.totp[uid]
+	if st == nil {
+		return models.TwoFactor{}, store.ErrNotFound
+	}
+	tf := st.TwoFactor
+	tf.EnabledAt = copyTime(st.EnabledAt)
+	tf.CodesLeft = 0
+	for _, used := range st.Codes {
+		if !used {
+			tf.CodesLeft++
+		}
+	}
+	return tf, nil
+}
+
+func (s *twoFactorStore) Begin(_ context.Context, uid int64, secret string, at time.Time) error {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if st := d.totp[uid]; st != nil && st.EnabledAt != nil {
+		return store.ErrNotFound
+	}
+	d.totp[uid] = &totpState{TwoFactor: models.TwoFactor{UserID: uid, Secret: secret, CreatedAt: at}}
+	return nil
+}
+
+func (s *twoFactorStore) Enable(_ context.Context, uid, step int64, codeHashes []string, at time.Time) error {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	st := d.totp[uid]
+	if st == nil || st.EnabledAt != nil {
+		return store.ErrNotFound
+	}
+	st.EnabledAt = copyTime(&at)
+	st.LastStep = step
+	st.Codes = newCodes(codeHashes)
+	return nil
+}
+
+func (s *twoFactorStore) Disable(_ context.Context, uid int64) error {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.totp[uid] == nil {
+		return store.ErrNotFound
+	}
+	delete(d.totp, uid)
+	return nil
+}
+
+func (s *twoFactorStore) UseStep(_ context.Context, uid, step int64) error {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	st := d.totp[uid]
+	if st == nil || st.EnabledAt == nil || step <= st.LastStep {
+		return store.ErrNotFound
+	}
+	st.LastStep = step
+	return nil
+}
+
+func (s *twoFactorStore) UseRecoveryCode(_ context.Context, uid int64, hash string, _ time.Time) error {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	st := d.totp[uid]
+	if st == nil {
+		return store.ErrNotFound
+	}
+	if used, ok := st.Codes[hash]; !ok || used {
+		return store.ErrNotFound
+	}
+	st.Codes[hash] = true
+	return nil
+}
+
+func (s *twoFactorStore) ReplaceRecoveryCodes(_ context.Context, uid int64, hashes []string, _ time.Time) error {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	st := d.totp[uid]
+	if st == nil {
+		return store.ErrNotFound
+	}
+	st.Codes = newCodes(hashes)
+	return nil
+}
+
+func newCodes(hashes []string) map[string]bool {
+	m := make(map[string]bool, len(hashes))
+	for _, h := range hashes {
+		m[h] = false
+	}
+	return m
+}
@@ -0,0 +1,314 @@
+package memstore
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"forum/internal/models"
+	"forum/internal/store"
+)
+
+type userStore db
+
+func (s *userStore) Create(_ context.Context, u models.User) (int64, error) {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, o := range d.users {
+		if o.Email == u.Email {
+			return 0, store.ErrEmailTaken
+		}
+		if o.Username == u.Username {
+			return 0, store.ErrUsernameTaken
+		}
+	}
+	if d.heldByOther(u.Username, 0) {
+		return 0, store.ErrUsernameTaken
+	}
+	if u.Role == "" {
+		u.Role = "member"
+	}
+	u.ID = d.nextID()
+	u.EmailVerifiedAt = copyTime(u.EmailVerifiedAt)
+	d.users[u.ID] = &u
+	return u.ID, nil
+}
+
+func (s *userStore) find(match func(*models.User) bool) (models.User, error) {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, u := range d.users {
+		if match(u) {
+			out := *u
+			out.EmailVerifiedAt = copyTime(u.EmailVerifiedAt)
+			out.BannedAt = copyTime(u.BannedAt)
+			out.SuspendedUntil = copyTime(u.SuspendedUntil)
+			out.MutedUntil = copyTime(u.MutedUntil)
+			return out, nil
+		}
+	}
+	return models.User{}, store.ErrNotFound
+}
+
+func (s *userStore) ByID(_ context.Context, id int64) (models.User, error) {
+	return s.find(func(u *models.User) bool { return u.ID == id })
+}
+
+func (s *userStore) ByEmail(_ context.Context, email string) (models.User, error) {
+	return s.find(func(u *models.User) bool { return u.Email == email })
+}
+
+func (s *userStore) ByUsername(_ context.Context, username string) (models.User, error) {
+	return s.find(func(u *models.User) bool { return u.Username == username })
+}
+
+func (s *userStore) ChangePassword(_ context.Context, id int64, hash, keepSession string) error {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.changePassword(id, hash, keepSession)
+}
+
+// changePassword: llamar con mu tomado.
+func (d *db) changePassword(id int64, hash, keepSession string) error {
+	u := d.users[id]
+	if u == nil {
+		return store.ErrNotFound
+	}
+	u.PasswordHash = hash
+	d.deleteSessions(id, keepSession)
+	for h, t := range d.apiTokens {
+		if t.UserID == id {
+			delete(d.apiTokens, h)
+		}
+	}
+	return nil
+}
+
+func (s *userStore) SetEmail(_ context.Context, id int64, email string, verifiedAt time.Time) error {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	u := d.users[id]
+	if u == nil {
+		return store.ErrNotFound
+	}
+	for _, o := range d.users {
+		if o.ID != id && o.Email == email {
+			return store.ErrEmailTaken
+		}
+	}
+	u.Email, u.EmailVerifiedAt = email, &verifiedAt
+	return nil
+}
+
+func (s *userStore) SetUsername(_ context.Context, id int64, username string, at time.Time) error {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	u := d.users[id]
+	if u == nil {
+		return store.ErrNotFound
+	}
+	if u.Username == username {
+		return nil
+	}
+	if d.heldByOther(username, id) {
+		return store.ErrUsernameTaken
+	}
+	for _, o := range d.users {
+		if o.Username == username {
+			return store.ErrUsernameTaken
+		}
+	}
+	d.pastNames = append(d.pastNames, pastName{UserID: id, UsernameChange: models.UsernameChange{Username: u.Username, ChangedAt: at}})
+	u.Username = username
+	return nil
+}
+
+func (s *userStore) PastUsernames(_ context.Context, id int64) ([]models.UsernameChange, error) {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var out []models.UsernameChange
+	for i := len(d.pastNames) - 1; i >= 0; i-- {
+		if d.pastNames[i].UserID == id {
+			out = append(out, d.pastNames[i].UsernameChange)
+		}
+	}
+	return out, nil
+}
+
+func (s *userStore) SetRole(_ context.Context, id int64, role string) error {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	u := d.users[id]
+	if u == nil {
+		return store.ErrNotFound
+	}
+	u.Role = role
+	return nil
+}
+
+func (s *userStore) MarkEmailVerified(_ context.Context, id int64, at time.Time) error {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if u := d.users[id]; u != nil && u.EmailVerifiedAt == nil {
+		u.EmailVerifiedAt = &at
+	}
+	return nil
+}
+
+func (s *userStore) SetBan(_ context.Context, id int64, bannedAt, suspendedUntil *time.Time, reason string) error {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	u := d.users[id]
+	if u == nil {
+		return store.ErrNotFound
+	}
+	u.BannedAt, u.SuspendedUntil, u.BanReason = copyTime(bannedAt), copyTime(suspendedUntil), reason
+	return nil
+}
+
+func (s *userStore) SetMute(_ context.Context, id int64, until *time.Time, reason string) error {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	u := d.users[id]
+	if u == nil {
+		return store.ErrNotFound
+	}
+	u.MutedUntil, u.MuteReason = copyTime(until), reason
+	return nil
+}
+
+type sessionStore db
+
+func (s *sessionStore) Create(_ context.Context, ses models.Session) error {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.sessions[ses.ID] = ses
+	return nil
+}
+
+func (s *sessionStore) Get(_ context.Context, id string) (models.Session, error) {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	ses, ok := d.sessions[id]
+	if !ok {
+		return models.Session{}, store.ErrNotFound
+	}
+	return ses, nil
+}
+
+func (s *sessionStore) ListForUser(_ context.Context, uid int64, now time.Time) ([]models.Session, error) {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var out []models.Session
+	for _, ses := range d.sessions {
+		if ses.UserID == uid && ses.ExpiresAt.After(now) {
+			out = append(out, ses)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].LastSeenAt.Equal(out[j].LastSeenAt) {
+			return out[i].LastSeenAt.After(out[j].LastSeenAt)
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out, nil
+}
+
+func (s *sessionStore) Touch(_ context.Context, id, ip string, at time.Time) error {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	ses, ok := d.sessions[id]
+	if !ok {
+		return store.ErrNotFound
+	}
+	ses.LastSeenAt, ses.IP = at, ip
+	d.sessions[id] = ses
+	return nil
+}
+
+func (s *sessionStore) Renew(_ context.Context, id string, expiresAt time.Time) error {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	ses, ok := d.sessions[id]
+	if !ok {
+		return store.ErrNotFound
+	}
+	ses.ExpiresAt = expiresAt
+	d.sessions[id] = ses
+	return nil
+}
+
+func (s *sessionStore) Rotate(_ context.Context, oldID string, ses models.Session) error {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.sessions[oldID]; !ok {
+		return store.ErrNotFound
+	}
+	delete(d.sessions, oldID)
+	d.sessions[ses.ID] = ses
+	return nil
+}
+
+func (s *sessionStore) Delete(_ context.Context, id string) error {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.sessions, id)
+	return nil
+}
+
+func (s *sessionStore) DeleteForUser(_ context.Context, uid int64) error {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.deleteSessions(uid, "")
+	return nil
+}
+
+func (s *sessionStore) DeleteOthers(_ context.Context, uid int64, keep string) error {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.deleteSessions(uid, keep)
+	return nil
+}
+
+// deleteSessions borra las sesiones de uid salvo keep ("" = todas). Llamar
+// con mu tomado.
+func (d *db) deleteSessions(uid int64, keep string) {
+	for id, ses := range d.sessions {
+		if ses.UserID == uid && id != keep {
+			delete(d.sessions, id)
+		}
+	}
+}
+
+func (s *sessionStore) DeleteExpired(_ context.Context, now time.Time) (int64, error) {
+	d := (*db)(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var n int64
+	for id, ses := range d.sessions {
+		if !ses.ExpiresAt.After(now) {
+			delete(d.sessions, id)
+			n++
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,49 @@
+package store
+
+import "strings"
+
+// Marcas de resaltado en SearchHit.Snippet. Son caracteres de uso privado
+// para que no choquen con el texto ni con HTML; la capa web las cambia por
+// <mark> después de escapar.
+const (
+	HighlightStart = "\ue000"
+	HighlightEnd   = "\ue001"
+)
+
+// Terms separa el texto de búsqueda en palabras o "frases" que deben
+// aparecer y las que llevan '-' delante y no deben aparecer. Es la misma
+// sintaxis que websearch_to_tsquery de Postgres (sin OR).
+func Terms(text string) (include, exclude []string) {
+	for text = strings.TrimSpace(text); text != ""; text = strings.TrimSpace(text) {
+		neg := false
+		if text[0] == '-' {
+			neg, text = true, text[1:]
+		}
+		var term string
+		if strings.HasPrefix(text, `"`) {
+			end := strings.IndexByte(text[1:], '"')
+			if end < 0 {
+				term, text = text[1:], ""
+			} else {
+				term, text = text[1:end+1], text[end+2:]
+			}
+		} else {
+			end := strings.IndexFunc(text, isSpace)
+			if end < 0 {
+				end = len(text)
+			}
+			term, text = text[:end], text[end:]
+		}
+		term = strings.Join(strings.Fields(term), " ")
+		switch {
+		case term == "":
+		case neg:
+			exclude = append(exclude, term)
+		default:
+			include = append(include, term)
+		}
+	}
+	return include, exclude
+}
+
+func isSpace(r rune) bool { return r == ' ' || r == '\t' || r == '\n' || r == '\r' }
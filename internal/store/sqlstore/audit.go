@@ -0,0 +1,106 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"forum/internal/models"
+	"forum/internal/store"
+)
+
+type auditStore struct{ db *sql.DB }
+
+func (s *auditStore) Append(ctx context.Context, e models.AuditEntry) (int64, error) {
+	var actor sql.NullInt64
+	if e.ActorID != nil {
+		actor = sql.NullInt64{Int64: *e.ActorID, Valid: true}
+	}
+	var id int64
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO audit_log (actor_id, action, target_type, target_id, before, after, ip, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id
+	`, actor, e.Action, e.TargetType, e.TargetID, nullJSON(e.Before), nullJSON(e.After), e.IP, e.CreatedAt).Scan(&id)
+	return id, err
+}
+
+// nullJSON guarda "" como NULL (sin estado).
+func nullJSON(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+func (s *auditStore) List(ctx context.Context, q store.AuditQuery) ([]models.AuditEntry, bool, error) {
+	if q.Limit <= 0 {
+		q.Limit = 50
+	}
+	var where []string
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	if q.Actor != "" {
+		where = append(where, "u.username = "+arg(q.Actor))
+	}
+	if prefix, ok := strings.CutSuffix(q.Action, "."); ok {
+		where = append(where, "a.action LIKE "+arg(prefix+".%"))
+	} else if q.Action != "" {
+		where = append(where, "a.action = "+arg(q.Action))
+	}
+	if q.TargetType != "" {
+		where = append(where, "a.target_type = "+arg(q.TargetType))
+	}
+	if q.TargetID != 0 {
+		where = append(where, "a.target_id = "+arg(q.TargetID))
+	}
+	if !q.From.IsZero() {
+		where = append(where, "a.created_at >= "+arg(q.From))
+	}
+	if !q.To.IsZero() {
+		where = append(where, "a.created_at < "+arg(q.To))
+	}
+	if q.BeforeID != 0 {
+		where = append(where, "a.id < "+arg(q.BeforeID))
+	}
+	cond := ""
+	if len(where) > 0 {
+		cond = " WHERE " + strings.Join(where, " AND ")
+	}
+
+	// Orden por id: es el orden de inserción aunque dos filas compartan hora.
+	rows, err := s.db.QueryContext(ctx, `
+SELECT a.id, a.actor_id, COALESCE(u.username, ''), a.action, a.target_type, a.target_id,
+       a.before, a.after, a.ip, a.created_at
+  FROM audit_log a
+  LEFT JOIN users u ON u.id = a.actor_id`+cond+`
+ ORDER BY a.id DESC
+ LIMIT `+arg(q.Limit+1), args...)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	var out []models.AuditEntry
+	for rows.Next() {
+		var e models.AuditEntry
+		var actor sql.NullInt64
+		var before, after sql.NullString
+		if err := rows.Scan(&e.ID, &actor, &e.Actor, &e.Action, &e.TargetType, &e.TargetID,
+			&before, &after, &e.IP, &e.CreatedAt); err != nil {
+			return nil, false, err
+		}
+		e.ActorID = int64Ptr(actor)
+		e.Before, e.After = before.String, after.String
+		out = append(out, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+	more := len(out) > q.Limit
+	if more {
+		out = out[:q.Limit]
+	}
+	return out, more, nil
+}
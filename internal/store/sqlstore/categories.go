@@ -0,0 +1,63 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"forum/internal/db"
+	"forum/internal/models"
+)
+
+type categoryStore struct {
+	db      *sql.DB
+	dialect db.Dialect
+}
+
+func (s *categoryStore) List(ctx context.Context) ([]models.Category, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, name FROM categories ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("categories query: %w", err)
+	}
+	defer rows.Close()
+
+	var cats []models.Category
+	for rows.Next() {
+		var c models.Category
+		if err := rows.Scan(&c.ID, &c.Name); err != nil {
+			return nil, fmt.Errorf("categories scan: %w", err)
+		}
+		cats = append(cats, c)
+	}
+	return cats, rows.Err()
+}
+
+// ForPosts trae las categorías de todos los posts con una sola consulta.
+func (s *categoryStore) ForPosts(ctx context.Context, postIDs []int64) (map[int64][]models.Category, error) {
+	out := make(map[int64][]models.Category, len(postIDs))
+	if len(postIDs) == 0 {
+		return out, nil
+	}
+	in, args := inIDs(s.dialect, "pc.post_id", postIDs, 1)
+	rows, err := s.db.QueryContext(ctx, `
+SELECT pc.post_id, c.id, c.name
+  FROM post_categories pc
+  JOIN categories c ON c.id = pc.category_id
+ WHERE `+in+`
+ ORDER BY pc.post_id, c.name
+`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("post categories query: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var pid int64
+		var c models.Category
+		if err := rows.Scan(&pid, &c.ID, &c.Name); err != nil {
+			return nil, fmt.Errorf("post categories scan: %w", err)
+		}
+		out[pid] = append(out[pid], c)
+	}
+	return out, rows.Err()
+}
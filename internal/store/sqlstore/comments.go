@@ -0,0 +1,151 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"forum/internal/db"
+	"forum/internal/models"
+)
+
+type commentStore struct {
+	db      *sql.DB
+	dialect db.Dialect
+}
+
+// ForPosts trae los hilos de todos los posts en una consulta. base numera
+// los comentarios de primer nivel desde el más nuevo (para quedarse con los
+// últimos N) y cuenta el total por post; thread baja recursivamente por
+// parent_id calculando la profundidad; al final se suman las reacciones.
+func (s *commentStore) ForPosts(ctx context.Context, postIDs []int64, latest int) ([]models.Comment, map[int64]int, error) {
+	counts := make(map[int64]int, len(postIDs))
+	if len(postIDs) == 0 {
+		return nil, counts, nil
+	}
+	if latest < 0 {
+		latest = 0 // 0 = sin límite en el WHERE de abajo
+	}
+	in, args := inIDs(s.dialect, "c.post_id", postIDs, 2)
+	rows, err := s.db.QueryContext(ctx, `
+WITH RECURSIVE base AS (
+    SELECT c.id, c.post_id, c.parent_id,
+           ROW_NUMBER() OVER (PARTITION BY c.post_id, c.parent_id IS NULL ORDER BY c.created_at DESC, c.id DESC) AS rn,
+           COUNT(*)     OVER (PARTITION BY c.post_id) AS total
+      FROM comments c
+     WHERE `+in+`
+), thread AS (
+    SELECT id, total, 0 AS depth
+      FROM base
+     WHERE parent_id IS NULL AND ($1 = 0 OR rn <= $1)
+    UNION ALL
+    SELECT b.id, b.total, t.depth + 1
+      FROM base b
+      JOIN thread t ON b.parent_id = t.id
+)
+SELECT c.id, c.post_id, c.parent_id, c.user_id, u.username, c.content, c.created_at, c.updated_at, t.depth, t.total,
+       COUNT(r.id) FILTER (WHERE r.value = 1)  AS likes,
+       COUNT(r.id) FILTER (WHERE r.value = -1) AS dislikes
+  FROM thread t
+  JOIN comments c ON c.id = t.id
+  JOIN users u ON u.id = c.user_id
+  LEFT JOIN reactions r ON r.target_type = 'comment' AND r.target_id = c.id
+ GROUP BY c.id, c.post_id, c.parent_id, c.user_id, u.username, c.content, c.created_at, c.updated_at, t.depth, t.total
+ ORDER BY c.post_id, c.created_at ASC, c.id ASC
+`, append([]any{latest}, args...)...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("comments query: %w", err)
+	}
+	defer rows.Close()
+
+	var out []models.Comment
+	for rows.Next() {
+		var c models.Comment
+		var total int
+		var parent sql.NullInt64
+		var updated sql.NullTime
+		if err := rows.Scan(&c.ID, &c.PostID, &parent, &c.UserID, &c.Author, &c.Content, &c.CreatedAt, &updated, &c.Depth, &total, &c.Likes, &c.Dislikes); err != nil {
+			return nil, nil, fmt.Errorf("comments scan: %w", err)
+		}
+		c.ParentID = int64Ptr(parent)
+		c.UpdatedAt = timePtr(updated)
+		counts[c.PostID] = total
+		out = append(out, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("comments err: %w", err)
+	}
+	return out, counts, nil
+}
+
+func (s *commentStore) Get(ctx context.Context, id int64) (models.Comment, error) {
+	var c models.Comment
+	var parent sql.NullInt64
+	var updated sql.NullTime
+	err := s.db.QueryRowContext(ctx, `
+SELECT c.id, c.post_id, c.parent_id, c.user_id, u.username, c.content, c.created_at, c.updated_at,
+       (SELECT COUNT(*) FROM reactions r WHERE r.target_type = 'comment' AND r.target_id = c.id AND r.value = 1),
+       (SELECT COUNT(*) FROM reactions r WHERE r.target_type = 'comment' AND r.target_id = c.id AND r.value = -1)
+  FROM comments c
+  JOIN users u ON u.id = c.user_id
+ WHERE c.id = $1
+`, id).Scan(&c.ID, &c.PostID, &parent, &c.UserID, &c.Author, &c.Content, &c.CreatedAt, &updated, &c.Likes, &c.Dislikes)
+	if err != nil {
+		return models.Comment{}, notFound(err)
+	}
+	c.ParentID = int64Ptr(parent)
+	c.UpdatedAt = timePtr(updated)
+	return c, nil
+}
+
+// Create inserta el comentario solo si existe el post y, si es una
+// respuesta, si el padre es de ese mismo post.
+func (s *commentStore) Create(ctx context.Context, c models.Comment) (int64, error) {
+	var id, parent int64 // parent 0 = primer nivel
+	if c.ParentID != nil {
+		parent = *c.ParentID
+	}
+	err := s.db.QueryRowContext(ctx, `
+INSERT INTO comments (post_id, parent_id, user_id, content, created_at)
+SELECT $1, p.id, $3, $4, $5
+  FROM posts
+  LEFT JOIN comments p ON p.id = $2 AND p.post_id = posts.id
+ WHERE posts.id = $1
+   AND ($2 = 0 OR p.id IS NOT NULL)
+RETURNING id
+`, c.PostID, parent, c.UserID, c.Content, c.CreatedAt).Scan(&id)
+	return id, notFound(err)
+}
+
+func (s *commentStore) Update(ctx context.Context, id int64, content string, at time.Time) error {
+	return exec1(ctx, s.db, `
+UPDATE comments SET content = $1, updated_at = $2 WHERE id = $3
+`, content, at, id)
+}
+
+// Delete borra el comentario y su subárbol (CASCADE por parent_id); las
+// reacciones no tienen FK, así que se recorre el subárbol para borrarlas.
+func (s *commentStore) Delete(ctx context.Context, id int64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+WITH RECURSIVE sub AS (
+    SELECT id FROM comments WHERE id = $1
+    UNION ALL
+    SELECT c.id FROM comments c JOIN sub ON c.parent_id = sub.id
+)
+DELETE FROM reactions
+ WHERE target_type = 'comment' AND target_id IN (SELECT id FROM sub)
+`, id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM comments WHERE id = $1`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
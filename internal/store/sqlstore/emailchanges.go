@@ -0,0 +1,55 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type emailChangeStore struct{ db *sql.DB }
+
+func (s *emailChangeStore) Create(ctx context.Context, uid int64, newEmail, hash string, expires, now time.Time) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO email_changes (user_id, new_email, token_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, uid, newEmail, hash, expires, now)
+	return err
+}
+
+func (s *emailChangeStore) Pending(ctx context.Context, uid int64, now time.Time) (string, error) {
+	var email string
+	err := s.db.QueryRowContext(ctx, `
+		SELECT new_email FROM email_changes
+		 WHERE user_id = $1 AND used_at IS NULL AND expires_at > $2
+		 ORDER BY created_at DESC, id DESC
+		 LIMIT 1
+	`, uid, now).Scan(&email)
+	return email, notFound(err)
+}
+
+// Consume: el primer UPDATE reclama el token de forma atómica (dos clics a la
+// vez no lo usan dos veces); el segundo invalida los enlaces anteriores.
+func (s *emailChangeStore) Consume(ctx context.Context, hash string, now time.Time) (int64, string, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, "", err
+	}
+	defer tx.Rollback()
+
+	var uid int64
+	var email string
+	err = tx.QueryRowContext(ctx, `
+		UPDATE email_changes SET used_at = $2
+		 WHERE token_hash = $1 AND used_at IS NULL AND expires_at > $2
+		RETURNING user_id, new_email
+	`, hash, now).Scan(&uid, &email)
+	if err != nil {
+		return 0, "", notFound(err)
+	}
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE email_changes SET used_at = $2 WHERE user_id = $1 AND used_at IS NULL
+	`, uid, now); err != nil {
+		return 0, "", err
+	}
+	return uid, email, tx.Commit()
+}
@@ -0,0 +1,47 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type loginAttemptStore struct{ db *sql.DB }
+
+func (s *loginAttemptStore) Fail(ctx context.Context, key string, at time.Time) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO login_failures (key, failed_at) VALUES ($1, $2)`, key, at)
+	return err
+}
+
+func (s *loginAttemptStore) Recent(ctx context.Context, key string, since time.Time, limit int) ([]time.Time, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT failed_at FROM login_failures
+		 WHERE key = $1 AND failed_at >= $2
+		 ORDER BY failed_at DESC
+		 LIMIT $3
+	`, key, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []time.Time
+	for rows.Next() {
+		var t time.Time
+		if err := rows.Scan(&t); err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
+func (s *loginAttemptStore) Clear(ctx context.Context, key string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM login_failures WHERE key = $1`, key)
+	return err
+}
+
+func (s *loginAttemptStore) Prune(ctx context.Context, before time.Time) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM login_failures WHERE failed_at < $1`, before)
+	return err
+}
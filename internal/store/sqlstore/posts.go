@@ -0,0 +1,251 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"forum/internal/models"
+	"forum/internal/store"
+)
+
+type postStore struct{ db *sql.DB }
+
+const postSelect = `
+SELECT
+  p.id, p.user_id, p.title, p.content, u.username,
+  COUNT(*) FILTER (WHERE r.value = 1)  AS likes,
+  COUNT(*) FILTER (WHERE r.value = -1) AS dislikes,
+  p.created_at, p.updated_at, p.locked_at, p.pinned_at
+FROM posts p
+JOIN users u ON u.id = p.user_id
+LEFT JOIN reactions r
+  ON r.target_type = 'post'
+ AND r.target_id  = p.id
+`
+
+// En Postgres deben agruparse TODOS los no agregados.
+const postGroupBy = `
+GROUP BY p.id, p.user_id, p.title, p.content, u.username, p.created_at, p.updated_at, p.locked_at, p.pinned_at
+`
+
+func scanPost(sc scanner) (models.Post, error) {
+	var p models.Post
+	var updated, locked, pinned sql.NullTime
+	if err := sc.Scan(&p.ID, &p.UserID, &p.Title, &p.Content, &p.Author, &p.Likes, &p.Dislikes, &p.CreatedAt, &updated, &locked, &pinned); err != nil {
+		return models.Post{}, err
+	}
+	p.UpdatedAt = timePtr(updated)
+	p.LockedAt = timePtr(locked)
+	p.PinnedAt = timePtr(pinned)
+	return p, nil
+}
+
+func (s *postStore) List(ctx context.Context, q store.PostQuery) ([]models.Post, bool, error) {
+	var (
+		args []any
+		sb   strings.Builder
+	)
+	// helper para numerar $1, $2, ...
+	nextArg := func() string { return fmt.Sprintf("$%d", len(args)+1) }
+
+	sb.WriteString(postSelect)
+	sb.WriteString("WHERE 1=1\n")
+
+	if q.Category != "" {
+		sb.WriteString(`
+  AND EXISTS (
+        SELECT 1
+          FROM post_categories pc
+          JOIN categories c ON c.id = pc.category_id
+         WHERE pc.post_id = p.id
+           AND c.name = ` + nextArg() + `
+      )
+`)
+		args = append(args, q.Category)
+	}
+	if q.AuthorID != 0 {
+		sb.WriteString("  AND p.user_id = " + nextArg() + " ")
+		args = append(args, q.AuthorID)
+	}
+	if q.LikedBy != 0 {
+		sb.WriteString(`
+  AND EXISTS (
+        SELECT 1
+          FROM reactions rx
+         WHERE rx.user_id     = ` + nextArg() + `
+           AND rx.target_type = 'post'
+           AND rx.target_id   = p.id
+           AND rx.value       = 1
+      )
+`)
+		args = append(args, q.LikedBy)
+	}
+
+	switch q.Pinned {
+	case store.PinOnly:
+		sb.WriteString("  AND p.pinned_at IS NOT NULL\n")
+	case store.PinExclude:
+		sb.WriteString("  AND p.pinned_at IS NULL\n")
+	}
+
+	// Keyset: (created_at, id) estrictamente menor/mayor que el cursor
+	order := "DESC"
+	if c := q.After; c != nil {
+		sb.WriteString("  AND (p.created_at, p.id) < (" + nextArg())
+		args = append(args, c.CreatedAt)
+		sb.WriteString(", " + nextArg() + ")\n")
+		args = append(args, c.ID)
+	}
+	if c := q.Before; c != nil {
+		// hacia atrás: orden ascendente y luego se invierte
+		order = "ASC"
+		sb.WriteString("  AND (p.created_at, p.id) > (" + nextArg())
+		args = append(args, c.CreatedAt)
+		sb.WriteString(", " + nextArg() + ")\n")
+		args = append(args, c.ID)
+	}
+
+	// Se pide una fila de más para saber si hay otra página.
+	sb.WriteString(postGroupBy)
+	sb.WriteString("ORDER BY p.created_at " + order + ", p.id " + order + "\nLIMIT " + nextArg() + "\n")
+	args = append(args, q.Limit+1)
+
+	rows, err := s.db.QueryContext(ctx, sb.String(), args...)
+	if err != nil {
+		return nil, false, fmt.Errorf("posts query: %w", err)
+	}
+	defer rows.Close()
+
+	var posts []models.Post
+	for rows.Next() {
+		p, err := scanPost(rows)
+		if err != nil {
+			return nil, false, fmt.Errorf("posts scan: %w", err)
+		}
+		posts = append(posts, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, fmt.Errorf("posts err: %w", err)
+	}
+
+	more := len(posts) > q.Limit
+	if more {
+		posts = posts[:q.Limit]
+	}
+	if q.Before != nil {
+		slices.Reverse(posts)
+	}
+	return posts, more, nil
+}
+
+func (s *postStore) Get(ctx context.Context, id int64) (models.Post, error) {
+	p, err := scanPost(s.db.QueryRowContext(ctx, postSelect+"WHERE p.id = $1\n"+postGroupBy, id))
+	if err != nil {
+		return models.Post{}, notFound(err)
+	}
+	return p, nil
+}
+
+func (s *postStore) Create(ctx context.Context, p models.Post, categories []string) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// 1) Crear post y obtener id (PG: RETURNING)
+	var pid int64
+	if err := tx.QueryRowContext(ctx,
+		`INSERT INTO posts (user_id, title, content, created_at)
+         VALUES ($1,$2,$3,$4)
+         RETURNING id`,
+		p.UserID, p.Title, p.Content, p.CreatedAt,
+	).Scan(&pid); err != nil {
+		return 0, err
+	}
+
+	// 2) Asegurar categorías y vincular (PG: ON CONFLICT DO NOTHING)
+	for _, name := range categories {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		var cid int64
+		// Intentamos insertar; si ya existe, no devuelve fila (ErrNoRows).
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO categories (name)
+			VALUES ($1)
+			ON CONFLICT (name) DO NOTHING
+			RETURNING id
+		`, name).Scan(&cid)
+
+		if err == sql.ErrNoRows {
+			// Ya existía: recupera su id
+			if e2 := tx.QueryRowContext(ctx, `SELECT id FROM categories WHERE name=$1`, name).Scan(&cid); e2 != nil {
+				// si no podemos obtenerla, saltamos esta categoría
+				log.Printf("skip category %q: %v", name, e2)
+				continue
+			}
+		} else if err != nil {
+			// error real al intentar crear categoría
+			log.Printf("insert category %q err: %v", name, err)
+			continue
+		}
+
+		// Vincular post-categoría; PK (post_id,category_id) evita duplicados
+		if _, e3 := tx.ExecContext(ctx, `
+            INSERT INTO post_categories (post_id, category_id)
+            VALUES ($1,$2)
+            ON CONFLICT DO NOTHING
+        `, pid, cid); e3 != nil {
+			log.Printf("link post-category err: %v", e3)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return pid, nil
+}
+
+func (s *postStore) Update(ctx context.Context, id int64, title, content string, at time.Time) error {
+	return exec1(ctx, s.db, `
+UPDATE posts SET title = $1, content = $2, updated_at = $3 WHERE id = $4
+`, title, content, at, id)
+}
+
+func (s *postStore) SetLocked(ctx context.Context, id int64, at *time.Time) error {
+	return exec1(ctx, s.db, `UPDATE posts SET locked_at = $1 WHERE id = $2`, nullTime(at), id)
+}
+
+func (s *postStore) SetPinned(ctx context.Context, id int64, at *time.Time) error {
+	return exec1(ctx, s.db, `UPDATE posts SET pinned_at = $1 WHERE id = $2`, nullTime(at), id)
+}
+
+// Delete borra el post; comentarios y post_categories caen por CASCADE,
+// pero las reacciones (sin FK) hay que borrarlas a mano.
+func (s *postStore) Delete(ctx context.Context, id int64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+DELETE FROM reactions
+ WHERE (target_type = 'post' AND target_id = $1)
+    OR (target_type = 'comment' AND target_id IN (SELECT id FROM comments WHERE post_id = $1))
+`, id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM posts WHERE id = $1`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,100 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"forum/internal/db"
+	"forum/internal/store"
+)
+
+type reactionStore struct {
+	db      *sql.DB
+	dialect db.Dialect
+}
+
+func (s *reactionStore) Set(ctx context.Context, uid int64, target string, id int64, value int) error {
+	_, err := s.write(ctx, uid, target, id, value, false)
+	return err
+}
+
+func (s *reactionStore) Toggle(ctx context.Context, uid int64, target string, id int64, value int) (int, error) {
+	return s.write(ctx, uid, target, id, value, true)
+}
+
+// write comprueba que el objetivo existe (reactions.target_id no tiene FK) y
+// guarda o cambia la reacción. Con toggle, repetir el mismo valor la borra.
+func (s *reactionStore) write(ctx context.Context, uid int64, target string, id int64, value int, toggle bool) (int, error) {
+	table := "posts"
+	if target == "comment" {
+		table = "comments"
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM `+table+` WHERE id = $1)`, id).Scan(&exists); err != nil {
+		return 0, err
+	}
+	if !exists {
+		return 0, store.ErrNotFound
+	}
+
+	if toggle {
+		res, err := tx.ExecContext(ctx, `
+DELETE FROM reactions
+ WHERE user_id = $1 AND target_type = $2 AND target_id = $3 AND value = $4
+`, uid, target, id, value)
+		if err != nil {
+			return 0, err
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			return 0, tx.Commit()
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+INSERT INTO reactions (user_id,target_type,target_id,value) VALUES ($1,$2,$3,$4)
+ON CONFLICT(user_id,target_type,target_id) DO UPDATE SET value=excluded.value
+`, uid, target, id, value); err != nil {
+		return 0, err
+	}
+	return value, tx.Commit()
+}
+
+func (s *reactionStore) Clear(ctx context.Context, uid int64, target string, id int64) error {
+	_, err := s.db.ExecContext(ctx, `
+DELETE FROM reactions WHERE user_id = $1 AND target_type = $2 AND target_id = $3
+`, uid, target, id)
+	return err
+}
+
+func (s *reactionStore) Mine(ctx context.Context, uid int64, target string, ids []int64) (map[int64]int, error) {
+	out := make(map[int64]int, len(ids))
+	if len(ids) == 0 {
+		return out, nil
+	}
+	in, args := inIDs(s.dialect, "target_id", ids, 3)
+	rows, err := s.db.QueryContext(ctx, `
+SELECT target_id, value
+  FROM reactions
+ WHERE user_id = $1 AND target_type = $2 AND `+in,
+		append([]any{uid, target}, args...)...)
+	if err != nil {
+		return nil, fmt.Errorf("reactions query: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		var v int
+		if err := rows.Scan(&id, &v); err != nil {
+			return nil, fmt.Errorf("reactions scan: %w", err)
+		}
+		out[id] = v
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,111 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"forum/internal/models"
+	"forum/internal/store"
+)
+
+type reportStore struct{ db *sql.DB }
+
+// Create comprueba que el contenido existe (target_id no tiene FK) y que el
+// usuario no lo tiene ya denunciado antes de insertar.
+func (s *reportStore) Create(ctx context.Context, r models.Report) (int64, error) {
+	table := "posts"
+	if r.Target == "comment" {
+		table = "comments"
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var exists, open bool
+	err = tx.QueryRowContext(ctx, `
+SELECT EXISTS (SELECT 1 FROM `+table+` WHERE id = $1),
+       EXISTS (SELECT 1 FROM reports
+                WHERE target_type = $2 AND target_id = $1 AND reporter_id = $3 AND status = 'open')
+`, r.TargetID, r.Target, r.ReporterID).Scan(&exists, &open)
+	if err != nil {
+		return 0, err
+	}
+	if !exists {
+		return 0, store.ErrNotFound
+	}
+	if open {
+		return 0, store.ErrAlreadyReported
+	}
+
+	var id int64
+	if err := tx.QueryRowContext(ctx, `
+INSERT INTO reports (target_type, target_id, reporter_id, reason, status, created_at)
+VALUES ($1, $2, $3, $4, 'open', $5)
+RETURNING id
+`, r.Target, r.TargetID, r.ReporterID, r.Reason, r.CreatedAt).Scan(&id); err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+// reportSelect trae la denuncia con quién la puso, quién la cerró y el
+// contenido denunciado (post directo, o comentario y su post).
+const reportSelect = `
+SELECT r.id, r.target_type, r.target_id, r.reporter_id, ru.username, r.reason, r.status, r.created_at,
+       r.handled_by, COALESCE(hu.username, ''), r.handled_at,
+       COALESCE(p.id, c.post_id, 0), COALESCE(p.title, cp.title, ''), COALESCE(p.content, c.content, ''),
+       COALESCE(au.id, 0), COALESCE(au.username, '')
+  FROM reports r
+  JOIN users ru ON ru.id = r.reporter_id
+  LEFT JOIN users hu ON hu.id = r.handled_by
+  LEFT JOIN posts p ON r.target_type = 'post' AND p.id = r.target_id
+  LEFT JOIN comments c ON r.target_type = 'comment' AND c.id = r.target_id
+  LEFT JOIN posts cp ON cp.id = c.post_id
+  LEFT JOIN users au ON au.id = COALESCE(p.user_id, c.user_id)`
+
+func scanReport(sc scanner) (models.Report, error) {
+	var r models.Report
+	var handledBy sql.NullInt64
+	var handledAt sql.NullTime
+	err := sc.Scan(&r.ID, &r.Target, &r.TargetID, &r.ReporterID, &r.Reporter, &r.Reason, &r.Status, &r.CreatedAt,
+		&handledBy, &r.Handler, &handledAt,
+		&r.PostID, &r.PostTitle, &r.Content, &r.AuthorID, &r.Author)
+	r.HandledBy = int64Ptr(handledBy)
+	r.HandledAt = timePtr(handledAt)
+	return r, err
+}
+
+func (s *reportStore) Get(ctx context.Context, id int64) (models.Report, error) {
+	r, err := scanReport(s.db.QueryRowContext(ctx, reportSelect+`
+ WHERE r.id = $1`, id))
+	return r, notFound(err)
+}
+
+func (s *reportStore) Open(ctx context.Context, limit int) ([]models.Report, error) {
+	rows, err := s.db.QueryContext(ctx, reportSelect+`
+ WHERE r.status = 'open'
+ ORDER BY r.created_at, r.id
+ LIMIT $1`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []models.Report
+	for rows.Next() {
+		r, err := scanReport(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+func (s *reportStore) Close(ctx context.Context, id int64, status string, by int64, at time.Time) error {
+	return exec1(ctx, s.db, `
+UPDATE reports SET status = $1, handled_by = $2, handled_at = $3
+ WHERE id = $4 AND status = 'open'`, status, by, at, id)
+}
@@ -0,0 +1,189 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"forum/internal/db"
+	"forum/internal/store"
+)
+
+type searchStore struct {
+	db      *sql.DB
+	dialect db.Dialect
+}
+
+// Opciones de ts_headline: las marcas de store y hasta dos fragmentos.
+const pgHeadline = "StartSel=" + store.HighlightStart + ", StopSel=" + store.HighlightEnd +
+	`, MaxWords=30, MinWords=12, MaxFragments=2, FragmentDelimiter=" … "`
+
+// Search junta posts y comentarios en una sola consulta (UNION ALL) y ordena
+// por relevancia. Postgres usa las columnas tsvector (ver migración 0006) y
+// SQLite las tablas FTS5; los filtros son el mismo SQL en los dos.
+func (s *searchStore) Search(ctx context.Context, q store.SearchQuery) ([]store.SearchHit, bool, error) {
+	if q.Limit <= 0 {
+		q.Limit = 20
+	}
+	include, exclude := store.Terms(q.Text)
+	hasText := len(include)+len(exclude) > 0
+
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	// Filtros comunes; item es el alias de la fila buscada (p o c).
+	filters := func(item string) string {
+		var sb strings.Builder
+		if q.Author != "" {
+			sb.WriteString(" AND u.username = " + arg(q.Author))
+		}
+		if q.Category != "" {
+			sb.WriteString(` AND EXISTS (
+        SELECT 1 FROM post_categories pc JOIN categories cat ON cat.id = pc.category_id
+         WHERE pc.post_id = p.id AND cat.name = ` + arg(q.Category) + `)`)
+		}
+		if !q.From.IsZero() {
+			sb.WriteString(" AND " + item + ".created_at >= " + arg(q.From))
+		}
+		if !q.To.IsZero() {
+			sb.WriteString(" AND " + item + ".created_at < " + arg(q.To))
+		}
+		return sb.String()
+	}
+
+	var posts, comments string
+	switch {
+	case !hasText:
+		posts = `
+SELECT 'post' AS kind, p.id AS post_id, 0 AS comment_id, p.title AS title, u.username AS author,
+       substr(p.content, 1, 200) AS snippet, p.created_at AS created_at, 0 AS rank
+  FROM posts p
+  JOIN users u ON u.id = p.user_id
+ WHERE 1 = 1` + filters("p")
+		comments = `
+SELECT 'comment', c.post_id, c.id, p.title, u.username,
+       substr(c.content, 1, 200), c.created_at, 0
+  FROM comments c
+  JOIN posts p ON p.id = c.post_id
+  JOIN users u ON u.id = c.user_id
+ WHERE 1 = 1` + filters("c")
+
+	case s.dialect == db.Postgres:
+		tsq := "websearch_to_tsquery('english', " + arg(q.Text) + ")"
+		opts := arg(pgHeadline)
+		posts = `
+SELECT 'post' AS kind, p.id AS post_id, 0 AS comment_id, p.title AS title, u.username AS author,
+       ts_headline('english', p.content, q, ` + opts + `) AS snippet, p.created_at AS created_at,
+       ts_rank(p.search, q) AS rank
+  FROM posts p
+  JOIN users u ON u.id = p.user_id
+ CROSS JOIN ` + tsq + ` q
+ WHERE p.search @@ q` + filters("p")
+		comments = `
+SELECT 'comment', c.post_id, c.id, p.title, u.username,
+       ts_headline('english', c.content, q, ` + opts + `), c.created_at,
+       ts_rank(c.search, q)
+  FROM comments c
+  JOIN posts p ON p.id = c.post_id
+  JOIN users u ON u.id = c.user_id
+ CROSS JOIN ` + tsq + ` q
+ WHERE c.search @@ q` + filters("c")
+
+	default:
+		match, only := ftsMatch(include, exclude)
+		m := arg(match)
+		if only {
+			// Solo exclusiones: FTS5 no sabe negar sin más, así que se
+			// buscan los que sí las contienen y se quitan.
+			posts = `
+SELECT 'post' AS kind, p.id AS post_id, 0 AS comment_id, p.title AS title, u.username AS author,
+       substr(p.content, 1, 200) AS snippet, p.created_at AS created_at, 0 AS rank
+  FROM posts p
+  JOIN users u ON u.id = p.user_id
+ WHERE p.id NOT IN (SELECT rowid FROM posts_fts WHERE posts_fts MATCH ` + m + `)` + filters("p")
+			comments = `
+SELECT 'comment', c.post_id, c.id, p.title, u.username,
+       substr(c.content, 1, 200), c.created_at, 0
+  FROM comments c
+  JOIN posts p ON p.id = c.post_id
+  JOIN users u ON u.id = c.user_id
+ WHERE c.id NOT IN (SELECT rowid FROM comments_fts WHERE comments_fts MATCH ` + m + `)` + filters("c")
+			break
+		}
+		hl := arg(store.HighlightStart)
+		hr := arg(store.HighlightEnd)
+		// bm25 es menor cuanto más relevante: se cambia el signo. El título pesa más.
+		posts = `
+SELECT 'post' AS kind, p.id AS post_id, 0 AS comment_id, p.title AS title, u.username AS author,
+       snippet(posts_fts, 1, ` + hl + `, ` + hr + `, '…', 24) AS snippet, p.created_at AS created_at,
+       -bm25(posts_fts, 4.0, 1.0) AS rank
+  FROM posts_fts
+  JOIN posts p ON p.id = posts_fts.rowid
+  JOIN users u ON u.id = p.user_id
+ WHERE posts_fts MATCH ` + m + filters("p")
+		comments = `
+SELECT 'comment', c.post_id, c.id, p.title, u.username,
+       snippet(comments_fts, 0, ` + hl + `, ` + hr + `, '…', 24), c.created_at,
+       -bm25(comments_fts)
+  FROM comments_fts
+  JOIN comments c ON c.id = comments_fts.rowid
+  JOIN posts p ON p.id = c.post_id
+  JOIN users u ON u.id = c.user_id
+ WHERE comments_fts MATCH ` + m + filters("c")
+	}
+
+	query := posts + "\nUNION ALL" + comments + `
+ ORDER BY rank DESC, created_at DESC, post_id DESC, comment_id DESC
+ LIMIT ` + arg(q.Limit+1) + ` OFFSET ` + arg(q.Offset)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, false, fmt.Errorf("search query: %w", err)
+	}
+	defer rows.Close()
+
+	var hits []store.SearchHit
+	for rows.Next() {
+		var h store.SearchHit
+		var rank float64
+		if err := rows.Scan(&h.Kind, &h.PostID, &h.CommentID, &h.Title, &h.Author, &h.Snippet, &h.CreatedAt, &rank); err != nil {
+			return nil, false, fmt.Errorf("search scan: %w", err)
+		}
+		hits = append(hits, h)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, fmt.Errorf("search err: %w", err)
+	}
+	more := len(hits) > q.Limit
+	if more {
+		hits = hits[:q.Limit]
+	}
+	return hits, more, nil
+}
+
+// ftsMatch traduce los términos a una expresión MATCH de FTS5: cada término
+// entre comillas (frase) y unidos por AND implícito. Si solo hay exclusiones
+// devuelve su OR con only = true (ver Search).
+func ftsMatch(include, exclude []string) (match string, only bool) {
+	quote := func(t string) string { return `"` + strings.ReplaceAll(t, `"`, `""`) + `"` }
+	if len(include) == 0 {
+		parts := make([]string, len(exclude))
+		for i, t := range exclude {
+			parts[i] = quote(t)
+		}
+		return strings.Join(parts, " OR "), true
+	}
+	parts := make([]string, len(include))
+	for i, t := range include {
+		parts[i] = quote(t)
+	}
+	match = strings.Join(parts, " ")
+	for _, t := range exclude {
+		match += " NOT " + quote(t)
+	}
+	return match, false
+}
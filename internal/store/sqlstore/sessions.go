@@ -0,0 +1,128 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"forum/internal/models"
+	"forum/internal/store"
+)
+
+type sessionStore struct{ db *sql.DB }
+
+const sessionCols = `id, user_id, csrf_token, user_agent, ip, persistent, role, last_seen_at, expires_at, created_at`
+
+func scanSession(row scanner) (models.Session, error) {
+	var ses models.Session
+	var seen sql.NullTime
+	err := row.Scan(&ses.ID, &ses.UserID, &ses.CSRFToken, &ses.UserAgent, &ses.IP, &ses.Persistent, &ses.Role, &seen, &ses.ExpiresAt, &ses.CreatedAt)
+	// Las sesiones anteriores a 0013 no tienen last_seen_at
+	ses.LastSeenAt = ses.CreatedAt
+	if seen.Valid {
+		ses.LastSeenAt = seen.Time
+	}
+	return ses, err
+}
+
+// execer: *sql.DB o *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func insertSession(ctx context.Context, db execer, ses models.Session) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO sessions (id, user_id, csrf_token, user_agent, ip, persistent, role, last_seen_at, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`, ses.ID, ses.UserID, ses.CSRFToken, ses.UserAgent, ses.IP, ses.Persistent, ses.Role, ses.LastSeenAt, ses.ExpiresAt, ses.CreatedAt)
+	return err
+}
+
+func (s *sessionStore) Create(ctx context.Context, ses models.Session) error {
+	return insertSession(ctx, s.db, ses)
+}
+
+func (s *sessionStore) Get(ctx context.Context, id string) (models.Session, error) {
+	ses, err := scanSession(s.db.QueryRowContext(ctx,
+		`SELECT `+sessionCols+` FROM sessions WHERE id = $1`, id))
+	if err != nil {
+		return models.Session{}, notFound(err)
+	}
+	return ses, nil
+}
+
+func (s *sessionStore) ListForUser(ctx context.Context, uid int64, now time.Time) ([]models.Session, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+sessionCols+` FROM sessions
+		WHERE user_id = $1 AND expires_at > $2
+		ORDER BY COALESCE(last_seen_at, created_at) DESC, id
+	`, uid, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []models.Session
+	for rows.Next() {
+		ses, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, ses)
+	}
+	return out, rows.Err()
+}
+
+func (s *sessionStore) Touch(ctx context.Context, id, ip string, at time.Time) error {
+	return exec1(ctx, s.db, `UPDATE sessions SET last_seen_at = $1, ip = $2 WHERE id = $3`, at, ip, id)
+}
+
+func (s *sessionStore) Renew(ctx context.Context, id string, expiresAt time.Time) error {
+	return exec1(ctx, s.db, `UPDATE sessions SET expires_at = $1 WHERE id = $2`, expiresAt, id)
+}
+
+func (s *sessionStore) Rotate(ctx context.Context, oldID string, ses models.Session) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM sessions WHERE id = $1`, oldID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return store.ErrNotFound
+	}
+	if err := insertSession(ctx, tx, ses); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *sessionStore) Delete(ctx context.Context, id string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM sessions WHERE id = $1`, id)
+	return err
+}
+
+func (s *sessionStore) DeleteForUser(ctx context.Context, uid int64) error {
+	return deleteSessions(ctx, s.db, uid, "")
+}
+
+func (s *sessionStore) DeleteOthers(ctx context.Context, uid int64, keep string) error {
+	return deleteSessions(ctx, s.db, uid, keep)
+}
+
+// deleteSessions borra las sesiones de uid salvo keep ("" = todas).
+func deleteSessions(ctx context.Context, db execer, uid int64, keep string) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM sessions WHERE user_id = $1 AND id <> $2`, uid, keep)
+	return err
+}
+
+func (s *sessionStore) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM sessions WHERE expires_at <= $1`, now)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
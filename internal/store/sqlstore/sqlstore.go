@@ -0,0 +1,101 @@
+// Package sqlstore implementa los repositorios de store sobre Postgres.
+package sqlstore
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"forum/internal/db"
+	"forum/internal/store"
+)
+
+// New construye todos los repositorios sobre la misma conexión. El SQL es
+// común a Postgres y SQLite salvo donde se indica (ver inIDs y searchStore).
+func New(d *sql.DB) *store.Store {
+	dialect := db.DialectOf(d)
+	return &store.Store{
+		Users:      &userStore{db: d},
+		Sessions:   &sessionStore{db: d},
+		Tokens:     &tokenStore{db: d},
+		APITokens:  &apiTokenStore{db: d},
+		Posts:      &postStore{db: d},
+		Comments:   &commentStore{db: d, dialect: dialect},
+		Categories: &categoryStore{db: d, dialect: dialect},
+		Reactions:  &reactionStore{db: d, dialect: dialect},
+		Search:     &searchStore{db: d, dialect: dialect},
+		Reports:    &reportStore{db: d},
+		Audit:      &auditStore{db: d},
+
+		LoginAttempts: &loginAttemptStore{db: d},
+		TwoFactor:     &twoFactorStore{db: d},
+		EmailChanges:  &emailChangeStore{db: d},
+	}
+}
+
+/* =========================
+   Helpers
+   ========================= */
+
+// notFound traduce sql.ErrNoRows al error del paquete store.
+func notFound(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return store.ErrNotFound
+	}
+	return err
+}
+
+// isUniqueErr detecta la violación de un UNIQUE de una sola columna por el
+// mensaje. Postgres nombra los UNIQUE implícitos tabla_columna_key
+// (users_email_key); SQLite dice "UNIQUE constraint failed: users.email".
+func isUniqueErr(err error, table, column string) bool {
+	if err == nil {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	if strings.Contains(msg, "duplicate key value violates unique constraint") {
+		return strings.Contains(msg, table+"_"+column+"_key")
+	}
+	return strings.Contains(msg, "unique constraint failed: "+table+"."+column)
+}
+
+// inIDs devuelve la condición "col está en ids" numerando los parámetros
+// desde $first. Postgres recibe el array en un solo parámetro; SQLite no
+// tiene arrays y usa IN ($n, $n+1, ...). En ambos casos es una consulta.
+func inIDs(dialect db.Dialect, col string, ids []int64, first int) (string, []any) {
+	if dialect == db.Postgres {
+		return fmt.Sprintf("%s = ANY($%d)", col, first), []any{ids}
+	}
+	ph := make([]string, len(ids))
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		ph[i] = fmt.Sprintf("$%d", first+i)
+		args[i] = id
+	}
+	return col + " IN (" + strings.Join(ph, ", ") + ")", args
+}
+
+func timePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	v := t.Time
+	return &v
+}
+
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: *t, Valid: true}
+}
+
+func int64Ptr(n sql.NullInt64) *int64 {
+	if !n.Valid {
+		return nil
+	}
+	v := n.Int64
+	return &v
+}
@@ -0,0 +1,18 @@
+package sqlstore_test
+
+import (
+	"testing"
+
+	"forum/internal/store/storetest"
+)
+
+func TestSQLite(t *testing.T) {
+	storetest.Run(t, storetest.SQLite)
+}
+
+// Necesita un Postgres de usar y tirar:
+//
+//	FORUM_TEST_DATABASE_URL=postgres://... go test ./internal/store/sqlstore
+func TestPostgres(t *testing.T) {
+	storetest.Run(t, storetest.Postgres)
+}
@@ -0,0 +1,175 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"forum/internal/models"
+	"forum/internal/store"
+)
+
+type tokenStore struct{ db *sql.DB }
+
+// tokenTable devuelve la tabla de cada tipo de token de un solo uso.
+func tokenTable(kind store.TokenKind) (string, error) {
+	switch kind {
+	case store.TokenPasswordReset:
+		return "password_reset_tokens", nil
+	case store.TokenEmailVerify:
+		return "email_verification_tokens", nil
+	case store.TokenLoginChallenge:
+		return "login_challenges", nil
+	}
+	return "", fmt.Errorf("sqlstore: unknown token kind %q", kind)
+}
+
+func (s *tokenStore) Create(ctx context.Context, kind store.TokenKind, uid int64, hash string, expires, now time.Time) error {
+	table, err := tokenTable(kind)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO `+table+` (user_id, token_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, $4)
+	`, uid, hash, expires, now)
+	return err
+}
+
+// Consume marca en una sola sentencia el token y el resto de pendientes del
+// usuario; dos peticiones concurrentes con el mismo token no pueden ganar ambas.
+func (s *tokenStore) Consume(ctx context.Context, kind store.TokenKind, hash string, now time.Time) (int64, error) {
+	table, err := tokenTable(kind)
+	if err != nil {
+		return 0, err
+	}
+	return consumeToken(ctx, s.db, table, hash, now)
+}
+
+// ConsumeAndReset: si algo falla a medias el enlace sigue valiendo y nada cambia.
+func (s *tokenStore) ConsumeAndReset(ctx context.Context, hash, passwordHash string, now time.Time) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	uid, err := consumeToken(ctx, tx, "password_reset_tokens", hash, now)
+	if err != nil {
+		return 0, err
+	}
+	if err := changePassword(ctx, tx, uid, passwordHash, ""); err != nil {
+		return 0, err
+	}
+	return uid, tx.Commit()
+}
+
+type rowQuerier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func consumeToken(ctx context.Context, db rowQuerier, table, hash string, now time.Time) (int64, error) {
+	var uid int64
+	err := db.QueryRowContext(ctx, `
+		WITH t AS (
+		  SELECT user_id FROM `+table+`
+		   WHERE token_hash = $1 AND used_at IS NULL AND expires_at > $2
+		)
+		UPDATE `+table+` SET used_at = $2
+		 WHERE user_id = (SELECT user_id FROM t) AND used_at IS NULL
+		RETURNING user_id
+	`, hash, now).Scan(&uid)
+	if err != nil {
+		return 0, notFound(err)
+	}
+	return uid, nil
+}
+
+func (s *tokenStore) Lookup(ctx context.Context, kind store.TokenKind, hash string, now time.Time) (int64, error) {
+	table, err := tokenTable(kind)
+	if err != nil {
+		return 0, err
+	}
+	var uid int64
+	err = s.db.QueryRowContext(ctx, `
+		SELECT user_id FROM `+table+`
+		 WHERE token_hash = $1 AND used_at IS NULL AND expires_at > $2
+	`, hash, now).Scan(&uid)
+	if err != nil {
+		return 0, notFound(err)
+	}
+	return uid, nil
+}
+
+type apiTokenStore struct{ db *sql.DB }
+
+func (s *apiTokenStore) Create(ctx context.Context, t models.APIToken, hash string) (int64, error) {
+	var id int64
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO api_tokens (user_id, name, token_hash, scopes, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`, t.UserID, t.Name, hash, strings.Join(t.Scopes, ","), t.CreatedAt).Scan(&id)
+	return id, err
+}
+
+func (s *apiTokenStore) ListForUser(ctx context.Context, uid int64) ([]models.APIToken, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, user_id, name, scopes, last_used_at, created_at
+		  FROM api_tokens
+		 WHERE user_id = $1
+		 ORDER BY created_at DESC, id DESC
+	`, uid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.APIToken
+	for rows.Next() {
+		t, err := scanAPIToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
+func (s *apiTokenStore) Delete(ctx context.Context, uid, id int64) error {
+	return exec1(ctx, s.db, `DELETE FROM api_tokens WHERE id = $1 AND user_id = $2`, id, uid)
+}
+
+// deleteAPITokens borra todos los tokens personales de uid.
+func deleteAPITokens(ctx context.Context, db execer, uid int64) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM api_tokens WHERE user_id = $1`, uid)
+	return err
+}
+
+// Use valida el token y actualiza last_used_at en la misma consulta.
+func (s *apiTokenStore) Use(ctx context.Context, hash string, now time.Time) (models.APIToken, error) {
+	t, err := scanAPIToken(s.db.QueryRowContext(ctx, `
+		UPDATE api_tokens SET last_used_at = $2
+		 WHERE token_hash = $1
+		RETURNING id, user_id, name, scopes, last_used_at, created_at
+	`, hash, now))
+	return t, notFound(err)
+}
+
+type scanner interface{ Scan(dest ...any) error }
+
+func scanAPIToken(sc scanner) (models.APIToken, error) {
+	var t models.APIToken
+	var scopes string
+	var used sql.NullTime
+	if err := sc.Scan(&t.ID, &t.UserID, &t.Name, &scopes, &used, &t.CreatedAt); err != nil {
+		return models.APIToken{}, err
+	}
+	if scopes != "" {
+		t.Scopes = strings.Split(scopes, ",")
+	}
+	t.LastUsedAt = timePtr(used)
+	return t, nil
+}
@@ -0,0 +1,121 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"forum/internal/models"
+	"forum/internal/store"
+)
+
+type twoFactorStore struct{ db *sql.DB }
+
+func (s *twoFactorStore) Get(ctx context.Context, uid int64) (models.TwoFactor, error) {
+	tf := models.TwoFactor{UserID: uid}
+	var enabled sql.NullTime
+	err := s.db.QueryRowContext(ctx, `
+		SELECT secret, enabled_at, last_step, created_at,
+		       (SELECT COUNT(*) FROM recovery_codes r WHERE r.user_id = t.user_id AND r.used_at IS NULL)
+		  FROM user_totp t
+		 WHERE user_id = $1
+	`, uid).Scan(&tf.Secret, &enabled, &tf.LastStep, &tf.CreatedAt, &tf.CodesLeft)
+	if err != nil {
+		return models.TwoFactor{}, notFound(err)
+	}
+	tf.EnabledAt = timePtr(enabled)
+	return tf, nil
+}
+
+// Begin: el WHERE del upsert deja intacto un TOTP ya activo (0 filas).
+func (s *twoFactorStore) Begin(ctx context.Context, uid int64, secret string, at time.Time) error {
+	return exec1(ctx, s.db, `
+		INSERT INTO user_totp (user_id, secret, created_at) VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO UPDATE SET secret = excluded.secret, last_step = 0, created_at = excluded.created_at
+		 WHERE user_totp.enabled_at IS NULL
+	`, uid, secret, at)
+}
+
+func (s *twoFactorStore) Enable(ctx context.Context, uid, step int64, codeHashes []string, at time.Time) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `
+		UPDATE user_totp SET enabled_at = $2, last_step = $3
+		 WHERE user_id = $1 AND enabled_at IS NULL
+	`, uid, at, step)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return store.ErrNotFound
+	}
+	if err := replaceCodes(ctx, tx, uid, codeHashes, at); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *twoFactorStore) Disable(ctx context.Context, uid int64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, uid); err != nil {
+		return err
+	}
+	res, err := tx.ExecContext(ctx, `DELETE FROM user_totp WHERE user_id = $1`, uid)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return store.ErrNotFound
+	}
+	return tx.Commit()
+}
+
+// UseStep es atómico: dos logins con el mismo código no pueden ganar ambos.
+func (s *twoFactorStore) UseStep(ctx context.Context, uid, step int64) error {
+	return exec1(ctx, s.db, `
+		UPDATE user_totp SET last_step = $2
+		 WHERE user_id = $1 AND enabled_at IS NOT NULL AND last_step < $2
+	`, uid, step)
+}
+
+func (s *twoFactorStore) UseRecoveryCode(ctx context.Context, uid int64, hash string, at time.Time) error {
+	return exec1(ctx, s.db, `
+		UPDATE recovery_codes SET used_at = $3
+		 WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	`, uid, hash, at)
+}
+
+func (s *twoFactorStore) ReplaceRecoveryCodes(ctx context.Context, uid int64, hashes []string, at time.Time) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := replaceCodes(ctx, tx, uid, hashes, at); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func replaceCodes(ctx context.Context, tx *sql.Tx, uid int64, hashes []string, at time.Time) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, uid); err != nil {
+		return err
+	}
+	for _, h := range hashes {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO recovery_codes (user_id, code_hash, created_at) VALUES ($1, $2, $3)
+		`, uid, h, at); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"forum/internal/models"
+	"forum/internal/store"
+)
+
+type userStore struct{ db *sql.DB }
+
+func (s *userStore) Create(ctx context.Context, u models.User) (int64, error) {
+	role := u.Role
+	if role == "" {
+		role = "member"
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Los nombres antiguos siguen siendo de su dueño (ver SetUsername)
+	if taken, err := inHistory(ctx, tx, u.Username, 0); err != nil {
+		return 0, err
+	} else if taken {
+		return 0, store.ErrUsernameTaken
+	}
+	var id int64
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO users (email, username, password_hash, role, email_verified_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`, u.Email, u.Username, u.PasswordHash, role, nullTime(u.EmailVerifiedAt), u.CreatedAt).Scan(&id)
+	if isUniqueErr(err, "users", "email") {
+		return 0, store.ErrEmailTaken
+	}
+	if isUniqueErr(err, "users", "username") {
+		return 0, store.ErrUsernameTaken
+	}
+	if err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+// inHistory dice si username fue de un usuario distinto de uid.
+func inHistory(ctx context.Context, tx *sql.Tx, username string, uid int64) (bool, error) {
+	var taken bool
+	err := tx.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM username_history WHERE username = $1 AND user_id <> $2)
+	`, username, uid).Scan(&taken)
+	return taken, err
+}
+
+const userCols = `id, email, username, password_hash, role, email_verified_at, created_at,
+       banned_at, suspended_until, ban_reason, muted_until, mute_reason`
+
+func (s *userStore) one(ctx context.Context, where string, arg any) (models.User, error) {
+	var u models.User
+	var verified, banned, suspended, muted sql.NullTime
+	err := s.db.QueryRowContext(ctx, `SELECT `+userCols+` FROM users WHERE `+where+` = $1`, arg).
+		Scan(&u.ID, &u.Email, &u.Username, &u.PasswordHash, &u.Role, &verified, &u.CreatedAt,
+			&banned, &suspended, &u.BanReason, &muted, &u.MuteReason)
+	if err != nil {
+		return models.User{}, notFound(err)
+	}
+	u.EmailVerifiedAt = timePtr(verified)
+	u.BannedAt, u.SuspendedUntil, u.MutedUntil = timePtr(banned), timePtr(suspended), timePtr(muted)
+	return u, nil
+}
+
+func (s *userStore) ByID(ctx context.Context, id int64) (models.User, error) {
+	return s.one(ctx, "id", id)
+}
+
+func (s *userStore) ByEmail(ctx context.Context, email string) (models.User, error) {
+	return s.one(ctx, "email", email)
+}
+
+func (s *userStore) ByUsername(ctx context.Context, username string) (models.User, error) {
+	return s.one(ctx, "username", username)
+}
+
+func (s *userStore) ChangePassword(ctx context.Context, id int64, hash, keepSession string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := changePassword(ctx, tx, id, hash, keepSession); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// changePassword es ChangePassword dentro de una transacción ya abierta.
+func changePassword(ctx context.Context, tx *sql.Tx, id int64, hash, keepSession string) error {
+	if err := exec1(ctx, tx, `UPDATE users SET password_hash = $1 WHERE id = $2`, hash, id); err != nil {
+		return err
+	}
+	if err := deleteSessions(ctx, tx, id, keepSession); err != nil {
+		return err
+	}
+	return deleteAPITokens(ctx, tx, id)
+}
+
+func (s *userStore) SetEmail(ctx context.Context, id int64, email string, verifiedAt time.Time) error {
+	err := exec1(ctx, s.db, `UPDATE users SET email = $1, email_verified_at = $2 WHERE id = $3`, email, verifiedAt, id)
+	if isUniqueErr(err, "users", "email") {
+		return store.ErrEmailTaken
+	}
+	return err
+}
+
+func (s *userStore) SetUsername(ctx context.Context, id int64, username string, at time.Time) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var old string
+	if err := tx.QueryRowContext(ctx, `SELECT username FROM users WHERE id = $1`, id).Scan(&old); err != nil {
+		return notFound(err)
+	}
+	if old == username {
+		return nil
+	}
+	if taken, err := inHistory(ctx, tx, username, id); err != nil {
+		return err
+	} else if taken {
+		return store.ErrUsernameTaken
+	}
+	_, err = tx.ExecContext(ctx, `UPDATE users SET username = $1 WHERE id = $2`, username, id)
+	if isUniqueErr(err, "users", "username") {
+		return store.ErrUsernameTaken
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO username_history (user_id, username, changed_at) VALUES ($1, $2, $3)
+	`, id, old, at); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *userStore) PastUsernames(ctx context.Context, id int64) ([]models.UsernameChange, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT username, changed_at FROM username_history
+		 WHERE user_id = $1
+		 ORDER BY changed_at DESC, id DESC
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []models.UsernameChange
+	for rows.Next() {
+		var c models.UsernameChange
+		if err := rows.Scan(&c.Username, &c.ChangedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+func (s *userStore) SetRole(ctx context.Context, id int64, role string) error {
+	return exec1(ctx, s.db, `UPDATE users SET role = $1 WHERE id = $2`, role, id)
+}
+
+func (s *userStore) MarkEmailVerified(ctx context.Context, id int64, at time.Time) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE users SET email_verified_at = $1
+		 WHERE id = $2 AND email_verified_at IS NULL
+	`, at, id)
+	return err
+}
+
+func (s *userStore) SetBan(ctx context.Context, id int64, bannedAt, suspendedUntil *time.Time, reason string) error {
+	return exec1(ctx, s.db, `
+		UPDATE users SET banned_at = $1, suspended_until = $2, ban_reason = $3
+		 WHERE id = $4
+	`, nullTime(bannedAt), nullTime(suspendedUntil), reason, id)
+}
+
+func (s *userStore) SetMute(ctx context.Context, id int64, until *time.Time, reason string) error {
+	return exec1(ctx, s.db, `UPDATE users SET muted_until = $1, mute_reason = $2 WHERE id = $3`, nullTime(until), reason, id)
+}
+
+// exec1 ejecuta una sentencia que debe afectar a una fila; ErrNotFound si no.
+func exec1(ctx context.Context, db execer, q string, args ...any) error {
+	res, err := db.ExecContext(ctx, q, args...)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
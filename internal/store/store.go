@@ -0,0 +1,327 @@
+// Package store define los repositorios que usan los handlers y auth.
+// Hay dos implementaciones: sqlstore (Postgres y SQLite) y memstore (en memoria, para tests).
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"forum/internal/models"
+)
+
+var (
+	ErrNotFound      = errors.New("store: not found")
+	ErrEmailTaken    = errors.New("store: email already taken")
+	ErrUsernameTaken = errors.New("store: username already taken")
+	// ErrAlreadyReported: ese usuario ya tiene una denuncia abierta sobre el mismo contenido.
+	ErrAlreadyReported = errors.New("store: already reported")
+)
+
+// Store agrupa todos los repositorios.
+type Store struct {
+	Users      UserStore
+	Sessions   SessionStore
+	Tokens     TokenStore
+	APITokens  APITokenStore
+	Posts      PostStore
+	Comments   CommentStore
+	Categories CategoryStore
+	Reactions  ReactionStore
+	Search     SearchStore
+	Reports    ReportStore
+	Audit      AuditStore
+
+	LoginAttempts LoginAttemptStore
+	TwoFactor     TwoFactorStore
+	EmailChanges  EmailChangeStore
+}
+
+/* =========================
+   Usuarios y sesiones
+   ========================= */
+
+type UserStore interface {
+	// Create devuelve ErrEmailTaken / ErrUsernameTaken si chocan los UNIQUE;
+	// ErrUsernameTaken también si el nombre está en el historial de otro.
+	Create(ctx context.Context, u models.User) (int64, error)
+	ByID(ctx context.Context, id int64) (models.User, error)
+	ByEmail(ctx context.Context, email string) (models.User, error)
+	ByUsername(ctx context.Context, username string) (models.User, error)
+	// ChangePassword pone la contraseña, cierra las sesiones de id salvo
+	// keepSession ("" = todas) y borra sus tokens personales, todo o nada.
+	ChangePassword(ctx context.Context, id int64, hash, keepSession string) error
+	// SetEmail cambia el email, ya verificado en verifiedAt; ErrEmailTaken si
+	// es de otro usuario.
+	SetEmail(ctx context.Context, id int64, email string, verifiedAt time.Time) error
+	// SetUsername cambia el nombre y guarda el anterior en el historial.
+	// ErrUsernameTaken si otro usuario lo usa o lo usó.
+	SetUsername(ctx context.Context, id int64, username string, at time.Time) error
+	// PastUsernames devuelve los nombres anteriores de id, el último primero.
+	PastUsernames(ctx context.Context, id int64) ([]models.UsernameChange, error)
+	SetRole(ctx context.Context, id int64, role string) error
+	MarkEmailVerified(ctx context.Context, id int64, at time.Time) error
+	// SetBan guarda el baneo (bannedAt) y la suspensión (suspendedUntil) con
+	// su motivo; ambos nil lo levantan.
+	SetBan(ctx context.Context, id int64, bannedAt, suspendedUntil *time.Time, reason string) error
+	// SetMute deja al usuario en solo lectura hasta until; nil lo levanta.
+	SetMute(ctx context.Context, id int64, until *time.Time, reason string) error
+}
+
+type SessionStore interface {
+	Create(ctx context.Context, s models.Session) error
+	Get(ctx context.Context, id string) (models.Session, error)
+	// ListForUser devuelve las sesiones de uid que siguen vigentes en now,
+	// la usada más recientemente primero.
+	ListForUser(ctx context.Context, uid int64, now time.Time) ([]models.Session, error)
+	// Touch apunta actividad en la sesión: hora e IP desde la que llegó.
+	Touch(ctx context.Context, id, ip string, at time.Time) error
+	// Renew cambia la caducidad de la sesión.
+	Renew(ctx context.Context, id string, expiresAt time.Time) error
+	// Rotate sustituye la sesión oldID por ses (con otro id) de forma atómica;
+	// ErrNotFound si oldID ya no existe.
+	Rotate(ctx context.Context, oldID string, ses models.Session) error
+	Delete(ctx context.Context, id string) error
+	DeleteForUser(ctx context.Context, uid int64) error
+	// DeleteOthers borra las sesiones de uid salvo keep.
+	DeleteOthers(ctx context.Context, uid int64, keep string) error
+	// DeleteExpired borra las sesiones caducadas en now y dice cuántas eran.
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
+
+// LoginAttemptStore guarda los logins fallidos para el rate limit (ver
+// auth.Login). key dice a quién se limita: "ip:…" o "account:…".
+type LoginAttemptStore interface {
+	Fail(ctx context.Context, key string, at time.Time) error
+	// Recent devuelve como mucho limit fallos de key desde since, el más nuevo primero.
+	Recent(ctx context.Context, key string, since time.Time, limit int) ([]time.Time, error)
+	Clear(ctx context.Context, key string) error
+	// Prune borra los fallos anteriores a before, de todas las claves.
+	Prune(ctx context.Context, before time.Time) error
+}
+
+// TokenKind distingue los tokens de un solo uso enviados por email.
+type TokenKind string
+
+const (
+	TokenPasswordReset  TokenKind = "password_reset"
+	TokenEmailVerify    TokenKind = "email_verify"
+	TokenLoginChallenge TokenKind = "login_challenge" // contraseña bien, falta el segundo factor
+)
+
+// TokenStore guarda tokens de un solo uso (solo su hash).
+type TokenStore interface {
+	Create(ctx context.Context, kind TokenKind, uid int64, hash string, expires, now time.Time) error
+	// Consume valida el token y marca como usados todos los pendientes de ese
+	// usuario y tipo. ErrNotFound si no existe, caducó o ya se usó.
+	Consume(ctx context.Context, kind TokenKind, hash string, now time.Time) (int64, error)
+	// Lookup es como Consume pero sin gastar el token.
+	Lookup(ctx context.Context, kind TokenKind, hash string, now time.Time) (int64, error)
+	// ConsumeAndReset gasta un token de reseteo de contraseña (como Consume)
+	// y hace UserStore.ChangePassword sin conservar ninguna sesión, todo o
+	// nada. Devuelve el usuario; ErrNotFound si el token no vale.
+	ConsumeAndReset(ctx context.Context, hash, passwordHash string, now time.Time) (int64, error)
+}
+
+// EmailChangeStore guarda los cambios de email pendientes de confirmar desde
+// la dirección nueva (solo el hash del token).
+type EmailChangeStore interface {
+	Create(ctx context.Context, uid int64, newEmail, hash string, expires, now time.Time) error
+	// Pending devuelve la dirección del último cambio sin confirmar ni
+	// caducar; ErrNotFound si no hay ninguno.
+	Pending(ctx context.Context, uid int64, now time.Time) (string, error)
+	// Consume valida el token y marca como usados todos los cambios pendientes
+	// del usuario; devuelve el usuario y su email nuevo. ErrNotFound si no
+	// existe, caducó o ya se usó.
+	Consume(ctx context.Context, hash string, now time.Time) (int64, string, error)
+}
+
+// TwoFactorStore guarda el secreto TOTP de cada usuario y sus códigos de
+// recuperación (solo el hash). ErrNotFound cuando el estado no permite la
+// operación: nada que confirmar, un paso ya usado, un código gastado…
+type TwoFactorStore interface {
+	Get(ctx context.Context, uid int64) (models.TwoFactor, error)
+	// Begin guarda un secreto sin confirmar, sustituyendo a otro pendiente.
+	// ErrNotFound si el usuario ya tiene el TOTP activo.
+	Begin(ctx context.Context, uid int64, secret string, at time.Time) error
+	// Enable confirma el alta pendiente con el paso del código usado y
+	// guarda los códigos de recuperación.
+	Enable(ctx context.Context, uid, step int64, codeHashes []string, at time.Time) error
+	// Disable borra el secreto y los códigos.
+	Disable(ctx context.Context, uid int64) error
+	// UseStep acepta step solo si es posterior al último usado.
+	UseStep(ctx context.Context, uid, step int64) error
+	UseRecoveryCode(ctx context.Context, uid int64, hash string, at time.Time) error
+	// ReplaceRecoveryCodes invalida los códigos anteriores.
+	ReplaceRecoveryCodes(ctx context.Context, uid int64, hashes []string, at time.Time) error
+}
+
+type APITokenStore interface {
+	Create(ctx context.Context, t models.APIToken, hash string) (int64, error)
+	ListForUser(ctx context.Context, uid int64) ([]models.APIToken, error)
+	// Delete borra el token solo si es de uid; ErrNotFound si no.
+	Delete(ctx context.Context, uid, id int64) error
+	// Use busca el token por hash y actualiza LastUsedAt.
+	Use(ctx context.Context, hash string, now time.Time) (models.APIToken, error)
+}
+
+/* =========================
+   Contenido
+   ========================= */
+
+// Cursor de paginación por keyset: (created_at, id) del último post visto.
+type Cursor struct {
+	CreatedAt time.Time
+	ID        int64
+}
+
+// PinFilter decide qué hacer con los posts fijados en un listado.
+type PinFilter int
+
+const (
+	PinAny     PinFilter = iota // todos, fijados o no
+	PinOnly                     // solo los fijados
+	PinExclude                  // solo los no fijados
+)
+
+// PostQuery son los filtros y la página de un listado de posts.
+type PostQuery struct {
+	Category string // nombre de categoría
+	AuthorID int64  // "mis posts"
+	LikedBy  int64  // posts con 👍 de este usuario
+	Pinned   PinFilter
+	After    *Cursor // posts más antiguos que el cursor
+	Before   *Cursor // posts más nuevos que el cursor
+	Limit    int
+}
+
+type PostStore interface {
+	// List devuelve como mucho q.Limit posts (más nuevo primero) con autor y
+	// reacciones, pero sin categorías ni comentarios; more indica si hay más
+	// posts en la dirección pedida.
+	List(ctx context.Context, q PostQuery) (posts []models.Post, more bool, err error)
+	Get(ctx context.Context, id int64) (models.Post, error)
+	// Create crea el post y lo enlaza con las categorías (creándolas si hace falta).
+	Create(ctx context.Context, p models.Post, categories []string) (int64, error)
+	Update(ctx context.Context, id int64, title, content string, at time.Time) error
+	// SetLocked / SetPinned marcan (at != nil) o desmarcan el post;
+	// ErrNotFound si no existe.
+	SetLocked(ctx context.Context, id int64, at *time.Time) error
+	SetPinned(ctx context.Context, id int64, at *time.Time) error
+	// Delete borra el post, sus comentarios y las reacciones de ambos.
+	Delete(ctx context.Context, id int64) error
+}
+
+type CommentStore interface {
+	// ForPosts devuelve los hilos de esos posts en orden cronológico (con
+	// Depth rellenado) y el total de comentarios por post. latest > 0 limita
+	// a los últimos N hilos de primer nivel de cada post, con todas sus respuestas.
+	ForPosts(ctx context.Context, postIDs []int64, latest int) ([]models.Comment, map[int64]int, error)
+	Get(ctx context.Context, id int64) (models.Comment, error)
+	// Create devuelve ErrNotFound si el post no existe o si ParentID no es un
+	// comentario de ese mismo post.
+	Create(ctx context.Context, c models.Comment) (int64, error)
+	Update(ctx context.Context, id int64, content string, at time.Time) error
+	// Delete borra el comentario, todas sus respuestas y las reacciones de todos ellos.
+	Delete(ctx context.Context, id int64) error
+}
+
+type CategoryStore interface {
+	List(ctx context.Context) ([]models.Category, error)
+	ForPosts(ctx context.Context, postIDs []int64) (map[int64][]models.Category, error)
+}
+
+type ReactionStore interface {
+	// Set guarda o cambia la reacción (1 / -1) de uid sobre un post o
+	// comentario; ErrNotFound si el objetivo no existe.
+	Set(ctx context.Context, uid int64, target string, id int64, value int) error
+	// Toggle es Set, salvo que si uid ya tenía ese mismo valor la quita.
+	// Devuelve la reacción que queda (0 = ninguna).
+	Toggle(ctx context.Context, uid int64, target string, id int64, value int) (int, error)
+	// Clear quita la reacción de uid; no es error si no había ninguna.
+	Clear(ctx context.Context, uid int64, target string, id int64) error
+	// Mine devuelve la reacción de uid sobre cada id que tenga una (1 / -1).
+	Mine(ctx context.Context, uid int64, target string, ids []int64) (map[int64]int, error)
+}
+
+/* =========================
+   Moderación
+   ========================= */
+
+// Estados de una denuncia.
+const (
+	ReportOpen      = "open"
+	ReportResolved  = "resolved"  // se actuó sobre el contenido
+	ReportDismissed = "dismissed" // no había nada que hacer
+)
+
+type ReportStore interface {
+	// Create abre una denuncia sobre r.Target/r.TargetID. ErrNotFound si el
+	// contenido no existe; ErrAlreadyReported si r.ReporterID ya tiene una
+	// abierta sobre él.
+	Create(ctx context.Context, r models.Report) (int64, error)
+	Get(ctx context.Context, id int64) (models.Report, error)
+	// Open devuelve las denuncias abiertas, la más antigua primero, con el
+	// contenido denunciado.
+	Open(ctx context.Context, limit int) ([]models.Report, error)
+	// Close cierra una denuncia abierta con status (ReportResolved o
+	// ReportDismissed) y apunta quién y cuándo; ErrNotFound si no existe o
+	// ya estaba cerrada.
+	Close(ctx context.Context, id int64, status string, by int64, at time.Time) error
+}
+
+// AuditQuery filtra el registro de auditoría; los campos vacíos no filtran.
+type AuditQuery struct {
+	Actor      string // nombre de usuario
+	Action     string // exacta ("user.ban") o prefijo acabado en punto ("user.")
+	TargetType string
+	TargetID   int64
+	From       time.Time // desde (incluido)
+	To         time.Time // hasta (excluido)
+	BeforeID   int64     // página: entradas con id menor (0 = desde la última)
+	Limit      int
+}
+
+// AuditStore es solo de añadir: no hay Update ni Delete (y la BD los rechaza).
+type AuditStore interface {
+	Append(ctx context.Context, e models.AuditEntry) (int64, error)
+	// List devuelve como mucho q.Limit entradas, la más nueva primero; more
+	// indica si hay más antiguas.
+	List(ctx context.Context, q AuditQuery) (entries []models.AuditEntry, more bool, err error)
+}
+
+/* =========================
+   Búsqueda
+   ========================= */
+
+// SearchQuery es una búsqueda de texto completo sobre posts y comentarios.
+// Text admite palabras, "frases" y -exclusiones (ver Terms); el resto son
+// filtros exactos. Text vacío = solo filtros, del más nuevo al más antiguo.
+type SearchQuery struct {
+	Text     string
+	Author   string    // nombre de usuario
+	Category string    // del post (también para sus comentarios)
+	From     time.Time // desde (incluido); cero = sin límite
+	To       time.Time // hasta (excluido); cero = sin límite
+	Limit    int
+	Offset   int
+}
+
+// SearchHit es un resultado: un post o un comentario (con su post).
+type SearchHit struct {
+	Kind      string // "post" | "comment"
+	PostID    int64
+	CommentID int64 // 0 si es un post
+	Title     string
+	Author    string
+	// Snippet es un extracto del texto con las coincidencias entre
+	// HighlightStart y HighlightEnd; sin escapar.
+	Snippet   string
+	CreatedAt time.Time
+}
+
+type SearchStore interface {
+	// Search devuelve como mucho q.Limit resultados por relevancia; more
+	// indica si hay más a partir de q.Offset+q.Limit.
+	Search(ctx context.Context, q SearchQuery) (hits []SearchHit, more bool, err error)
+}
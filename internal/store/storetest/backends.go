@@ -0,0 +1,62 @@
+package storetest
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"forum/internal/db"
+	"forum/internal/store"
+	"forum/internal/store/memstore"
+	"forum/internal/store/sqlstore"
+)
+
+// Backend es una implementación de store sobre la que ejecutar tests.
+type Backend struct {
+	Name string
+	New  func(t *testing.T) *store.Store
+}
+
+// Backends devuelve memstore y SQLite (siempre) y Postgres si
+// FORUM_TEST_DATABASE_URL apunta a una BD de usar y tirar.
+func Backends() []Backend {
+	return []Backend{
+		{"memstore", Memory},
+		{"sqlite", SQLite},
+		{"postgres", Postgres},
+	}
+}
+
+func Memory(*testing.T) *store.Store { return memstore.New() }
+
+// SQLite crea una BD nueva y migrada en un directorio temporal del test.
+func SQLite(t *testing.T) *store.Store {
+	t.Helper()
+	return sqlstore.New(openMigrated(t, filepath.Join(t.TempDir(), "forum.db")))
+}
+
+// Postgres usa FORUM_TEST_DATABASE_URL (compartida entre tests: cada test
+// crea sus propios datos) y se salta el test si no está definida.
+func Postgres(t *testing.T) *store.Store {
+	t.Helper()
+	dsn := os.Getenv("FORUM_TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("FORUM_TEST_DATABASE_URL not set")
+	}
+	return sqlstore.New(openMigrated(t, dsn))
+}
+
+func openMigrated(t *testing.T, dsn string) *sql.DB {
+	t.Helper()
+	d, err := db.Open(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Close() })
+	if _, err := db.MigrateUp(context.Background(), d); err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
@@ -0,0 +1,1176 @@
+// Package storetest tiene los tests comunes a todas las implementaciones de
+// store. Cada implementación los ejecuta desde su propio _test.go.
+package storetest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"forum/internal/models"
+	"forum/internal/store"
+)
+
+// seq distingue los datos de cada test cuando la BD se reutiliza entre ejecuciones.
+var seq atomic.Int64
+
+func unique(prefix string) string {
+	return fmt.Sprintf("%s-%d-%d", prefix, time.Now().UnixNano(), seq.Add(1))
+}
+
+// word devuelve una palabra inventada (solo letras) para que las búsquedas
+// no choquen con datos de otros tests en la misma base de datos.
+func word() string {
+	n := uint64(time.Now().UnixNano()) + uint64(seq.Add(1))
+	b := []byte("zq")
+	for ; n > 0; n /= 26 {
+		b = append(b, byte('a'+n%26))
+	}
+	return string(b)
+}
+
+// Run ejecuta la batería completa; newStore debe devolver un Store utilizable
+// (vacío o no: los tests crean sus propios datos).
+func Run(t *testing.T, newStore func(t *testing.T) *store.Store) {
+	t.Run("Users", func(t *testing.T) { testUsers(t, newStore(t)) })
+	t.Run("Sessions", func(t *testing.T) { testSessions(t, newStore(t)) })
+	t.Run("Tokens", func(t *testing.T) { testTokens(t, newStore(t)) })
+	t.Run("APITokens", func(t *testing.T) { testAPITokens(t, newStore(t)) })
+	t.Run("Posts", func(t *testing.T) { testPosts(t, newStore(t)) })
+	t.Run("Pagination", func(t *testing.T) { testPagination(t, newStore(t)) })
+	t.Run("Comments", func(t *testing.T) { testComments(t, newStore(t)) })
+	t.Run("Threads", func(t *testing.T) { testThreads(t, newStore(t)) })
+	t.Run("Reactions", func(t *testing.T) { testReactions(t, newStore(t)) })
+	t.Run("Reports", func(t *testing.T) { testReports(t, newStore(t)) })
+	t.Run("Audit", func(t *testing.T) { testAudit(t, newStore(t)) })
+	t.Run("LoginAttempts", func(t *testing.T) { testLoginAttempts(t, newStore(t)) })
+	t.Run("TwoFactor", func(t *testing.T) { testTwoFactor(t, newStore(t)) })
+	t.Run("AccountChanges", func(t *testing.T) { testAccountChanges(t, newStore(t)) })
+	t.Run("Search", func(t *testing.T) { testSearch(t, newStore(t)) })
+}
+
+// now se trunca a microsegundos, la precisión de TIMESTAMPTZ.
+func now() time.Time { return time.Now().Truncate(time.Microsecond) }
+
+func newUser(t *testing.T, st *store.Store) models.User {
+	t.Helper()
+	name := unique("user")
+	u := models.User{Email: name + "@example.test", Username: name, PasswordHash: "hash", CreatedAt: now()}
+	id, err := st.Users.Create(context.Background(), u)
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	u.ID = id
+	return u
+}
+
+func testUsers(t *testing.T, st *store.Store) {
+	ctx := context.Background()
+	u := newUser(t, st)
+
+	got, err := st.Users.ByEmail(ctx, u.Email)
+	if err != nil || got.ID != u.ID || got.Username != u.Username || got.Role != "member" {
+		t.Fatalf("ByEmail = %+v, %v", got, err)
+	}
+	if got.EmailVerifiedAt != nil {
+		t.Fatal("new user should be unverified")
+	}
+	if got, err := st.Users.ByUsername(ctx, u.Username); err != nil || got.ID != u.ID {
+		t.Fatalf("ByUsername = %+v, %v", got, err)
+	}
+	if _, err := st.Users.ByID(ctx, -1); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("ByID(missing) err = %v", err)
+	}
+
+	dup := models.User{Email: u.Email, Username: unique("other"), PasswordHash: "x", CreatedAt: now()}
+	if _, err := st.Users.Create(ctx, dup); !errors.Is(err, store.ErrEmailTaken) {
+		t.Fatalf("duplicate email err = %v", err)
+	}
+	dup = models.User{Email: unique("other") + "@example.test", Username: u.Username, PasswordHash: "x", CreatedAt: now()}
+	if _, err := st.Users.Create(ctx, dup); !errors.Is(err, store.ErrUsernameTaken) {
+		t.Fatalf("duplicate username err = %v", err)
+	}
+
+	if err := st.Users.ChangePassword(ctx, u.ID, "new-hash", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Users.ChangePassword(ctx, -1, "new-hash", ""); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("ChangePassword(missing) err = %v", err)
+	}
+	if err := st.Users.SetRole(ctx, u.ID, "moderator"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := st.Users.ByID(ctx, u.ID); got.Role != "moderator" {
+		t.Fatalf("role after SetRole = %q", got.Role)
+	}
+	if err := st.Users.SetRole(ctx, -1, "admin"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("SetRole(missing) err = %v", err)
+	}
+	at := now()
+	if err := st.Users.MarkEmailVerified(ctx, u.ID, at); err != nil {
+		t.Fatal(err)
+	}
+	got, _ = st.Users.ByID(ctx, u.ID)
+	if got.PasswordHash != "new-hash" || got.EmailVerifiedAt == nil || !got.EmailVerifiedAt.Equal(at) {
+		t.Fatalf("after updates: %+v", got)
+	}
+
+	// Sanciones: se guardan con su motivo y nil las levanta
+	until := at.Add(24 * time.Hour)
+	if err := st.Users.SetBan(ctx, u.ID, nil, &until, "flooding"); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Users.SetMute(ctx, u.ID, &until, "rude"); err != nil {
+		t.Fatal(err)
+	}
+	got, _ = st.Users.ByID(ctx, u.ID)
+	if got.BannedAt != nil || got.SuspendedUntil == nil || !got.SuspendedUntil.Equal(until) || got.BanReason != "flooding" ||
+		got.MutedUntil == nil || !got.MutedUntil.Equal(until) || got.MuteReason != "rude" {
+		t.Fatalf("after sanctions: %+v", got)
+	}
+	if err := st.Users.SetBan(ctx, u.ID, &at, nil, "spam"); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Users.SetMute(ctx, u.ID, nil, ""); err != nil {
+		t.Fatal(err)
+	}
+	got, _ = st.Users.ByID(ctx, u.ID)
+	if got.BannedAt == nil || !got.BannedAt.Equal(at) || got.SuspendedUntil != nil || got.BanReason != "spam" || got.MutedUntil != nil {
+		t.Fatalf("after ban/unmute: %+v", got)
+	}
+	if err := st.Users.SetBan(ctx, -1, &at, nil, "x"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("SetBan(missing) err = %v", err)
+	}
+}
+
+func testSessions(t *testing.T, st *store.Store) {
+	ctx := context.Background()
+	u := newUser(t, st)
+	ses := models.Session{ID: unique("sid"), UserID: u.ID, CSRFToken: "csrf", ExpiresAt: now().Add(time.Hour), CreatedAt: now()}
+	if err := st.Sessions.Create(ctx, ses); err != nil {
+		t.Fatal(err)
+	}
+	got, err := st.Sessions.Get(ctx, ses.ID)
+	if err != nil || got.UserID != u.ID || got.CSRFToken != "csrf" || !got.ExpiresAt.Equal(ses.ExpiresAt) {
+		t.Fatalf("Get = %+v, %v", got, err)
+	}
+
+	// Varias sesiones por usuario: la lista va por última actividad y omite las caducadas
+	n := now()
+	phone := models.Session{ID: unique("sid"), UserID: u.ID, CSRFToken: "c2", UserAgent: "phone", IP: "10.0.0.2",
+		LastSeenAt: n.Add(-time.Hour), ExpiresAt: n.Add(time.Hour), CreatedAt: n.Add(-time.Hour)}
+	old := models.Session{ID: unique("sid"), UserID: u.ID, CSRFToken: "c3",
+		LastSeenAt: n.Add(-2 * time.Hour), ExpiresAt: n.Add(-time.Minute), CreatedAt: n.Add(-2 * time.Hour)}
+	for _, x := range []models.Session{phone, old} {
+		if err := st.Sessions.Create(ctx, x); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := st.Sessions.Touch(ctx, phone.ID, "10.0.0.3", n.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	list, err := st.Sessions.ListForUser(ctx, u.ID, n)
+	if err != nil || len(list) != 2 || list[0].ID != phone.ID || list[1].ID != ses.ID {
+		t.Fatalf("ListForUser = %+v, %v", list, err)
+	}
+	if got := list[0]; got.UserAgent != "phone" || got.IP != "10.0.0.3" || !got.LastSeenAt.Equal(n.Add(time.Minute)) {
+		t.Fatalf("touched session = %+v", got)
+	}
+	if err := st.Sessions.Touch(ctx, unique("nope"), "", n); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Touch missing err = %v", err)
+	}
+
+	// Renovar y rotar: la rotación conserva todo salvo el id
+	if err := st.Sessions.Renew(ctx, phone.ID, n.Add(2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	rotated := list[0]
+	rotated.ID, rotated.Role, rotated.Persistent = unique("sid"), "moderator", true
+	if err := st.Sessions.Rotate(ctx, phone.ID, rotated); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.Sessions.Get(ctx, phone.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("old id after Rotate err = %v", err)
+	}
+	if got, err := st.Sessions.Get(ctx, rotated.ID); err != nil || got.Role != "moderator" || !got.Persistent ||
+		got.CSRFToken != "c2" || !got.CreatedAt.Equal(phone.CreatedAt) {
+		t.Fatalf("rotated = %+v, %v", got, err)
+	}
+	if err := st.Sessions.Rotate(ctx, phone.ID, rotated); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Rotate missing err = %v", err)
+	}
+	if err := st.Sessions.Renew(ctx, phone.ID, n); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Renew missing err = %v", err)
+	}
+	phone = rotated
+
+	deleted, err := st.Sessions.DeleteExpired(ctx, n)
+	if err != nil || deleted < 1 {
+		t.Fatalf("DeleteExpired = %d, %v", deleted, err)
+	}
+	if _, err := st.Sessions.Get(ctx, old.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("expired session err = %v", err)
+	}
+
+	if err := st.Sessions.DeleteOthers(ctx, u.ID, ses.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.Sessions.Get(ctx, phone.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("after DeleteOthers err = %v", err)
+	}
+	if _, err := st.Sessions.Get(ctx, ses.ID); err != nil {
+		t.Fatalf("kept session err = %v", err)
+	}
+
+	if err := st.Sessions.DeleteForUser(ctx, u.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.Sessions.Get(ctx, ses.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("after DeleteForUser err = %v", err)
+	}
+}
+
+func testAccountChanges(t *testing.T, st *store.Store) {
+	ctx := context.Background()
+	u, other := newUser(t, st), newUser(t, st)
+	n := now()
+
+	// Email: el nuevo queda verificado; uno ajeno choca
+	if err := st.Users.SetEmail(ctx, u.ID, other.Email, n); !errors.Is(err, store.ErrEmailTaken) {
+		t.Fatalf("SetEmail(taken) err = %v", err)
+	}
+	email := unique("new") + "@example.test"
+	if err := st.Users.SetEmail(ctx, u.ID, email, n); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := st.Users.ByEmail(ctx, email); err != nil || got.ID != u.ID || got.EmailVerifiedAt == nil {
+		t.Fatalf("ByEmail after SetEmail = %+v, %v", got, err)
+	}
+	if err := st.Users.SetEmail(ctx, -1, unique("x")+"@example.test", n); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("SetEmail(missing) err = %v", err)
+	}
+
+	// Cambios de email pendientes: el último manda, consumir uno gasta todos
+	if _, err := st.EmailChanges.Pending(ctx, u.ID, n); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Pending before Create err = %v", err)
+	}
+	a, b, old := unique("a"), unique("b"), unique("old")
+	if err := st.EmailChanges.Create(ctx, u.ID, "expired@example.test", old, n.Add(-time.Minute), n.Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.EmailChanges.Create(ctx, u.ID, "first@example.test", a, n.Add(time.Hour), n); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.EmailChanges.Create(ctx, u.ID, "second@example.test", b, n.Add(time.Hour), n.Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := st.EmailChanges.Pending(ctx, u.ID, n); err != nil || got != "second@example.test" {
+		t.Fatalf("Pending = %q, %v", got, err)
+	}
+	if _, _, err := st.EmailChanges.Consume(ctx, old, n); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("expired change err = %v", err)
+	}
+	// Cada enlace confirma su dirección, no la última pedida
+	uid, got, err := st.EmailChanges.Consume(ctx, a, n)
+	if err != nil || uid != u.ID || got != "first@example.test" {
+		t.Fatalf("Consume = %d, %q, %v", uid, got, err)
+	}
+	if _, _, err := st.EmailChanges.Consume(ctx, b, n); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("sibling change err = %v", err)
+	}
+	if _, err := st.EmailChanges.Pending(ctx, u.ID, n); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Pending after Consume err = %v", err)
+	}
+
+	// Nombre: el anterior pasa al historial y sigue siendo suyo
+	first := u.Username
+	second := unique("renamed")
+	if err := st.Users.SetUsername(ctx, u.ID, other.Username, n); !errors.Is(err, store.ErrUsernameTaken) {
+		t.Fatalf("SetUsername(taken) err = %v", err)
+	}
+	if err := st.Users.SetUsername(ctx, u.ID, second, n); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := st.Users.ByUsername(ctx, second); err != nil || got.ID != u.ID {
+		t.Fatalf("ByUsername after SetUsername = %+v, %v", got, err)
+	}
+	past, err := st.Users.PastUsernames(ctx, u.ID)
+	if err != nil || len(past) != 1 || past[0].Username != first || !past[0].ChangedAt.Equal(n) {
+		t.Fatalf("PastUsernames = %+v, %v", past, err)
+	}
+	if err := st.Users.SetUsername(ctx, other.ID, first, n); !errors.Is(err, store.ErrUsernameTaken) {
+		t.Fatalf("rename to someone's old name err = %v", err)
+	}
+	reg := models.User{Email: unique("reg") + "@example.test", Username: first, PasswordHash: "x", CreatedAt: n}
+	if _, err := st.Users.Create(ctx, reg); !errors.Is(err, store.ErrUsernameTaken) {
+		t.Fatalf("register someone's old name err = %v", err)
+	}
+	if err := st.Users.SetUsername(ctx, u.ID, first, n.Add(time.Second)); err != nil {
+		t.Fatalf("revert to own old name err = %v", err)
+	}
+	if past, _ := st.Users.PastUsernames(ctx, u.ID); len(past) != 2 || past[0].Username != second {
+		t.Fatalf("PastUsernames after revert = %+v", past)
+	}
+	if err := st.Users.SetUsername(ctx, -1, unique("x"), n); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("SetUsername(missing) err = %v", err)
+	}
+}
+
+func testTokens(t *testing.T, st *store.Store) {
+	ctx := context.Background()
+	u := newUser(t, st)
+	n := now()
+
+	a, b, old := unique("a"), unique("b"), unique("old")
+	for _, h := range []string{a, b} {
+		if err := st.Tokens.Create(ctx, store.TokenPasswordReset, u.ID, h, n.Add(time.Hour), n); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := st.Tokens.Create(ctx, store.TokenPasswordReset, u.ID, old, n.Add(-time.Minute), n); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := st.Tokens.Consume(ctx, store.TokenPasswordReset, old, n); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("expired token err = %v", err)
+	}
+	// Los tipos no se mezclan
+	if _, err := st.Tokens.Consume(ctx, store.TokenEmailVerify, a, n); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("wrong kind err = %v", err)
+	}
+	// Lookup no gasta el token
+	for i := 0; i < 2; i++ {
+		if uid, err := st.Tokens.Lookup(ctx, store.TokenPasswordReset, a, n); err != nil || uid != u.ID {
+			t.Fatalf("Lookup = %d, %v", uid, err)
+		}
+	}
+	uid, err := st.Tokens.Consume(ctx, store.TokenPasswordReset, a, n)
+	if err != nil || uid != u.ID {
+		t.Fatalf("Consume = %d, %v", uid, err)
+	}
+	if _, err := st.Tokens.Lookup(ctx, store.TokenPasswordReset, a, n); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Lookup after Consume err = %v", err)
+	}
+	if _, err := st.Tokens.Consume(ctx, store.TokenPasswordReset, a, n); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("reused token err = %v", err)
+	}
+	// Consumir uno invalida el resto de pendientes del usuario
+	if _, err := st.Tokens.Consume(ctx, store.TokenPasswordReset, b, n); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("sibling token err = %v", err)
+	}
+
+	// ConsumeAndReset: token, contraseña y sesiones de una vez
+	reset := unique("reset")
+	if err := st.Tokens.Create(ctx, store.TokenPasswordReset, u.ID, reset, n.Add(time.Hour), n); err != nil {
+		t.Fatal(err)
+	}
+	ses := models.Session{ID: unique("sid"), UserID: u.ID, CSRFToken: "csrf", ExpiresAt: n.Add(time.Hour), CreatedAt: n}
+	if err := st.Sessions.Create(ctx, ses); err != nil {
+		t.Fatal(err)
+	}
+	pat := unique("pat")
+	if _, err := st.APITokens.Create(ctx, models.APIToken{UserID: u.ID, Name: "ci", Scopes: []string{"read"}, CreatedAt: n}, pat); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.Tokens.ConsumeAndReset(ctx, unique("bogus"), "bogus-hash", n); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("ConsumeAndReset(bad token) err = %v", err)
+	}
+	if got, _ := st.Users.ByID(ctx, u.ID); got.PasswordHash != u.PasswordHash {
+		t.Fatalf("bad token changed the password to %q", got.PasswordHash)
+	}
+	if uid, err := st.Tokens.ConsumeAndReset(ctx, reset, "reset-hash", n); err != nil || uid != u.ID {
+		t.Fatalf("ConsumeAndReset = %d, %v", uid, err)
+	}
+	if got, _ := st.Users.ByID(ctx, u.ID); got.PasswordHash != "reset-hash" {
+		t.Fatalf("password after reset = %q", got.PasswordHash)
+	}
+	if _, err := st.Sessions.Get(ctx, ses.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("session after reset err = %v", err)
+	}
+	if _, err := st.APITokens.Use(ctx, pat, n); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("api token after reset err = %v", err)
+	}
+	if _, err := st.Tokens.ConsumeAndReset(ctx, reset, "again", n); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("reused reset token err = %v", err)
+	}
+
+	// ChangePassword: conserva solo keepSession y revoca los tokens personales
+	keep := models.Session{ID: unique("sid"), UserID: u.ID, CSRFToken: "csrf", ExpiresAt: n.Add(time.Hour), CreatedAt: n}
+	other := models.Session{ID: unique("sid"), UserID: u.ID, CSRFToken: "csrf", ExpiresAt: n.Add(time.Hour), CreatedAt: n}
+	for _, s := range []models.Session{keep, other} {
+		if err := st.Sessions.Create(ctx, s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := st.APITokens.Create(ctx, models.APIToken{UserID: u.ID, Name: "ci", Scopes: []string{"read"}, CreatedAt: n}, pat); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Users.ChangePassword(ctx, u.ID, "changed-hash", keep.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := st.Users.ByID(ctx, u.ID); got.PasswordHash != "changed-hash" {
+		t.Fatalf("password after change = %q", got.PasswordHash)
+	}
+	if _, err := st.Sessions.Get(ctx, keep.ID); err != nil {
+		t.Fatalf("kept session err = %v", err)
+	}
+	if _, err := st.Sessions.Get(ctx, other.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("other session after change err = %v", err)
+	}
+	if _, err := st.APITokens.Use(ctx, pat, n); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("api token after change err = %v", err)
+	}
+}
+
+func testAPITokens(t *testing.T, st *store.Store) {
+	ctx := context.Background()
+	u, other := newUser(t, st), newUser(t, st)
+	hash := unique("hash")
+
+	id, err := st.APITokens.Create(ctx, models.APIToken{UserID: u.ID, Name: "ci", Scopes: []string{"read", "post"}, CreatedAt: now()}, hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, err := st.APITokens.ListForUser(ctx, u.ID)
+	if err != nil || len(list) != 1 || list[0].ID != id || len(list[0].Scopes) != 2 || list[0].LastUsedAt != nil {
+		t.Fatalf("ListForUser = %+v, %v", list, err)
+	}
+
+	used := now()
+	tok, err := st.APITokens.Use(ctx, hash, used)
+	if err != nil || tok.UserID != u.ID || tok.LastUsedAt == nil || !tok.LastUsedAt.Equal(used) {
+		t.Fatalf("Use = %+v, %v", tok, err)
+	}
+	if _, err := st.APITokens.Use(ctx, unique("nope"), used); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Use(unknown) err = %v", err)
+	}
+
+	if err := st.APITokens.Delete(ctx, other.ID, id); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Delete by other user err = %v", err)
+	}
+	if err := st.APITokens.Delete(ctx, u.ID, id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.APITokens.Use(ctx, hash, used); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Use after delete err = %v", err)
+	}
+}
+
+func newPost(t *testing.T, st *store.Store, uid int64, at time.Time, cats ...string) int64 {
+	t.Helper()
+	id, err := st.Posts.Create(context.Background(), models.Post{UserID: uid, Title: "title", Content: "body", CreatedAt: at}, cats)
+	if err != nil {
+		t.Fatalf("create post: %v", err)
+	}
+	return id
+}
+
+func testPosts(t *testing.T, st *store.Store) {
+	ctx := context.Background()
+	u := newUser(t, st)
+	cat := unique("cat")
+	id := newPost(t, st, u.ID, now(), cat, cat, " ", "General")
+
+	p, err := st.Posts.Get(ctx, id)
+	if err != nil || p.UserID != u.ID || p.Author != u.Username || p.UpdatedAt != nil {
+		t.Fatalf("Get = %+v, %v", p, err)
+	}
+	cats, err := st.Categories.ForPosts(ctx, []int64{id})
+	if err != nil || len(cats[id]) != 2 {
+		t.Fatalf("ForPosts = %+v, %v", cats, err)
+	}
+	all, err := st.Categories.List(ctx)
+	if err != nil || !hasCategory(all, cat) {
+		t.Fatalf("List = %+v, %v", all, err)
+	}
+
+	if err := st.Posts.Update(ctx, id, "new", "content", now()); err != nil {
+		t.Fatal(err)
+	}
+	p, _ = st.Posts.Get(ctx, id)
+	if p.Title != "new" || p.Content != "content" || p.UpdatedAt == nil {
+		t.Fatalf("after Update: %+v", p)
+	}
+	if err := st.Posts.Update(ctx, -1, "x", "y", now()); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Update(missing) err = %v", err)
+	}
+
+	// Cerrar y fijar
+	at := now()
+	if err := st.Posts.SetLocked(ctx, id, &at); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Posts.SetPinned(ctx, id, &at); err != nil {
+		t.Fatal(err)
+	}
+	p, _ = st.Posts.Get(ctx, id)
+	if p.LockedAt == nil || !p.LockedAt.Equal(at) || p.PinnedAt == nil {
+		t.Fatalf("after lock/pin: %+v", p)
+	}
+	other := newPost(t, st, u.ID, now(), cat)
+	pinned, _, _ := st.Posts.List(ctx, store.PostQuery{Category: cat, Pinned: store.PinOnly, Limit: 10})
+	rest, _, _ := st.Posts.List(ctx, store.PostQuery{Category: cat, Pinned: store.PinExclude, Limit: 10})
+	if len(pinned) != 1 || pinned[0].ID != id || len(rest) != 1 || rest[0].ID != other {
+		t.Fatalf("pinned = %+v, rest = %+v", pinned, rest)
+	}
+	if err := st.Posts.SetLocked(ctx, id, nil); err != nil {
+		t.Fatal(err)
+	}
+	if p, _ = st.Posts.Get(ctx, id); p.LockedAt != nil || p.PinnedAt == nil {
+		t.Fatalf("after unlock: %+v", p)
+	}
+	if err := st.Posts.SetPinned(ctx, -1, nil); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("SetPinned(missing) err = %v", err)
+	}
+
+	if err := st.Posts.Delete(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.Posts.Get(ctx, id); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Get after Delete err = %v", err)
+	}
+}
+
+func hasCategory(cs []models.Category, name string) bool {
+	for _, c := range cs {
+		if c.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func testPagination(t *testing.T, st *store.Store) {
+	ctx := context.Background()
+	u, fan := newUser(t, st), newUser(t, st)
+	cat := unique("page")
+	base := now().Add(-time.Hour)
+
+	// 5 posts, del más viejo (0) al más nuevo (4)
+	var ids []int64
+	for i := 0; i < 5; i++ {
+		ids = append(ids, newPost(t, st, u.ID, base.Add(time.Duration(i)*time.Minute), cat))
+	}
+
+	q := store.PostQuery{Category: cat, Limit: 2}
+	page, more, err := st.Posts.List(ctx, q)
+	if err != nil || !more || len(page) != 2 || page[0].ID != ids[4] || page[1].ID != ids[3] {
+		t.Fatalf("page 1 = %v more=%v err=%v", postIDs(page), more, err)
+	}
+
+	q.After = &store.Cursor{CreatedAt: page[1].CreatedAt, ID: page[1].ID}
+	page, more, err = st.Posts.List(ctx, q)
+	if err != nil || !more || len(page) != 2 || page[0].ID != ids[2] || page[1].ID != ids[1] {
+		t.Fatalf("page 2 = %v more=%v err=%v", postIDs(page), more, err)
+	}
+
+	q.After = &store.Cursor{CreatedAt: page[1].CreatedAt, ID: page[1].ID}
+	last, more, err := st.Posts.List(ctx, q)
+	if err != nil || more || len(last) != 1 || last[0].ID != ids[0] {
+		t.Fatalf("page 3 = %v more=%v err=%v", postIDs(last), more, err)
+	}
+
+	// Hacia atrás desde la página 2 vuelve a la 1, en el mismo orden
+	q.After = nil
+	q.Before = &store.Cursor{CreatedAt: page[0].CreatedAt, ID: page[0].ID}
+	back, more, err := st.Posts.List(ctx, q)
+	if err != nil || more || len(back) != 2 || back[0].ID != ids[4] || back[1].ID != ids[3] {
+		t.Fatalf("back = %v more=%v err=%v", postIDs(back), more, err)
+	}
+
+	// Filtros
+	if err := st.Reactions.Set(ctx, fan.ID, "post", ids[2], 1); err != nil {
+		t.Fatal(err)
+	}
+	liked, _, err := st.Posts.List(ctx, store.PostQuery{LikedBy: fan.ID, Limit: 10})
+	if err != nil || len(liked) != 1 || liked[0].ID != ids[2] || liked[0].Likes != 1 {
+		t.Fatalf("liked = %+v, %v", liked, err)
+	}
+	mine, _, err := st.Posts.List(ctx, store.PostQuery{AuthorID: u.ID, Limit: 10})
+	if err != nil || len(mine) != 5 {
+		t.Fatalf("mine = %v, %v", postIDs(mine), err)
+	}
+}
+
+func postIDs(ps []models.Post) []int64 {
+	out := make([]int64, len(ps))
+	for i, p := range ps {
+		out[i] = p.ID
+	}
+	return out
+}
+
+func testComments(t *testing.T, st *store.Store) {
+	ctx := context.Background()
+	u := newUser(t, st)
+	base := now().Add(-time.Hour)
+	p1, p2 := newPost(t, st, u.ID, base), newPost(t, st, u.ID, base)
+
+	var c1 []int64
+	for i := 0; i < 4; i++ {
+		id, err := st.Comments.Create(ctx, models.Comment{PostID: p1, UserID: u.ID, Content: fmt.Sprint(i), CreatedAt: base.Add(time.Duration(i) * time.Minute)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		c1 = append(c1, id)
+	}
+	if _, err := st.Comments.Create(ctx, models.Comment{PostID: p2, UserID: u.ID, Content: "x", CreatedAt: base}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.Comments.Create(ctx, models.Comment{PostID: -1, UserID: u.ID, Content: "x", CreatedAt: base}); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("comment on missing post err = %v", err)
+	}
+
+	// Últimos 2 de cada post, en orden cronológico, con el total
+	cs, counts, err := st.Comments.ForPosts(ctx, []int64{p1, p2}, 2)
+	if err != nil || counts[p1] != 4 || counts[p2] != 1 || len(cs) != 3 {
+		t.Fatalf("ForPosts = %+v %v %v", cs, counts, err)
+	}
+	var got []int64
+	for _, c := range cs {
+		if c.PostID == p1 {
+			got = append(got, c.ID)
+		}
+	}
+	if len(got) != 2 || got[0] != c1[2] || got[1] != c1[3] {
+		t.Fatalf("latest comments = %v, want %v", got, c1[2:])
+	}
+	if cs, _, _ := st.Comments.ForPosts(ctx, []int64{p1}, 0); len(cs) != 4 {
+		t.Fatalf("all comments = %d, want 4", len(cs))
+	}
+
+	if err := st.Comments.Update(ctx, c1[0], "edited", now()); err != nil {
+		t.Fatal(err)
+	}
+	c, err := st.Comments.Get(ctx, c1[0])
+	if err != nil || c.Content != "edited" || c.UpdatedAt == nil || c.Author != u.Username {
+		t.Fatalf("Get = %+v, %v", c, err)
+	}
+	if err := st.Comments.Delete(ctx, c1[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.Comments.Get(ctx, c1[0]); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Get after Delete err = %v", err)
+	}
+
+	// Borrar el post se lleva sus comentarios
+	if err := st.Posts.Delete(ctx, p1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.Comments.Get(ctx, c1[1]); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("comment of deleted post err = %v", err)
+	}
+}
+
+func testThreads(t *testing.T, st *store.Store) {
+	ctx := context.Background()
+	u := newUser(t, st)
+	base := now().Add(-time.Hour)
+	pid, other := newPost(t, st, u.ID, base), newPost(t, st, u.ID, base)
+
+	n := 0
+	reply := func(parent int64) int64 {
+		t.Helper()
+		n++
+		c := models.Comment{PostID: pid, UserID: u.ID, Content: fmt.Sprint(n), CreatedAt: base.Add(time.Duration(n) * time.Minute)}
+		if parent != 0 {
+			c.ParentID = &parent
+		}
+		id, err := st.Comments.Create(ctx, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	// a ─ a1 ─ a11
+	// b ─ b1
+	a := reply(0)
+	b := reply(0)
+	a1 := reply(a)
+	b1 := reply(b)
+	a11 := reply(a1)
+
+	// El padre tiene que ser del mismo post
+	bad := models.Comment{PostID: other, ParentID: &a, UserID: u.ID, Content: "x", CreatedAt: base}
+	if _, err := st.Comments.Create(ctx, bad); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("reply across posts err = %v", err)
+	}
+	missing := int64(-1)
+	bad = models.Comment{PostID: pid, ParentID: &missing, UserID: u.ID, Content: "x", CreatedAt: base}
+	if _, err := st.Comments.Create(ctx, bad); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("reply to missing comment err = %v", err)
+	}
+
+	cs, counts, err := st.Comments.ForPosts(ctx, []int64{pid}, 0)
+	if err != nil || counts[pid] != 5 || len(cs) != 5 {
+		t.Fatalf("ForPosts = %+v %v %v", cs, counts, err)
+	}
+	depth := map[int64]int{}
+	for _, c := range cs {
+		depth[c.ID] = c.Depth
+	}
+	if depth[a] != 0 || depth[b] != 0 || depth[a1] != 1 || depth[b1] != 1 || depth[a11] != 2 {
+		t.Fatalf("depths = %v", depth)
+	}
+	if c, _ := st.Comments.Get(ctx, a11); c.ParentID == nil || *c.ParentID != a1 {
+		t.Fatalf("Get(a11).ParentID = %v", c.ParentID)
+	}
+
+	// latest cuenta hilos de primer nivel: el último (b) entra con sus respuestas
+	cs, counts, _ = st.Comments.ForPosts(ctx, []int64{pid}, 1)
+	if counts[pid] != 5 || len(cs) != 2 || cs[0].ID != b || cs[1].ID != b1 {
+		t.Fatalf("latest thread = %+v, total %d", cs, counts[pid])
+	}
+
+	// Borrar un comentario se lleva su subárbol y sus reacciones
+	if err := st.Reactions.Set(ctx, u.ID, "comment", a11, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Comments.Delete(ctx, a); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []int64{a, a1, a11} {
+		if _, err := st.Comments.Get(ctx, id); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("Get(%d) after deleting thread err = %v", id, err)
+		}
+	}
+	if cs, counts, _ := st.Comments.ForPosts(ctx, []int64{pid}, 0); counts[pid] != 2 || len(cs) != 2 {
+		t.Fatalf("after delete = %+v, total %d", cs, counts[pid])
+	}
+}
+
+func testReactions(t *testing.T, st *store.Store) {
+	ctx := context.Background()
+	u := newUser(t, st)
+	pid := newPost(t, st, u.ID, now())
+
+	if err := st.Reactions.Set(ctx, u.ID, "post", pid, 1); err != nil {
+		t.Fatal(err)
+	}
+	// Cambiar de opinión sustituye la reacción
+	if err := st.Reactions.Set(ctx, u.ID, "post", pid, -1); err != nil {
+		t.Fatal(err)
+	}
+	p, _ := st.Posts.Get(ctx, pid)
+	if p.Likes != 0 || p.Dislikes != 1 {
+		t.Fatalf("likes=%d dislikes=%d", p.Likes, p.Dislikes)
+	}
+	if err := st.Reactions.Set(ctx, u.ID, "comment", -1, 1); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("reaction on missing comment err = %v", err)
+	}
+
+	// Toggle: repetir el mismo valor quita la reacción, otro valor la cambia
+	if v, err := st.Reactions.Toggle(ctx, u.ID, "post", pid, -1); err != nil || v != 0 {
+		t.Fatalf("Toggle(same) = %d, %v", v, err)
+	}
+	if v, err := st.Reactions.Toggle(ctx, u.ID, "post", pid, 1); err != nil || v != 1 {
+		t.Fatalf("Toggle(new) = %d, %v", v, err)
+	}
+	if _, err := st.Reactions.Toggle(ctx, u.ID, "post", -1, 1); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Toggle on missing post err = %v", err)
+	}
+
+	// Reacciones a comentarios: contadores y la del propio usuario
+	other := newUser(t, st)
+	cid, err := st.Comments.Create(ctx, models.Comment{PostID: pid, UserID: u.ID, Content: "c", CreatedAt: now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	st.Reactions.Set(ctx, u.ID, "comment", cid, 1)
+	st.Reactions.Set(ctx, other.ID, "comment", cid, -1)
+	cs, _, _ := st.Comments.ForPosts(ctx, []int64{pid}, 0)
+	if len(cs) != 1 || cs[0].Likes != 1 || cs[0].Dislikes != 1 {
+		t.Fatalf("comment counts = %+v", cs)
+	}
+	if c, _ := st.Comments.Get(ctx, cid); c.Likes != 1 || c.Dislikes != 1 {
+		t.Fatalf("Get counts = %+v", c)
+	}
+	mine, err := st.Reactions.Mine(ctx, other.ID, "comment", []int64{cid, -1})
+	if err != nil || len(mine) != 1 || mine[cid] != -1 {
+		t.Fatalf("Mine = %v, %v", mine, err)
+	}
+	if err := st.Reactions.Clear(ctx, other.ID, "comment", cid); err != nil {
+		t.Fatal(err)
+	}
+	if mine, _ := st.Reactions.Mine(ctx, other.ID, "comment", []int64{cid}); len(mine) != 0 {
+		t.Fatalf("Mine after Clear = %v", mine)
+	}
+	if mine, _ := st.Reactions.Mine(ctx, u.ID, "post", []int64{pid}); mine[pid] != 1 {
+		t.Fatalf("Mine(post) = %v", mine)
+	}
+}
+
+func testReports(t *testing.T, st *store.Store) {
+	ctx := context.Background()
+	author, reporter, mod := newUser(t, st), newUser(t, st), newUser(t, st)
+	pid := newPost(t, st, author.ID, now())
+	cid, err := st.Comments.Create(ctx, models.Comment{PostID: pid, UserID: author.ID, Content: "rude", CreatedAt: now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rp, err := st.Reports.Create(ctx, models.Report{Target: "post", TargetID: pid, ReporterID: reporter.ID, Reason: "spam", CreatedAt: now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc, err := st.Reports.Create(ctx, models.Report{Target: "comment", TargetID: cid, ReporterID: reporter.ID, Reason: "insults", CreatedAt: now().Add(time.Second)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.Reports.Create(ctx, models.Report{Target: "post", TargetID: pid, ReporterID: reporter.ID, Reason: "again", CreatedAt: now()}); !errors.Is(err, store.ErrAlreadyReported) {
+		t.Fatalf("duplicate report err = %v", err)
+	}
+	if _, err := st.Reports.Create(ctx, models.Report{Target: "comment", TargetID: -1, ReporterID: reporter.ID, Reason: "x", CreatedAt: now()}); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("report on missing comment err = %v", err)
+	}
+
+	// La cola trae el contenido denunciado; puede haber denuncias de otros tests
+	mine := func() []models.Report {
+		all, err := st.Reports.Open(ctx, 1000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out []models.Report
+		for _, r := range all {
+			if r.ID == rp || r.ID == rc {
+				out = append(out, r)
+			}
+		}
+		return out
+	}
+	open := mine()
+	if len(open) != 2 || open[0].ID != rp || open[1].ID != rc {
+		t.Fatalf("open = %+v", open)
+	}
+	if r := open[1]; r.Reporter != reporter.Username || r.PostID != pid || r.Content != "rude" || r.Author != author.Username || r.Status != store.ReportOpen {
+		t.Fatalf("comment report = %+v", r)
+	}
+
+	// Cerrarla apunta quién y cuándo; no se puede cerrar dos veces
+	at := now()
+	if err := st.Reports.Close(ctx, rc, store.ReportResolved, mod.ID, at); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Reports.Close(ctx, rc, store.ReportDismissed, mod.ID, at); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("closing twice err = %v", err)
+	}
+	r, err := st.Reports.Get(ctx, rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Status != store.ReportResolved || r.HandledBy == nil || *r.HandledBy != mod.ID || r.Handler != mod.Username || r.HandledAt == nil || !r.HandledAt.Equal(at) {
+		t.Fatalf("closed report = %+v", r)
+	}
+	if open := mine(); len(open) != 1 || open[0].ID != rp {
+		t.Fatalf("open after close = %+v", open)
+	}
+
+	// La denuncia sobrevive al contenido borrado
+	if err := st.Posts.Delete(ctx, pid); err != nil {
+		t.Fatal(err)
+	}
+	if r, err := st.Reports.Get(ctx, rp); err != nil || r.PostID != 0 || r.Content != "" {
+		t.Fatalf("report of deleted post = %+v, %v", r, err)
+	}
+	if _, err := st.Reports.Get(ctx, -1); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Get(missing) err = %v", err)
+	}
+}
+
+func testAudit(t *testing.T, st *store.Store) {
+	ctx := context.Background()
+	admin, target := newUser(t, st), newUser(t, st)
+	t0 := now()
+	add := func(actor *int64, action, before, after string, at time.Time) int64 {
+		id, err := st.Audit.Append(ctx, models.AuditEntry{
+			ActorID: actor, Action: action, TargetType: "user", TargetID: target.ID,
+			Before: before, After: after, IP: "192.0.2.1", CreatedAt: at,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	first := add(nil, "user.role", `{"role":"member"}`, `{"role":"moderator"}`, t0)
+	second := add(&admin.ID, "user.mute", "", `{"days":3}`, t0.Add(time.Minute))
+	third := add(&admin.ID, "post.delete", `{"title":"x"}`, "", t0.Add(2*time.Minute))
+
+	ids := func(es []models.AuditEntry) []int64 {
+		var out []int64
+		for _, e := range es {
+			out = append(out, e.ID)
+		}
+		return out
+	}
+	mine := store.AuditQuery{TargetType: "user", TargetID: target.ID, Limit: 10}
+
+	all, more, err := st.Audit.List(ctx, mine)
+	if err != nil || more || fmt.Sprint(ids(all)) != fmt.Sprint([]int64{third, second, first}) {
+		t.Fatalf("List = %v, %v, %v", ids(all), more, err)
+	}
+	e := all[2]
+	if e.ActorID != nil || e.Actor != "" || e.IP != "192.0.2.1" || !e.CreatedAt.Equal(t0) {
+		t.Fatalf("cli entry = %+v", e)
+	}
+	// Postgres normaliza el JSON (jsonb): se compara ya decodificado
+	var before, after map[string]string
+	if err := json.Unmarshal([]byte(e.Before), &before); err != nil || before["role"] != "member" {
+		t.Fatalf("before = %q, %v", e.Before, err)
+	}
+	if err := json.Unmarshal([]byte(e.After), &after); err != nil || after["role"] != "moderator" {
+		t.Fatalf("after = %q, %v", e.After, err)
+	}
+	if all[1].Before != "" || all[0].After != "" || all[0].Actor != admin.Username {
+		t.Fatalf("entries = %+v", all[:2])
+	}
+
+	q := mine
+	q.Action = "user."
+	if got, _, _ := st.Audit.List(ctx, q); fmt.Sprint(ids(got)) != fmt.Sprint([]int64{second, first}) {
+		t.Fatalf("action prefix = %v", ids(got))
+	}
+	q = mine
+	q.Actor, q.Action = admin.Username, "post.delete"
+	if got, _, _ := st.Audit.List(ctx, q); fmt.Sprint(ids(got)) != fmt.Sprint([]int64{third}) {
+		t.Fatalf("actor+action = %v", ids(got))
+	}
+	q = mine
+	q.From, q.To = t0.Add(time.Minute), t0.Add(2*time.Minute)
+	if got, _, _ := st.Audit.List(ctx, q); fmt.Sprint(ids(got)) != fmt.Sprint([]int64{second}) {
+		t.Fatalf("date range = %v", ids(got))
+	}
+
+	// Paginación por id
+	q = mine
+	q.Limit = 2
+	page, more, _ := st.Audit.List(ctx, q)
+	if len(page) != 2 || !more {
+		t.Fatalf("page 1 = %v, more=%v", ids(page), more)
+	}
+	q.BeforeID = page[1].ID
+	if page, more, _ = st.Audit.List(ctx, q); fmt.Sprint(ids(page)) != fmt.Sprint([]int64{first}) || more {
+		t.Fatalf("page 2 = %v, more=%v", ids(page), more)
+	}
+}
+
+func testSearch(t *testing.T, st *store.Store) {
+	ctx := context.Background()
+	u, other := newUser(t, st), newUser(t, st)
+	w, x := word(), word()
+	cat := unique("cat")
+	base := now().Add(-24 * time.Hour)
+
+	post := func(uid int64, title, content string, at time.Time, cats ...string) int64 {
+		t.Helper()
+		id, err := st.Posts.Create(ctx, models.Post{UserID: uid, Title: title, Content: content, CreatedAt: at}, cats)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	p1 := post(u.ID, w+" guide", "an introduction for beginners", base, cat)
+	p2 := post(other.ID, "other", "this one mentions "+w+" once", base.Add(time.Hour))
+	p3 := post(u.ID, "third", w+" together with "+x, base.Add(2*time.Hour))
+	c1, err := st.Comments.Create(ctx, models.Comment{PostID: p1, UserID: other.ID, Content: "a reply about " + w, CreatedAt: base.Add(3 * time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	search := func(q store.SearchQuery) []store.SearchHit {
+		t.Helper()
+		hits, _, err := st.Search.Search(ctx, q)
+		if err != nil {
+			t.Fatalf("Search(%+v): %v", q, err)
+		}
+		return hits
+	}
+	ids := func(hits []store.SearchHit) []string {
+		var out []string
+		for _, h := range hits {
+			if h.Kind == "comment" {
+				out = append(out, fmt.Sprint("c", h.CommentID))
+			} else {
+				out = append(out, fmt.Sprint("p", h.PostID))
+			}
+		}
+		return out
+	}
+	want := func(got []store.SearchHit, ordered bool, expect ...string) {
+		t.Helper()
+		g := ids(got)
+		if !ordered {
+			sort.Strings(g)
+			sort.Strings(expect)
+		}
+		if fmt.Sprint(g) != fmt.Sprint(expect) {
+			t.Fatalf("hits = %v, want %v", g, expect)
+		}
+	}
+	P := func(id int64) string { return fmt.Sprint("p", id) }
+	C := func(id int64) string { return fmt.Sprint("c", id) }
+
+	// Texto: posts y comentarios; el título pesa más que el cuerpo
+	hits := search(store.SearchQuery{Text: w})
+	want(hits, false, P(p1), P(p2), P(p3), C(c1))
+	if hits[0].PostID != p1 || hits[0].Kind != "post" || hits[0].Title != w+" guide" {
+		t.Fatalf("best hit = %+v, want the post with the word in its title", hits[0])
+	}
+	for _, h := range hits {
+		if h.PostID == p2 && (!strings.Contains(h.Snippet, store.HighlightStart) || h.Author != other.Username) {
+			t.Fatalf("hit without highlight or author: %+v", h)
+		}
+		if h.Kind == "comment" && h.Title != w+" guide" {
+			t.Fatalf("comment hit should carry its post title: %+v", h)
+		}
+	}
+
+	// Operadores: exclusión, frase, autor, categoría y fechas
+	want(search(store.SearchQuery{Text: w + " -" + x}), false, P(p1), P(p2), C(c1))
+	want(search(store.SearchQuery{Text: `"mentions ` + w + `"`}), false, P(p2))
+	want(search(store.SearchQuery{Text: w, Author: other.Username}), false, P(p2), C(c1))
+	want(search(store.SearchQuery{Text: w, Category: cat}), false, P(p1), C(c1))
+	want(search(store.SearchQuery{Text: w, From: base.Add(30 * time.Minute), To: base.Add(150 * time.Minute)}), false, P(p2), P(p3))
+
+	// Sin texto: solo filtros, del más nuevo al más antiguo
+	want(search(store.SearchQuery{Author: other.Username}), true, C(c1), P(p2))
+
+	// Paginación
+	page, more, err := st.Search.Search(ctx, store.SearchQuery{Text: w, Limit: 3})
+	if err != nil || len(page) != 3 || !more {
+		t.Fatalf("page 1 = %d hits, more=%v, %v", len(page), more, err)
+	}
+	page, more, _ = st.Search.Search(ctx, store.SearchQuery{Text: w, Limit: 3, Offset: 3})
+	if len(page) != 1 || more {
+		t.Fatalf("page 2 = %d hits, more=%v", len(page), more)
+	}
+
+	// El índice sigue a las ediciones y borrados
+	if err := st.Posts.Update(ctx, p3, "third", "nothing to see", now()); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Posts.Delete(ctx, p2); err != nil {
+		t.Fatal(err)
+	}
+	want(search(store.SearchQuery{Text: w}), false, P(p1), C(c1))
+}
+
+func testLoginAttempts(t *testing.T, st *store.Store) {
+	ctx := context.Background()
+	key, other := unique("account:a"), unique("ip:b")
+	t0 := now().Add(-time.Hour)
+	for i := 0; i < 4; i++ {
+		if err := st.LoginAttempts.Fail(ctx, key, t0.Add(time.Duration(i)*time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := st.LoginAttempts.Fail(ctx, other, t0); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := st.LoginAttempts.Recent(ctx, key, t0.Add(time.Minute), 2)
+	if err != nil || len(got) != 2 || !got[0].Equal(t0.Add(3*time.Minute)) || !got[1].Equal(t0.Add(2*time.Minute)) {
+		t.Fatalf("Recent = %v, %v", got, err)
+	}
+	if got, _ := st.LoginAttempts.Recent(ctx, key, t0, 10); len(got) != 4 {
+		t.Fatalf("Recent since t0 = %d, want 4", len(got))
+	}
+
+	// Prune quita solo lo anterior al corte
+	if err := st.LoginAttempts.Prune(ctx, t0.Add(2*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := st.LoginAttempts.Recent(ctx, key, t0, 10); len(got) != 2 {
+		t.Fatalf("after Prune = %d, want 2", len(got))
+	}
+	if got, _ := st.LoginAttempts.Recent(ctx, other, t0, 10); len(got) != 0 {
+		t.Fatalf("other key after Prune = %d, want 0", len(got))
+	}
+
+	if err := st.LoginAttempts.Clear(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := st.LoginAttempts.Recent(ctx, key, t0, 10); len(got) != 0 {
+		t.Fatalf("after Clear = %d", len(got))
+	}
+}
+
+func testTwoFactor(t *testing.T, st *store.Store) {
+	ctx := context.Background()
+	u := newUser(t, st)
+	n := now()
+
+	if _, err := st.TwoFactor.Get(ctx, u.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Get before Begin err = %v", err)
+	}
+	if err := st.TwoFactor.Enable(ctx, u.ID, 1, nil, n); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Enable without Begin err = %v", err)
+	}
+	// Un alta pendiente se puede repetir con otro secreto
+	for _, secret := range []string{"FIRST", "SECOND"} {
+		if err := st.TwoFactor.Begin(ctx, u.ID, secret, n); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tf, err := st.TwoFactor.Get(ctx, u.ID)
+	if err != nil || tf.Secret != "SECOND" || tf.EnabledAt != nil {
+		t.Fatalf("pending = %+v, %v", tf, err)
+	}
+	if err := st.TwoFactor.UseStep(ctx, u.ID, 5); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("UseStep while pending err = %v", err)
+	}
+
+	if err := st.TwoFactor.Enable(ctx, u.ID, 10, []string{"h1", "h2", "h3"}, n); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.TwoFactor.Begin(ctx, u.ID, "THIRD", n); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Begin while enabled err = %v", err)
+	}
+	tf, err = st.TwoFactor.Get(ctx, u.ID)
+	if err != nil || tf.Secret != "SECOND" || tf.EnabledAt == nil || tf.LastStep != 10 || tf.CodesLeft != 3 {
+		t.Fatalf("enabled = %+v, %v", tf, err)
+	}
+
+	// Pasos: solo hacia delante
+	if err := st.TwoFactor.UseStep(ctx, u.ID, 10); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("reused step err = %v", err)
+	}
+	if err := st.TwoFactor.UseStep(ctx, u.ID, 11); err != nil {
+		t.Fatal(err)
+	}
+
+	// Códigos: cada uno una vez; reemplazar invalida los anteriores
+	if err := st.TwoFactor.UseRecoveryCode(ctx, u.ID, "h1", n); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.TwoFactor.UseRecoveryCode(ctx, u.ID, "h1", n); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("reused code err = %v", err)
+	}
+	if tf, _ := st.TwoFactor.Get(ctx, u.ID); tf.CodesLeft != 2 {
+		t.Fatalf("CodesLeft = %d, want 2", tf.CodesLeft)
+	}
+	if err := st.TwoFactor.ReplaceRecoveryCodes(ctx, u.ID, []string{"n1", "n2"}, n); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.TwoFactor.UseRecoveryCode(ctx, u.ID, "h2", n); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("old code after replace err = %v", err)
+	}
+	if tf, _ := st.TwoFactor.Get(ctx, u.ID); tf.CodesLeft != 2 {
+		t.Fatalf("CodesLeft after replace = %d, want 2", tf.CodesLeft)
+	}
+
+	if err := st.TwoFactor.Disable(ctx, u.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.TwoFactor.Get(ctx, u.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Get after Disable err = %v", err)
+	}
+	if err := st.TwoFactor.UseRecoveryCode(ctx, u.ID, "n1", n); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("code after Disable err = %v", err)
+	}
+}
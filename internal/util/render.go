@@ -1,23 +1,55 @@
 package util
 
 import (
+	"errors"
 	"html/template"
 	"net/http"
 	"path/filepath"
 )
 
+// funcs disponibles en todas las plantillas.
+var funcs = template.FuncMap{
+	// dict permite pasar varios valores a un parcial: {{template "x" dict "A" .A "B" $}}
+	"dict": func(kv ...any) (map[string]any, error) {
+		if len(kv)%2 != 0 {
+			return nil, errors.New("dict: odd number of arguments")
+		}
+		m := make(map[string]any, len(kv)/2)
+		for i := 0; i < len(kv); i += 2 {
+			k, ok := kv[i].(string)
+			if !ok {
+				return nil, errors.New("dict: keys must be strings")
+			}
+			m[k] = kv[i+1]
+		}
+		return m, nil
+	},
+}
+
 func Render(w http.ResponseWriter, name string, data any) {
-	layout := filepath.Join("web", "templates", "layout.html")
-	flash := filepath.Join("web", "templates", "_flash.html")
-	view := filepath.Join("web", "templates", name)
+	RenderStatus(w, http.StatusOK, name, data)
+}
+
+// RenderStatus es Render con un código HTTP distinto de 200 (p. ej. 404).
+// Carga el layout, todos los parciales "_*.html" y la vista.
+func RenderStatus(w http.ResponseWriter, status int, name string, data any) {
+	dir := filepath.Join("web", "templates")
+	partials, err := filepath.Glob(filepath.Join(dir, "_*.html"))
+	if err != nil {
+		http.Error(w, "template glob error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	files := append([]string{filepath.Join(dir, "layout.html")}, partials...)
+	files = append(files, filepath.Join(dir, name))
 
-	t, err := template.ParseFiles(layout, flash, view)
+	t, err := template.New("base").Funcs(funcs).ParseFiles(files...)
 	if err != nil {
 		http.Error(w, "template parse error: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
 	if err := t.ExecuteTemplate(w, "base", data); err != nil {
 		http.Error(w, "template exec error: "+err.Error(), http.StatusInternalServerError)
 		return